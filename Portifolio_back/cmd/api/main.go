@@ -2,47 +2,195 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/anomaly"
 	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/auth"
 	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/db"
 	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/forecast"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/integrations/mqtt"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/integrations/shelly"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/integrations/tapo"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/integrations/tasmota"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/loadshed"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/notify"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/poller"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/recommendations"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/scenes"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/schedules"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/secrets"
 	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/simulator"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/tariffs"
 	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/timers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/webhooks"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/ws"
 )
 
 func main() {
 	_ = godotenv.Load("../../.env.example")
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	pool, err := db.NewPool(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer pool.Close()
+
+	// "api migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(ctx, pool, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
 
-	r := setupRouter(ctx, pool)
+	// Apply pending schema migrations; refuse to start on a broken schema
+	applied, err := db.MigrateUp(ctx, pool)
+	if err != nil {
+		log.Fatalf("database migration failed: %v", err)
+	}
+	if applied > 0 {
+		log.Printf("Applied %d database migrations", applied)
+	}
+
+	svc := newServices(ctx, pool)
+	r := setupRouter(pool, svc)
 	port := getPort()
 
-	log.Printf("Starting server on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	// MQTT telemetry ingestion (disabled when MQTT_BROKER_URL is unset)
+	startMQTTSubscriber(ctx, pool, svc)
+
+	// Background polling of physical devices (disabled when POLL_INTERVAL_SEC=0)
+	p := startPoller(ctx, pool, svc)
+
+	// Alert rules engine (disabled when ALERT_RULES_INTERVAL_SEC=0)
+	rules := startAlertRules(ctx, pool, svc)
+
+	// E-mail delivery from the notification outbox (disabled when SMTP_HOST is unset)
+	mailer := startNotifications(ctx, svc)
+
+	// Outgoing webhooks for hub events
+	svc.webhooks.Start(ctx)
+
+	// Device schedules
+	scheduler := schedules.NewScheduler(schedules.NewRepo(&schedulesQuerier{wrap(pool)}), svc.control, svc.scenes)
+	scheduler.Start(ctx)
+
+	// Countdown and power-based auto-off timers
+	timerRunner := timers.NewRunner(
+		timers.NewRepo(&timersQuerier{wrap(pool)}),
+		svc.control,
+		telemetry.NewRepo(&telemetryQuerier{wrap(pool)}),
+	)
+	timerRunner.Start(ctx)
+
+	// Household load shedding against each user's power budget
+	svc.shedder.Start(ctx)
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	// Shutdown waits for open requests; end the SSE streams so it doesn't hang on them
+	srv.RegisterOnShutdown(svc.hub.Close)
+	go func() {
+		log.Printf("Starting server on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: HTTP server shutdown: %v", err)
+	}
+	if p != nil {
+		p.Wait()
+	}
+	if rules != nil {
+		rules.Wait()
+	}
+	if mailer != nil {
+		mailer.Wait()
+	}
+	svc.webhooks.Wait()
+	scheduler.Wait()
+	timerRunner.Wait()
+	svc.shedder.Wait()
+}
+
+// services holds the components shared by the HTTP API and the background workers.
+type services struct {
+	registry *drivers.Registry
+	hub      *realtime.Hub
+	creds    devices.CredentialStore // nil when no encryption key is configured
+	alerts   *alerts.Evaluator
+	anomaly  *anomaly.Detector
+	notify   *notify.Service
+	smtp     notify.SMTPConfig
+	webhooks *webhooks.Dispatcher
+	control  *devices.Controller // device commands issued by automations
+	scenes   *scenes.Executor
+	shedder  *loadshed.Shedder
+}
+
+func newServices(ctx context.Context, pool *pgxpool.Pool) *services {
+	wrapped := wrap(pool)
+	hub := realtime.NewHub()
+	smtpCfg := notify.SMTPConfigFromEnv()
+	notifier := notify.NewService(notify.NewRepo(&notifyQuerier{wrapped}), smtpCfg.Enabled())
+	hooks := webhooks.NewDispatcher(webhooks.NewRepo(&webhooksQuerier{wrapped}))
+	hub.Observe(hooks.Observe)
+	registry := newDriverRegistry()
+	creds := setupCredentialStore(ctx, wrapped)
+	control := devices.NewController(devices.NewRepo(&devicesQuerier{wrapped}), registry, creds, hub)
+	alertsRepo := alerts.NewRepo(&alertsQuerier{wrapped})
+	evaluator := alerts.NewEvaluator(alertsRepo, hub, notifier)
+	detector := anomaly.NewDetector(anomaly.NewRepo(&anomalyQuerier{wrapped}), alertsRepo, hub, notifier)
+	evaluator.Anomalies = detector
+	return &services{
+		registry: registry,
+		hub:      hub,
+		creds:    creds,
+		alerts:   evaluator,
+		anomaly:  detector,
+		notify:   notifier,
+		smtp:     smtpCfg,
+		webhooks: hooks,
+		control:  control,
+		scenes:   scenes.NewExecutor(scenes.NewRepo(&scenesQuerier{wrapped}), control),
+		shedder: loadshed.NewShedder(
+			loadshed.NewRepo(&loadshedQuerier{wrapped}),
+			control,
+			telemetry.NewRepo(&telemetryQuerier{wrapped}),
+		),
+	}
 }
 
 // setupRouter configures and returns the Gin router with all routes and middleware.
-func setupRouter(ctx context.Context, pool *pgxpool.Pool) *gin.Engine {
-	r := gin.Default()
-	r.Use(gin.Logger(), gin.Recovery())
+func setupRouter(pool *pgxpool.Pool, svc *services) *gin.Engine {
+	r := gin.New()
+	r.Use(auth.RequestLogger(), gin.Recovery())
 
 	// Health check endpoint
 	r.GET("/health", healthCheckHandler)
 
-	// Database schema initialization
-	ensureSchema(ctx, pool)
-
 	// Wrap pool for interfaces
 	wrapped := wrap(pool)
 
@@ -55,12 +203,17 @@ func setupRouter(ctx context.Context, pool *pgxpool.Pool) *gin.Engine {
 	{
 		// Devices CRUD
 		devicesRepo := devices.NewRepo(&devicesQuerier{wrapped})
-		devicesHandler := devices.NewHandler(devicesRepo)
+		devicesHandler := devices.NewHandler(devicesRepo, svc.registry, svc.creds, svc.hub)
+		devicesHandler.TopicAllowed = mqtt.ConfigFromEnv().Claimable
 		devicesHandler.RegisterRoutes(api)
 
+		// WebSocket control channel (/api/ws)
+		ws.NewHandler(devicesHandler.Control, svc.hub).RegisterRoutes(api)
+
 		// Telemetry CRUD
 		telemetryRepo := telemetry.NewRepo(&telemetryQuerier{wrapped})
-		telemetryHandler := telemetry.NewHandler(telemetryRepo)
+		tariffEngine := tariffs.NewEngine(tariffs.NewRepo(&tariffsQuerier{wrapped}), telemetryRepo)
+		telemetryHandler := telemetry.NewHandler(telemetryRepo, svc.hub, svc.alerts, tariffEngine)
 		telemetryHandler.RegisterRoutes(api)
 		
 		// Device-specific telemetry routes (/api/devices/:id/telemetry)
@@ -68,8 +221,21 @@ func setupRouter(ctx context.Context, pool *pgxpool.Pool) *gin.Engine {
 
 		// Simulator for generating test telemetry data
 		simulatorCreator := &telemetryCreatorAdapter{repo: telemetryRepo}
-		simulatorHandler := simulator.NewHandler(simulatorCreator)
+		simulatorHandler := simulator.NewHandler(simulatorCreator, svc.hub, svc.alerts)
 		simulatorHandler.RegisterRoutes(api)
+
+		// Alert thresholds and alert events
+		alerts.NewHandler(svc.alerts.Repo).RegisterRoutes(api)
+		anomaly.NewHandler(svc.anomaly.Repo).RegisterRoutes(api)
+		notify.NewHandler(svc.notify).RegisterRoutes(api)
+		webhooks.NewHandler(webhooks.NewRepo(&webhooksQuerier{wrapped}), svc.webhooks).RegisterRoutes(api)
+		schedules.NewHandler(schedules.NewRepo(&schedulesQuerier{wrapped})).RegisterRoutes(api)
+		timers.NewHandler(timers.NewRepo(&timersQuerier{wrapped})).RegisterRoutes(api)
+		scenes.NewHandler(svc.scenes).RegisterRoutes(api)
+		loadshed.NewHandler(loadshed.NewRepo(&loadshedQuerier{wrapped}), svc.shedder).RegisterRoutes(api)
+		tariffs.NewHandler(tariffEngine).RegisterRoutes(api)
+		forecast.NewHandler(forecast.NewForecaster(forecast.NewRepo(&forecastQuerier{wrapped}), telemetryRepo, tariffEngine)).RegisterRoutes(api)
+		recommendations.NewHandler(recommendations.NewAnalyzer(recommendations.NewRepo(&recommendationsQuerier{wrapped}), tariffEngine)).RegisterRoutes(api)
 	}
 
 	// Configure static file serving for frontend SPA
@@ -78,6 +244,122 @@ func setupRouter(ctx context.Context, pool *pgxpool.Pool) *gin.Engine {
 	return r
 }
 
+// setupCredentialStore loads CREDENTIALS_KEYS, re-encrypts credentials sealed with
+// an old key and moves any plaintext credentials out of device metadata.
+// It returns nil when no key is configured.
+func setupCredentialStore(ctx context.Context, wrapped *pgxWrap) devices.CredentialStore {
+	keys, err := secrets.KeyringFromEnv()
+	if errors.Is(err, secrets.ErrNoKeys) {
+		log.Printf("Warning: CREDENTIALS_KEYS not set, device credentials stay in plaintext metadata")
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("invalid CREDENTIALS_KEYS: %v", err)
+	}
+
+	store := secrets.NewStore(&secretsQuerier{wrapped}, keys)
+	if n, err := store.Rotate(ctx); err != nil {
+		log.Printf("Warning: credential key rotation failed after %d devices: %v", n, err)
+	} else if n > 0 {
+		log.Printf("Re-encrypted credentials of %d devices with key %q", n, keys.ActiveKeyID())
+	}
+
+	devicesRepo := devices.NewRepo(&devicesQuerier{wrapped})
+	if _, err := devices.MigratePlaintextCredentials(ctx, devicesRepo, store); err != nil {
+		log.Printf("Warning: failed to migrate plaintext device credentials: %v", err)
+	}
+	return store
+}
+
+// startMQTTSubscriber connects the MQTT ingestion subscriber when a broker is configured.
+func startMQTTSubscriber(ctx context.Context, pool *pgxpool.Pool, svc *services) {
+	cfg := mqtt.ConfigFromEnv()
+	if !cfg.Enabled() {
+		log.Printf("MQTT_BROKER_URL not set, MQTT ingestion disabled")
+		return
+	}
+
+	wrapped := wrap(pool)
+	sub := mqtt.NewSubscriber(
+		cfg,
+		telemetry.NewRepo(&telemetryQuerier{wrapped}),
+		devices.NewRepo(&devicesQuerier{wrapped}),
+		svc.hub,
+		svc.alerts,
+	)
+	if err := sub.Start(ctx); err != nil {
+		log.Printf("Warning: MQTT subscriber failed to start: %v", err)
+		return
+	}
+	log.Printf("MQTT ingestion subscribed to %v on %s", cfg.Topics, cfg.BrokerURL)
+}
+
+// startPoller starts the background device poller unless POLL_INTERVAL_SEC is 0.
+func startPoller(ctx context.Context, pool *pgxpool.Pool, svc *services) *poller.Poller {
+	cfg := poller.ConfigFromEnv()
+	if !cfg.Enabled() {
+		log.Printf("POLL_INTERVAL_SEC=0, device polling disabled")
+		return nil
+	}
+
+	wrapped := wrap(pool)
+	p := poller.New(
+		cfg,
+		devices.NewRepo(&devicesQuerier{wrapped}),
+		telemetry.NewRepo(&telemetryQuerier{wrapped}),
+		svc.registry,
+		svc.creds,
+		svc.hub,
+		svc.alerts,
+	)
+	p.Start(ctx)
+	log.Printf("Device poller started (interval %s, concurrency %d)", cfg.Interval, cfg.Concurrency)
+	return p
+}
+
+// startAlertRules starts the alert rules engine unless ALERT_RULES_INTERVAL_SEC is 0.
+func startAlertRules(ctx context.Context, pool *pgxpool.Pool, svc *services) *alerts.Engine {
+	cfg := alerts.EngineConfigFromEnv()
+	if !cfg.Enabled() {
+		log.Printf("ALERT_RULES_INTERVAL_SEC=0, alert rules disabled")
+		return nil
+	}
+
+	e := alerts.NewEngine(cfg, svc.alerts.Repo, telemetry.NewRepo(&telemetryQuerier{wrap(pool)}), svc.hub, svc.notify)
+	e.Start(ctx)
+	log.Printf("Alert rules engine started (interval %s)", cfg.Interval)
+	return e
+}
+
+// startNotifications starts delivering queued e-mails unless SMTP_HOST is unset.
+func startNotifications(ctx context.Context, svc *services) *notify.Dispatcher {
+	if !svc.smtp.Enabled() {
+		log.Printf("SMTP_HOST not set, e-mail notifications disabled")
+		return nil
+	}
+
+	sender, err := notify.NewSMTPSender(svc.smtp)
+	if err != nil {
+		log.Fatalf("invalid SMTP configuration: %v", err)
+	}
+	d := notify.NewDispatcher(svc.notify.Repo, sender)
+	d.Start(ctx)
+	log.Printf("E-mail notifications enabled via %s:%d (%s)", svc.smtp.Host, svc.smtp.Port, svc.smtp.TLS)
+	return d
+}
+
+// newDriverRegistry registers the smart plug drivers by device type.
+// "smart_plug" is the legacy default type and maps to Tapo.
+func newDriverRegistry() *drivers.Registry {
+	registry := drivers.NewRegistry()
+	tapoDriver := tapo.NewDriver()
+	registry.Register(tapo.DriverName, tapoDriver)
+	registry.Register("smart_plug", tapoDriver)
+	registry.Register(shelly.DriverName, shelly.NewDriver())
+	registry.Register(tasmota.DriverName, tasmota.NewDriver())
+	return registry
+}
+
 // healthCheckHandler returns a simple health check response.
 func healthCheckHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -194,6 +476,138 @@ func (t *telemetryQuerier) Query(ctx context.Context, sql string, args ...any) (
 	return &pgxRows{rows: r}, nil
 }
 
+// alertsQuerier adapts pgxWrap to alerts.RowsQuerier interface.
+type alertsQuerier struct{ *pgxWrap }
+
+func (a *alertsQuerier) Query(ctx context.Context, sql string, args ...any) (alerts.Rows, error) {
+	r, err := a.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// anomalyQuerier adapts pgxWrap to anomaly.RowsQuerier interface.
+type anomalyQuerier struct{ *pgxWrap }
+
+func (a *anomalyQuerier) Query(ctx context.Context, sql string, args ...any) (anomaly.Rows, error) {
+	r, err := a.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// notifyQuerier adapts pgxWrap to notify.RowsQuerier interface.
+type notifyQuerier struct{ *pgxWrap }
+
+func (n *notifyQuerier) Query(ctx context.Context, sql string, args ...any) (notify.Rows, error) {
+	r, err := n.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// webhooksQuerier adapts pgxWrap to webhooks.RowsQuerier interface.
+type webhooksQuerier struct{ *pgxWrap }
+
+func (w *webhooksQuerier) Query(ctx context.Context, sql string, args ...any) (webhooks.Rows, error) {
+	r, err := w.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// schedulesQuerier adapts pgxWrap to schedules.RowsQuerier interface.
+type schedulesQuerier struct{ *pgxWrap }
+
+func (s *schedulesQuerier) Query(ctx context.Context, sql string, args ...any) (schedules.Rows, error) {
+	r, err := s.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// timersQuerier adapts pgxWrap to timers.RowsQuerier interface.
+type timersQuerier struct{ *pgxWrap }
+
+func (t *timersQuerier) Query(ctx context.Context, sql string, args ...any) (timers.Rows, error) {
+	r, err := t.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// scenesQuerier adapts pgxWrap to scenes.RowsQuerier interface.
+type scenesQuerier struct{ *pgxWrap }
+
+func (s *scenesQuerier) Query(ctx context.Context, sql string, args ...any) (scenes.Rows, error) {
+	r, err := s.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// loadshedQuerier adapts pgxWrap to loadshed.RowsQuerier interface.
+type loadshedQuerier struct{ *pgxWrap }
+
+func (l *loadshedQuerier) Query(ctx context.Context, sql string, args ...any) (loadshed.Rows, error) {
+	r, err := l.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// tariffsQuerier adapts pgxWrap to tariffs.RowsQuerier interface.
+type tariffsQuerier struct{ *pgxWrap }
+
+func (t *tariffsQuerier) Query(ctx context.Context, sql string, args ...any) (tariffs.Rows, error) {
+	r, err := t.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// forecastQuerier adapts pgxWrap to forecast.RowsQuerier interface.
+type forecastQuerier struct{ *pgxWrap }
+
+func (f *forecastQuerier) Query(ctx context.Context, sql string, args ...any) (forecast.Rows, error) {
+	r, err := f.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
+// recommendationsQuerier adapts pgxWrap to recommendations.RowsQuerier interface.
+type recommendationsQuerier struct{ *pgxWrap }
+
+func (r *recommendationsQuerier) Query(ctx context.Context, sql string, args ...any) (recommendations.Rows, error) {
+	rows, err := r.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: rows}, nil
+}
+
+// secretsQuerier adapts pgxWrap to secrets.RowsQuerier interface.
+type secretsQuerier struct{ *pgxWrap }
+
+func (s *secretsQuerier) Query(ctx context.Context, sql string, args ...any) (secrets.Rows, error) {
+	r, err := s.pgxWrap.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxRows{rows: r}, nil
+}
+
 // telemetryCreatorAdapter adapts telemetry.Repo to simulator.TelemetryCreator interface.
 type telemetryCreatorAdapter struct {
 	repo *telemetry.Repo
@@ -214,47 +628,6 @@ func (a *telemetryCreatorAdapter) UserOwnsDevice(userID, deviceID int64) (bool,
 	return a.repo.UserOwnsDevice(context.Background(), userID, deviceID)
 }
 
-func (a *telemetryCreatorAdapter) UpdateDeviceStatus(deviceID int64) error {
+func (a *telemetryCreatorAdapter) UpdateDeviceStatus(deviceID int64) (bool, error) {
 	return a.repo.UpdateDeviceLastSeenAndStatus(context.Background(), deviceID)
 }
-
-func ensureSchema(ctx context.Context, p *pgxpool.Pool) {
-	// Create users table
-	_, _ = p.Exec(ctx, `CREATE TABLE IF NOT EXISTS app_user(
-		id BIGSERIAL PRIMARY KEY,
-		name TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		created_at TIMESTAMPTZ DEFAULT NOW()
-	)`)
-
-	// Create devices table
-	_, _ = p.Exec(ctx, `CREATE TABLE IF NOT EXISTS device(
-		id BIGSERIAL PRIMARY KEY,
-		user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
-		name TEXT NOT NULL,
-		room TEXT,
-		type TEXT DEFAULT 'smart_plug',
-		status TEXT DEFAULT 'offline',
-		metadata TEXT,
-		created_at TIMESTAMPTZ DEFAULT NOW(),
-		last_seen TIMESTAMPTZ
-	)`)
-
-	// Create index on user_id for faster device lookups
-	_, _ = p.Exec(ctx, `CREATE INDEX IF NOT EXISTS idx_device_user_id ON device(user_id)`)
-
-	// Create telemetry table
-	_, _ = p.Exec(ctx, `CREATE TABLE IF NOT EXISTS telemetry(
-		id BIGSERIAL PRIMARY KEY,
-		device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
-		power DOUBLE PRECISION NOT NULL,
-		voltage DOUBLE PRECISION,
-		current DOUBLE PRECISION,
-		timestamp TIMESTAMPTZ DEFAULT NOW()
-	)`)
-
-	// Create index on device_id and timestamp for faster telemetry queries
-	_, _ = p.Exec(ctx, `CREATE INDEX IF NOT EXISTS idx_telemetry_device_id ON telemetry(device_id)`)
-	_, _ = p.Exec(ctx, `CREATE INDEX IF NOT EXISTS idx_telemetry_timestamp ON telemetry(timestamp DESC)`)
-}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/db"
+)
+
+const migrateUsage = "usage: api migrate up | down [steps] | status"
+
+// runMigrateCommand handles "api migrate up|down [steps]|status".
+func runMigrateCommand(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := db.MigrateUp(ctx, pool)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				return fmt.Errorf("invalid steps %q: must be a positive number", args[1])
+			}
+			steps = v
+		}
+		n, err := db.MigrateDown(ctx, pool, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migrations\n", n)
+	case "status":
+		list, err := db.Status(ctx, pool)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d %-30s %s\n", m.Version, m.Name, state)
+		}
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+)
+
+// Evaluator checks readings against device thresholds and keeps at most one
+// active threshold alert per device: it opens an alert when a limit is crossed,
+// replaces it when the level changes and resolves it when power drops below warning.
+type Evaluator struct {
+	Repo   *Repo
+	Events realtime.Publisher // nil when nobody listens for live updates
+	Notify Notifier           // nil when notifications are off
+
+	Anomalies ReadingObserver // nil disables anomaly detection
+
+	locks sync.Map // device id -> *sync.Mutex, serialises evaluations per device
+}
+
+// Notifier is told about every alert that is opened or resolved.
+type Notifier interface {
+	AlertChanged(ctx context.Context, userID int64, a *Alert)
+}
+
+// ReadingObserver is given every reading the evaluator checks, under the same
+// per-device lock (satisfied by anomaly.Detector).
+type ReadingObserver interface {
+	Observe(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error
+}
+
+// NewEvaluator creates a threshold evaluator.
+func NewEvaluator(repo *Repo, events realtime.Publisher, notify Notifier) *Evaluator {
+	return &Evaluator{Repo: repo, Events: events, Notify: notify}
+}
+
+// Evaluate checks one power reading (Watts) of a device owned by userID
+// against its thresholds and passes it on to anomaly detection.
+func (e *Evaluator) Evaluate(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error {
+	mu, _ := e.locks.LoadOrStore(deviceID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	err := e.checkThresholds(ctx, userID, deviceID, power, at)
+	if e.Anomalies != nil {
+		err = errors.Join(err, e.Anomalies.Observe(ctx, userID, deviceID, power, at))
+	}
+	return err
+}
+
+// checkThresholds opens, replaces or resolves the device's threshold alert.
+func (e *Evaluator) checkThresholds(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error {
+	t, err := e.Repo.GetThreshold(ctx, deviceID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	active, err := e.Repo.ActiveAlert(ctx, deviceID, SourceThreshold)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	level, limit := "", 0.0
+	if t != nil {
+		level, limit = classify(power, t)
+	}
+
+	if active != nil {
+		if active.Level == level {
+			return e.Repo.RecordPeak(ctx, active.ID, power)
+		}
+		// Back to normal, thresholds removed or level changed: close the current alert
+		if err := e.Repo.Resolve(ctx, active.ID); err != nil {
+			return err
+		}
+		active.State = StateResolved
+		resolvedAt := time.Now()
+		active.ResolvedAt = &resolvedAt
+		e.publish(ctx, userID, active)
+	}
+	if level == "" {
+		return nil
+	}
+
+	a := &Alert{
+		DeviceID:  deviceID,
+		Source:    SourceThreshold,
+		Level:     level,
+		State:     StateOpen,
+		Message:   fmt.Sprintf("Power %.1f W exceeded the %s threshold of %.1f W", power, level, limit),
+		Value:     power,
+		Threshold: limit,
+		PeakValue: power,
+		OpenedAt:  at,
+	}
+	id, err := e.Repo.OpenAlert(ctx, a)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	e.publish(ctx, userID, a)
+	return nil
+}
+
+// classify returns the level crossed by power and its limit ("" when below warning).
+func classify(power float64, t *Threshold) (string, float64) {
+	switch {
+	case power >= t.Danger:
+		return LevelDanger, t.Danger
+	case power >= t.Warning:
+		return LevelWarning, t.Warning
+	default:
+		return "", 0
+	}
+}
+
+func (e *Evaluator) publish(ctx context.Context, userID int64, a *Alert) {
+	if e.Events != nil {
+		e.Events.Publish(realtime.AlertEvent(userID, a.DeviceID, a))
+	}
+	if e.Notify != nil {
+		e.Notify.AlertChanged(ctx, userID, a)
+	}
+}
@@ -0,0 +1,386 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles threshold, alert and alert rule HTTP requests.
+type Handler struct {
+	Repo *Repo
+}
+
+// NewHandler creates a new alerts handler.
+func NewHandler(repo *Repo) *Handler {
+	return &Handler{Repo: repo}
+}
+
+// RegisterRoutes registers threshold and alert routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/thresholds", h.ListThresholds)
+
+	d := r.Group("/devices")
+	d.GET("/:id/thresholds", h.GetThreshold)
+	d.PUT("/:id/thresholds", h.SetThreshold)
+	d.DELETE("/:id/thresholds", h.DeleteThreshold)
+
+	a := r.Group("/alerts")
+	a.GET("", h.List)
+	a.POST("/:id/ack", h.Acknowledge)
+	a.POST("/:id/resolve", h.Resolve)
+
+	ar := r.Group("/alert-rules")
+	ar.GET("", h.ListRules)
+	ar.POST("", h.CreateRule)
+	ar.GET("/:id", h.GetRule)
+	ar.PUT("/:id", h.UpdateRule)
+	ar.DELETE("/:id", h.DeleteRule)
+}
+
+// ListThresholds returns the thresholds of every device of the user.
+func (h *Handler) ListThresholds(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.Repo.ListThresholdsByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch thresholds"})
+		return
+	}
+	if list == nil {
+		list = []Threshold{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetThreshold returns the thresholds of a device.
+func (h *Handler) GetThreshold(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	t, err := h.Repo.GetThreshold(c.Request.Context(), deviceID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no thresholds set for device"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch thresholds"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// SetThreshold creates or replaces the thresholds of a device.
+func (h *Handler) SetThreshold(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	var req ThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: warning must be positive and danger greater than warning"})
+		return
+	}
+
+	t, err := h.Repo.UpsertThreshold(c.Request.Context(), deviceID, req.Warning, req.Danger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save thresholds"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteThreshold removes the thresholds of a device.
+func (h *Handler) DeleteThreshold(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.DeleteThreshold(c.Request.Context(), deviceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete thresholds"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// List returns the user's alerts.
+// Query params: state (open|acknowledged|resolved), device_id, rule_id, limit (default 100)
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var f ListFilter
+	switch state := c.Query("state"); state {
+	case "", StateOpen, StateAcknowledged, StateResolved:
+		f.State = state
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		f.DeviceID = id
+	}
+	if v := c.Query("rule_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule_id"})
+			return
+		}
+		f.RuleID = id
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			f.Limit = parsed
+		}
+	}
+
+	list, err := h.Repo.ListByUser(c.Request.Context(), userID, f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alerts"})
+		return
+	}
+	if list == nil {
+		list = []Alert{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Acknowledge marks an open alert as seen; it stays active until resolved.
+func (h *Handler) Acknowledge(c *gin.Context) {
+	h.transition(c, h.Repo.Acknowledge, "alert not found or not open")
+}
+
+// Resolve closes an alert manually.
+func (h *Handler) Resolve(c *gin.Context) {
+	h.transition(c, h.Repo.ResolveForUser, "alert not found or already resolved")
+}
+
+func (h *Handler) transition(c *gin.Context, apply func(ctx context.Context, userID, id int64) error, notFound string) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := apply(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update alert"})
+		return
+	}
+
+	a, err := h.Repo.GetForUser(c.Request.Context(), userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alert"})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// ListRules returns the user's alert rules.
+func (h *Handler) ListRules(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.Repo.ListRulesByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alert rules"})
+		return
+	}
+	if list == nil {
+		list = []Rule{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetRule returns one of the user's alert rules.
+func (h *Handler) GetRule(c *gin.Context) {
+	userID, id, ok := ruleParams(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.Repo.GetRuleForUser(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alert rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateRule adds an alert rule on one of the user's devices.
+func (h *Handler) CreateRule(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	req, ok := h.bindRule(c, userID)
+	if !ok {
+		return
+	}
+
+	rule, err := h.Repo.CreateRule(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create alert rule"})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule replaces one of the user's alert rules. Disabling a rule resolves its active alert.
+func (h *Handler) UpdateRule(c *gin.Context) {
+	userID, id, ok := ruleParams(c)
+	if !ok {
+		return
+	}
+	req, ok := h.bindRule(c, userID)
+	if !ok {
+		return
+	}
+
+	rule, err := h.Repo.UpdateRule(c.Request.Context(), userID, id, req)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update alert rule"})
+		return
+	}
+	if !rule.Enabled {
+		if err := h.Repo.ResolveRuleAlerts(c.Request.Context(), userID, rule.ID); err != nil {
+			log.Printf("Warning: failed to resolve alerts of disabled rule %d: %v", rule.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes one of the user's alert rules.
+func (h *Handler) DeleteRule(c *gin.Context) {
+	userID, id, ok := ruleParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.DeleteRule(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete alert rule"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindRule parses and validates a rule payload and checks the user owns its device.
+func (h *Handler) bindRule(c *gin.Context, userID int64) (*RuleRequest, bool) {
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: device_id, name and kind are required"})
+		return nil, false
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	owns, err := h.Repo.UserOwnsDevice(c.Request.Context(), userID, req.DeviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return nil, false
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// ruleParams extracts the user ID and the :id rule parameter, writing the error response if invalid.
+func ruleParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+// ownedDevice parses :id and checks the user owns the device, writing the error response if not.
+func (h *Handler) ownedDevice(c *gin.Context) (int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, false
+	}
+	deviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return 0, false
+	}
+
+	owns, err := h.Repo.UserOwnsDevice(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return 0, false
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return 0, false
+	}
+	return deviceID, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,163 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Alert states.
+const (
+	StateOpen         = "open"
+	StateAcknowledged = "acknowledged"
+	StateResolved     = "resolved"
+)
+
+// Alert levels.
+const (
+	LevelWarning = "warning"
+	LevelDanger  = "danger"
+)
+
+// Alert sources.
+const (
+	SourceThreshold = "threshold" // per-device power thresholds, checked on every reading
+	SourceRule      = "rule"      // alert rules, checked by the background engine
+	SourceAnomaly   = "anomaly"   // hourly power unlike the device's usual, checked as readings arrive
+)
+
+// Threshold holds the power limits (Watts) of a device.
+type Threshold struct {
+	DeviceID  int64     `json:"device_id"`
+	Warning   float64   `json:"warning"`
+	Danger    float64   `json:"danger"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ThresholdRequest is the payload for setting a device's thresholds.
+type ThresholdRequest struct {
+	Warning float64 `json:"warning" binding:"required,gt=0"`
+	Danger  float64 `json:"danger" binding:"required,gtfield=Warning"`
+}
+
+// Alert is a persisted alert event.
+type Alert struct {
+	ID             int64      `json:"id"`
+	DeviceID       int64      `json:"device_id"`
+	DeviceName     string     `json:"device_name,omitempty"`
+	Source         string     `json:"source"`
+	RuleID         *int64     `json:"rule_id,omitempty"`
+	Level          string     `json:"level"` // "warning" or "danger"
+	State          string     `json:"state"` // "open", "acknowledged" or "resolved"
+	Message        string     `json:"message"`
+	Value          float64    `json:"value"`      // reading that opened the alert
+	Threshold      float64    `json:"threshold"`  // limit that was crossed
+	PeakValue      float64    `json:"peak_value"` // highest reading while active
+	OpenedAt       time.Time  `json:"opened_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ListFilter narrows an alert listing.
+type ListFilter struct {
+	State    string // empty = any
+	DeviceID int64  // 0 = any
+	RuleID   int64  // 0 = any
+	Limit    int
+}
+
+// Rule kinds.
+const (
+	RuleAvgPowerAbove    = "avg_power_above"    // average power over window_minutes > threshold (W)
+	RuleOffline          = "offline"            // no reading for more than threshold minutes
+	RuleDailyEnergyAbove = "daily_energy_above" // energy since local midnight > threshold (kWh)
+	RuleVoltageOutside   = "voltage_outside"    // average voltage over window_minutes outside [min_value, max_value]
+)
+
+// Rule is a user-defined alert condition on one device.
+// Hysteresis is in the unit of the rule (W, minutes, kWh or V): an alert only
+// resolves once the value is back inside the limit by at least that margin.
+// Cooldown keeps a resolved rule from firing again too soon.
+type Rule struct {
+	ID              int64     `json:"id"`
+	UserID          int64     `json:"user_id"`
+	DeviceID        int64     `json:"device_id"`
+	Name            string    `json:"name"`
+	Kind            string    `json:"kind"`
+	Level           string    `json:"level"`
+	Threshold       *float64  `json:"threshold,omitempty"`
+	MinValue        *float64  `json:"min_value,omitempty"`
+	MaxValue        *float64  `json:"max_value,omitempty"`
+	WindowMinutes   int       `json:"window_minutes"`
+	Hysteresis      float64   `json:"hysteresis"`
+	CooldownMinutes int       `json:"cooldown_minutes"`
+	Timezone        string    `json:"timezone"` // IANA name; "today" of daily rules
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// RuleRequest is the payload for creating or replacing a rule.
+type RuleRequest struct {
+	DeviceID        int64    `json:"device_id" binding:"required"`
+	Name            string   `json:"name" binding:"required"`
+	Kind            string   `json:"kind" binding:"required"`
+	Level           string   `json:"level"`
+	Threshold       *float64 `json:"threshold"`
+	MinValue        *float64 `json:"min_value"`
+	MaxValue        *float64 `json:"max_value"`
+	WindowMinutes   int      `json:"window_minutes"`
+	Hysteresis      float64  `json:"hysteresis"`
+	CooldownMinutes int      `json:"cooldown_minutes"`
+	Timezone        string   `json:"timezone"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+const (
+	// defaultWindowMinutes is used by windowed rules that set no window.
+	defaultWindowMinutes = 10
+	defaultTimezone      = "America/Sao_Paulo"
+)
+
+// Validate checks the fields required by the rule kind and fills defaults.
+func (r *RuleRequest) Validate() error {
+	if r.Level == "" {
+		r.Level = LevelWarning
+	}
+	if r.Level != LevelWarning && r.Level != LevelDanger {
+		return errors.New("level must be warning or danger")
+	}
+	if r.Hysteresis < 0 || r.CooldownMinutes < 0 || r.WindowMinutes < 0 {
+		return errors.New("hysteresis, cooldown_minutes and window_minutes must not be negative")
+	}
+	if r.Timezone == "" {
+		r.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+
+	switch r.Kind {
+	case RuleAvgPowerAbove, RuleOffline, RuleDailyEnergyAbove:
+		if r.Threshold == nil || *r.Threshold <= 0 {
+			return fmt.Errorf("%s requires a positive threshold", r.Kind)
+		}
+		if r.Hysteresis >= *r.Threshold {
+			return errors.New("hysteresis must be smaller than threshold")
+		}
+	case RuleVoltageOutside:
+		if r.MinValue == nil || r.MaxValue == nil || *r.MinValue >= *r.MaxValue {
+			return errors.New("voltage_outside requires min_value < max_value")
+		}
+		if 2*r.Hysteresis >= *r.MaxValue-*r.MinValue {
+			return errors.New("hysteresis must be smaller than half the voltage range")
+		}
+	default:
+		return fmt.Errorf("unknown rule kind %q", r.Kind)
+	}
+
+	if (r.Kind == RuleAvgPowerAbove || r.Kind == RuleVoltageOutside) && r.WindowMinutes == 0 {
+		r.WindowMinutes = defaultWindowMinutes
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a threshold or alert does not exist (or belongs to another user).
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for thresholds and alert events.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new alerts repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsDevice checks if a device belongs to the user.
+func (r *Repo) UserOwnsDevice(ctx context.Context, userID, deviceID int64) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM device WHERE id = $1 AND user_id = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, deviceID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetThreshold returns the thresholds of a device.
+func (r *Repo) GetThreshold(ctx context.Context, deviceID int64) (*Threshold, error) {
+	sql := `SELECT device_id, warning, danger, updated_at FROM device_threshold WHERE device_id = $1`
+	var t Threshold
+	if err := r.q.QueryRow(ctx, sql, deviceID).Scan(&t.DeviceID, &t.Warning, &t.Danger, &t.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListThresholdsByUser returns the thresholds of every device of a user.
+func (r *Repo) ListThresholdsByUser(ctx context.Context, userID int64) ([]Threshold, error) {
+	sql := `SELECT t.device_id, t.warning, t.danger, t.updated_at
+			FROM device_threshold t
+			INNER JOIN device d ON d.id = t.device_id
+			WHERE d.user_id = $1
+			ORDER BY t.device_id`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Threshold
+	for rows.Next() {
+		var t Threshold
+		if err := rows.Scan(&t.DeviceID, &t.Warning, &t.Danger, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// UpsertThreshold creates or replaces the thresholds of a device.
+func (r *Repo) UpsertThreshold(ctx context.Context, deviceID int64, warning, danger float64) (*Threshold, error) {
+	sql := `INSERT INTO device_threshold (device_id, warning, danger, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (device_id) DO UPDATE
+			SET warning = EXCLUDED.warning, danger = EXCLUDED.danger, updated_at = NOW()
+			RETURNING device_id, warning, danger, updated_at`
+	var t Threshold
+	err := r.q.QueryRow(ctx, sql, deviceID, warning, danger).Scan(&t.DeviceID, &t.Warning, &t.Danger, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteThreshold removes the thresholds of a device.
+func (r *Repo) DeleteThreshold(ctx context.Context, deviceID int64) error {
+	return r.q.Exec(ctx, `DELETE FROM device_threshold WHERE device_id = $1`, deviceID)
+}
+
+const alertColumns = `a.id, a.device_id, d.name, a.source, a.rule_id, a.level, a.state, a.message, a.value,
+			a.threshold, a.peak_value, a.opened_at, a.acknowledged_at, a.resolved_at`
+
+func scanAlert(row interface{ Scan(dest ...any) error }) (*Alert, error) {
+	var a Alert
+	err := row.Scan(&a.ID, &a.DeviceID, &a.DeviceName, &a.Source, &a.RuleID, &a.Level, &a.State, &a.Message, &a.Value,
+		&a.Threshold, &a.PeakValue, &a.OpenedAt, &a.AcknowledgedAt, &a.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ActiveAlert returns the unresolved alert of a device for a source, or ErrNotFound.
+func (r *Repo) ActiveAlert(ctx context.Context, deviceID int64, source string) (*Alert, error) {
+	sql := `SELECT ` + alertColumns + `
+			FROM alert_event a
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE a.device_id = $1 AND a.source = $2 AND a.state <> 'resolved'`
+	a, err := scanAlert(r.q.QueryRow(ctx, sql, deviceID, source))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// OpenAlert inserts a new open alert and returns its ID.
+func (r *Repo) OpenAlert(ctx context.Context, a *Alert) (int64, error) {
+	sql := `INSERT INTO alert_event (device_id, source, rule_id, level, state, message, value, threshold, peak_value, opened_at)
+			VALUES ($1, $2, $3, $4, 'open', $5, $6, $7, $6, $8) RETURNING id`
+	opened := a.OpenedAt
+	if opened.IsZero() {
+		opened = time.Now()
+	}
+	var id int64
+	err := r.q.QueryRow(ctx, sql, a.DeviceID, a.Source, a.RuleID, a.Level, a.Message, a.Value, a.Threshold, opened).Scan(&id)
+	return id, err
+}
+
+// RecordPeak raises the peak value of an active alert.
+func (r *Repo) RecordPeak(ctx context.Context, id int64, value float64) error {
+	return r.q.Exec(ctx, `UPDATE alert_event SET peak_value = GREATEST(peak_value, $2) WHERE id = $1`, id, value)
+}
+
+// Resolve marks an alert resolved.
+func (r *Repo) Resolve(ctx context.Context, id int64) error {
+	sql := `UPDATE alert_event SET state = 'resolved', resolved_at = NOW() WHERE id = $1 AND state <> 'resolved'`
+	return r.q.Exec(ctx, sql, id)
+}
+
+// GetForUser returns an alert of one of the user's devices.
+func (r *Repo) GetForUser(ctx context.Context, userID, id int64) (*Alert, error) {
+	sql := `SELECT ` + alertColumns + `
+			FROM alert_event a
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE a.id = $1 AND d.user_id = $2`
+	a, err := scanAlert(r.q.QueryRow(ctx, sql, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// ListByUser returns the alerts of a user's devices, newest first.
+func (r *Repo) ListByUser(ctx context.Context, userID int64, f ListFilter) ([]Alert, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000 // Cap at 1000 to prevent resource exhaustion
+	}
+
+	sql := `SELECT ` + alertColumns + `
+			FROM alert_event a
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE d.user_id = $1`
+	args := []any{userID}
+	if f.State != "" {
+		args = append(args, f.State)
+		sql += ` AND a.state = $` + strconv.Itoa(len(args))
+	}
+	if f.DeviceID != 0 {
+		args = append(args, f.DeviceID)
+		sql += ` AND a.device_id = $` + strconv.Itoa(len(args))
+	}
+	if f.RuleID != 0 {
+		args = append(args, f.RuleID)
+		sql += ` AND a.rule_id = $` + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	sql += ` ORDER BY a.opened_at DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+// Acknowledge moves an open alert of one of the user's devices to acknowledged.
+// It returns ErrNotFound when there is no such open alert.
+func (r *Repo) Acknowledge(ctx context.Context, userID, id int64) error {
+	sql := `UPDATE alert_event a SET state = 'acknowledged', acknowledged_at = NOW()
+			FROM device d
+			WHERE a.id = $1 AND d.id = a.device_id AND d.user_id = $2 AND a.state = 'open'
+			RETURNING a.id`
+	var updated int64
+	if err := r.q.QueryRow(ctx, sql, id, userID).Scan(&updated); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ResolveForUser resolves an unresolved alert of one of the user's devices.
+// It returns ErrNotFound when there is no such unresolved alert.
+func (r *Repo) ResolveForUser(ctx context.Context, userID, id int64) error {
+	sql := `UPDATE alert_event a SET state = 'resolved', resolved_at = NOW()
+			FROM device d
+			WHERE a.id = $1 AND d.id = a.device_id AND d.user_id = $2 AND a.state <> 'resolved'
+			RETURNING a.id`
+	var updated int64
+	if err := r.q.QueryRow(ctx, sql, id, userID).Scan(&updated); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+const ruleColumns = `id, user_id, device_id, name, kind, level, threshold, min_value, max_value,
+			window_minutes, hysteresis, cooldown_minutes, timezone, enabled, created_at, updated_at`
+
+func scanRule(row interface{ Scan(dest ...any) error }) (*Rule, error) {
+	var r Rule
+	err := row.Scan(&r.ID, &r.UserID, &r.DeviceID, &r.Name, &r.Kind, &r.Level, &r.Threshold, &r.MinValue, &r.MaxValue,
+		&r.WindowMinutes, &r.Hysteresis, &r.CooldownMinutes, &r.Timezone, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRulesByUser returns the alert rules of a user.
+func (r *Repo) ListRulesByUser(ctx context.Context, userID int64) ([]Rule, error) {
+	sql := `SELECT ` + ruleColumns + ` FROM alert_rule WHERE user_id = $1 ORDER BY id`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *rule)
+	}
+	return list, rows.Err()
+}
+
+// GetRuleForUser returns an alert rule of a user, or ErrNotFound.
+func (r *Repo) GetRuleForUser(ctx context.Context, userID, id int64) (*Rule, error) {
+	sql := `SELECT ` + ruleColumns + ` FROM alert_rule WHERE id = $1 AND user_id = $2`
+	rule, err := scanRule(r.q.QueryRow(ctx, sql, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rule, err
+}
+
+// CreateRule inserts an alert rule and returns it.
+func (r *Repo) CreateRule(ctx context.Context, userID int64, req *RuleRequest) (*Rule, error) {
+	sql := `INSERT INTO alert_rule (user_id, device_id, name, kind, level, threshold, min_value, max_value,
+				window_minutes, hysteresis, cooldown_minutes, timezone, enabled)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING ` + ruleColumns
+	return scanRule(r.q.QueryRow(ctx, sql, userID, req.DeviceID, req.Name, req.Kind, req.Level,
+		req.Threshold, req.MinValue, req.MaxValue, req.WindowMinutes, req.Hysteresis, req.CooldownMinutes,
+		req.Timezone, req.Enabled == nil || *req.Enabled))
+}
+
+// UpdateRule replaces an alert rule of a user. It returns ErrNotFound when there is no such rule.
+func (r *Repo) UpdateRule(ctx context.Context, userID, id int64, req *RuleRequest) (*Rule, error) {
+	sql := `UPDATE alert_rule SET device_id = $3, name = $4, kind = $5, level = $6, threshold = $7,
+				min_value = $8, max_value = $9, window_minutes = $10, hysteresis = $11,
+				cooldown_minutes = $12, timezone = $13, enabled = $14, updated_at = NOW()
+			WHERE id = $1 AND user_id = $2
+			RETURNING ` + ruleColumns
+	rule, err := scanRule(r.q.QueryRow(ctx, sql, id, userID, req.DeviceID, req.Name, req.Kind, req.Level,
+		req.Threshold, req.MinValue, req.MaxValue, req.WindowMinutes, req.Hysteresis, req.CooldownMinutes,
+		req.Timezone, req.Enabled == nil || *req.Enabled))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rule, err
+}
+
+// DeleteRule removes an alert rule of a user and resolves its active alert.
+// It returns ErrNotFound when there is no such rule.
+func (r *Repo) DeleteRule(ctx context.Context, userID, id int64) error {
+	if err := r.ResolveRuleAlerts(ctx, userID, id); err != nil {
+		return err
+	}
+	sql := `DELETE FROM alert_rule WHERE id = $1 AND user_id = $2 RETURNING id`
+	var deleted int64
+	if err := r.q.QueryRow(ctx, sql, id, userID).Scan(&deleted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ResolveRuleAlerts resolves the unresolved alert of a user's rule, if any.
+func (r *Repo) ResolveRuleAlerts(ctx context.Context, userID, ruleID int64) error {
+	sql := `UPDATE alert_event SET state = 'resolved', resolved_at = NOW()
+			WHERE rule_id = $1 AND state <> 'resolved'
+				AND rule_id IN (SELECT id FROM alert_rule WHERE user_id = $2)`
+	return r.q.Exec(ctx, sql, ruleID, userID)
+}
+
+// ListEnabledRules returns every enabled rule with the last time its device reported.
+func (r *Repo) ListEnabledRules(ctx context.Context) ([]DueRule, error) {
+	sql := `SELECT r.id, r.user_id, r.device_id, r.name, r.kind, r.level, r.threshold, r.min_value, r.max_value,
+				r.window_minutes, r.hysteresis, r.cooldown_minutes, r.timezone, r.enabled, r.created_at, r.updated_at,
+				d.last_seen
+			FROM alert_rule r
+			INNER JOIN device d ON d.id = r.device_id AND d.user_id = r.user_id
+			WHERE r.enabled
+			ORDER BY r.id`
+	rows, err := r.q.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []DueRule
+	for rows.Next() {
+		var d DueRule
+		err := rows.Scan(&d.ID, &d.UserID, &d.DeviceID, &d.Name, &d.Kind, &d.Level, &d.Threshold, &d.MinValue, &d.MaxValue,
+			&d.WindowMinutes, &d.Hysteresis, &d.CooldownMinutes, &d.Timezone, &d.Enabled, &d.CreatedAt, &d.UpdatedAt,
+			&d.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// ActiveRuleAlert returns the unresolved alert of a rule, or ErrNotFound.
+func (r *Repo) ActiveRuleAlert(ctx context.Context, ruleID int64) (*Alert, error) {
+	sql := `SELECT ` + alertColumns + `
+			FROM alert_event a
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE a.rule_id = $1 AND a.state <> 'resolved'`
+	a, err := scanAlert(r.q.QueryRow(ctx, sql, ruleID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// LastRuleResolution returns when the latest alert of a rule was resolved (nil if never).
+func (r *Repo) LastRuleResolution(ctx context.Context, ruleID int64) (*time.Time, error) {
+	var at *time.Time
+	err := r.q.QueryRow(ctx, `SELECT MAX(resolved_at) FROM alert_event WHERE rule_id = $1`, ruleID).Scan(&at)
+	return at, err
+}
@@ -0,0 +1,255 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// TelemetryStats aggregates recorded readings (satisfied by telemetry.Repo).
+type TelemetryStats interface {
+	WindowStats(ctx context.Context, deviceID int64, since time.Time) (*telemetry.WindowStats, error)
+	EnergySince(ctx context.Context, deviceID int64, since time.Time) (float64, error)
+}
+
+// DueRule is an enabled rule together with the last time its device reported.
+type DueRule struct {
+	Rule
+	LastSeen *time.Time
+}
+
+// EngineConfig controls how often rules are evaluated.
+type EngineConfig struct {
+	Interval time.Duration
+}
+
+// EngineConfigFromEnv reads ALERT_RULES_INTERVAL_SEC.
+// The engine is disabled when it is 0.
+func EngineConfigFromEnv() EngineConfig {
+	cfg := EngineConfig{Interval: time.Minute}
+	if v := os.Getenv("ALERT_RULES_INTERVAL_SEC"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+			cfg.Interval = time.Duration(sec) * time.Second
+		}
+	}
+	return cfg
+}
+
+// Enabled reports whether the engine should run.
+func (c EngineConfig) Enabled() bool {
+	return c.Interval > 0
+}
+
+// Engine periodically evaluates alert rules against recorded telemetry and
+// keeps at most one active alert per rule.
+type Engine struct {
+	cfg    EngineConfig
+	Repo   *Repo
+	Stats  TelemetryStats
+	Events realtime.Publisher // nil when nobody listens for live updates
+	Notify Notifier           // nil when notifications are off
+
+	done chan struct{}
+}
+
+// NewEngine creates a rules engine; call Start to run it.
+func NewEngine(cfg EngineConfig, repo *Repo, stats TelemetryStats, events realtime.Publisher, notify Notifier) *Engine {
+	return &Engine{cfg: cfg, Repo: repo, Stats: stats, Events: events, Notify: notify, done: make(chan struct{})}
+}
+
+// Start evaluates the rules every interval until ctx is cancelled.
+func (e *Engine) Start(ctx context.Context) {
+	go e.run(ctx)
+}
+
+// Wait blocks until the engine has stopped.
+func (e *Engine) Wait() {
+	<-e.done
+}
+
+func (e *Engine) run(ctx context.Context) {
+	defer close(e.done)
+
+	tick := time.NewTicker(e.cfg.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			e.EvaluateAll(ctx, now)
+		}
+	}
+}
+
+// EvaluateAll evaluates every enabled rule once.
+func (e *Engine) EvaluateAll(ctx context.Context, now time.Time) {
+	rules, err := e.Repo.ListEnabledRules(ctx)
+	if err != nil {
+		log.Printf("Alert rules: failed to list rules: %v", err)
+		return
+	}
+	for _, r := range rules {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := e.evaluate(ctx, r, now); err != nil {
+			log.Printf("Alert rules: failed to evaluate rule %d: %v", r.ID, err)
+		}
+	}
+}
+
+// observation is the outcome of measuring a rule.
+type observation struct {
+	value    float64
+	limit    float64 // limit crossed (or nearest one) for the alert record
+	breached bool    // the rule fires
+	cleared  bool    // the value is back inside the limit by the hysteresis margin
+	message  string
+}
+
+func (e *Engine) evaluate(ctx context.Context, r DueRule, now time.Time) error {
+	obs, ok, err := e.observe(ctx, r, now)
+	if err != nil || !ok {
+		return err // no data: keep the current state
+	}
+
+	active, err := e.Repo.ActiveRuleAlert(ctx, r.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if active != nil {
+		switch {
+		case obs.cleared:
+			if err := e.Repo.Resolve(ctx, active.ID); err != nil {
+				return err
+			}
+			active.State = StateResolved
+			active.ResolvedAt = &now
+			e.publish(ctx, r.UserID, active)
+		case obs.breached && obs.value > active.PeakValue:
+			return e.Repo.RecordPeak(ctx, active.ID, obs.value)
+		}
+		return nil
+	}
+	if !obs.breached {
+		return nil
+	}
+
+	if r.CooldownMinutes > 0 {
+		last, err := e.Repo.LastRuleResolution(ctx, r.ID)
+		if err != nil {
+			return err
+		}
+		if last != nil && now.Sub(*last) < time.Duration(r.CooldownMinutes)*time.Minute {
+			return nil
+		}
+	}
+
+	ruleID := r.ID
+	a := &Alert{
+		DeviceID:  r.DeviceID,
+		Source:    SourceRule,
+		RuleID:    &ruleID,
+		Level:     r.Level,
+		State:     StateOpen,
+		Message:   fmt.Sprintf("%s: %s", r.Name, obs.message),
+		Value:     obs.value,
+		Threshold: obs.limit,
+		PeakValue: obs.value,
+		OpenedAt:  now,
+	}
+	id, err := e.Repo.OpenAlert(ctx, a)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	e.publish(ctx, r.UserID, a)
+	return nil
+}
+
+// observe measures the value a rule watches. ok is false when there is no data to judge.
+func (e *Engine) observe(ctx context.Context, r DueRule, now time.Time) (observation, bool, error) {
+	window := now.Add(-time.Duration(r.WindowMinutes) * time.Minute)
+
+	switch r.Kind {
+	case RuleAvgPowerAbove:
+		s, err := e.Stats.WindowStats(ctx, r.DeviceID, window)
+		if err != nil || s.AvgPower == nil {
+			return observation{}, false, err
+		}
+		obs := above(*s.AvgPower, *r.Threshold, r.Hysteresis)
+		obs.message = fmt.Sprintf("average power %.1f W over %d min is above %.1f W", obs.value, r.WindowMinutes, obs.limit)
+		return obs, true, nil
+
+	case RuleOffline:
+		ref := r.CreatedAt
+		if r.LastSeen != nil {
+			ref = *r.LastSeen
+		}
+		obs := above(now.Sub(ref).Minutes(), *r.Threshold, r.Hysteresis)
+		obs.message = fmt.Sprintf("device has not reported for %.0f min", obs.value)
+		return obs, true, nil
+
+	case RuleDailyEnergyAbove:
+		loc, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return observation{}, false, err
+		}
+		y, m, d := now.In(loc).Date()
+		kwh, err := e.Stats.EnergySince(ctx, r.DeviceID, time.Date(y, m, d, 0, 0, 0, 0, loc))
+		if err != nil {
+			return observation{}, false, err
+		}
+		obs := above(kwh, *r.Threshold, r.Hysteresis)
+		obs.message = fmt.Sprintf("energy today %.2f kWh is above the %.2f kWh budget", obs.value, obs.limit)
+		return obs, true, nil
+
+	case RuleVoltageOutside:
+		s, err := e.Stats.WindowStats(ctx, r.DeviceID, window)
+		if err != nil || s.AvgVoltage == nil {
+			return observation{}, false, err
+		}
+		v, lo, hi := *s.AvgVoltage, *r.MinValue, *r.MaxValue
+		obs := observation{
+			value:    v,
+			limit:    hi,
+			breached: v < lo || v > hi,
+			cleared:  v >= lo+r.Hysteresis && v <= hi-r.Hysteresis,
+		}
+		if v < lo {
+			obs.limit = lo
+		}
+		obs.message = fmt.Sprintf("average voltage %.1f V over %d min is outside %.1f-%.1f V", v, r.WindowMinutes, lo, hi)
+		return obs, true, nil
+	}
+	return observation{}, false, fmt.Errorf("unknown rule kind %q", r.Kind)
+}
+
+// above builds the observation of a rule that fires when value exceeds limit.
+func above(value, limit, hysteresis float64) observation {
+	return observation{
+		value:    value,
+		limit:    limit,
+		breached: value > limit,
+		cleared:  value < limit-hysteresis,
+	}
+}
+
+func (e *Engine) publish(ctx context.Context, userID int64, a *Alert) {
+	if e.Events != nil {
+		e.Events.Publish(realtime.AlertEvent(userID, a.DeviceID, a))
+	}
+	if e.Notify != nil {
+		e.Notify.AlertChanged(ctx, userID, a)
+	}
+}
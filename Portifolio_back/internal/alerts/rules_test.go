@@ -0,0 +1,50 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// fakeStats records the start of the energy sum it is asked for.
+type fakeStats struct{ since time.Time }
+
+func (f *fakeStats) WindowStats(context.Context, int64, time.Time) (*telemetry.WindowStats, error) {
+	return &telemetry.WindowStats{}, nil
+}
+
+func (f *fakeStats) EnergySince(_ context.Context, _ int64, since time.Time) (float64, error) {
+	f.since = since
+	return 1, nil
+}
+
+func TestDailyEnergyUsesRuleTimezone(t *testing.T) {
+	// 01:30 UTC is still the previous evening in São Paulo (UTC-3)
+	now := time.Date(2026, 3, 10, 1, 30, 0, 0, time.UTC)
+	threshold := 5.0
+
+	tests := []struct {
+		timezone string
+		want     time.Time
+	}{
+		{"America/Sao_Paulo", time.Date(2026, 3, 9, 3, 0, 0, 0, time.UTC)},
+		{"UTC", time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"Asia/Tokyo", time.Date(2026, 3, 9, 15, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			stats := &fakeStats{}
+			e := &Engine{Stats: stats}
+			r := DueRule{Rule: Rule{DeviceID: 1, Kind: RuleDailyEnergyAbove, Threshold: &threshold, Timezone: tt.timezone}}
+
+			if _, _, err := e.observe(context.Background(), r, now); err != nil {
+				t.Fatal(err)
+			}
+			if !stats.since.Equal(tt.want) {
+				t.Errorf("energy summed since %v, want %v", stats.since.UTC(), tt.want)
+			}
+		})
+	}
+}
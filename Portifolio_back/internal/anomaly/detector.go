@@ -0,0 +1,197 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+)
+
+const (
+	minReadings    = 3   // readings an hour needs before it is judged and learnt
+	minDaySamples  = 3   // weeks of history before a day-of-week slot is trusted
+	minHourSamples = 7   // days of history before the any-day slot is trusted
+	memory         = 8   // samples after which a slot starts forgetting the oldest
+	relativeFloor  = 0.1 // smallest spread, as a share of the mean...
+	absoluteFloor  = 1.0 // ...and in Watts, so steady devices do not flag noise
+)
+
+// Detector learns the usual hourly power of each device by hour of day and day
+// of week and flags hours that deviate from it. It works incrementally: each
+// reading is added to its device's current hour, and the first reading of a
+// later hour closes the previous one, which is compared with the baseline and
+// then folded into it. The first reading of a device seeds its baseline from
+// the telemetry recorded before.
+type Detector struct {
+	Repo   *Repo
+	Alerts *alerts.Repo
+	Events realtime.Publisher // nil when nobody listens for live updates
+	Notify alerts.Notifier    // nil when notifications are off
+}
+
+// NewDetector creates an anomaly detector.
+func NewDetector(repo *Repo, alertsRepo *alerts.Repo, events realtime.Publisher, notify alerts.Notifier) *Detector {
+	return &Detector{Repo: repo, Alerts: alertsRepo, Events: events, Notify: notify}
+}
+
+// Observe adds one power reading (Watts) of a device owned by userID. Readings
+// of a device must not be observed concurrently (alerts.Evaluator serialises
+// them); readings older than the current hour are ignored.
+func (d *Detector) Observe(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error {
+	st, err := d.Repo.state(ctx, deviceID)
+	if err != nil || !st.Enabled {
+		return err
+	}
+	loc, err := time.LoadLocation(st.Timezone)
+	if err != nil {
+		return err
+	}
+	l := at.In(loc)
+	hour := time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), 0, 0, 0, loc)
+
+	// Learn the history recorded before detection started once, up to the
+	// hour being accumulated
+	if !st.Backfilled {
+		before := hour
+		if st.HourStart != nil {
+			before = *st.HourStart
+		}
+		if err := d.Repo.backfill(ctx, deviceID, loc, before); err != nil {
+			return err
+		}
+	}
+
+	if st.HourStart != nil {
+		switch {
+		case hour.Equal(*st.HourStart):
+			return d.Repo.saveHour(ctx, deviceID, hour, st.Readings+1, st.PowerSum+power)
+		case hour.Before(*st.HourStart):
+			return nil
+		}
+	}
+	// A new hour: start it before closing the previous one, so a failure
+	// cannot make the previous hour be judged twice
+	if err := d.Repo.saveHour(ctx, deviceID, hour, 1, power); err != nil {
+		return err
+	}
+	if st.HourStart == nil || st.Readings < minReadings {
+		return nil
+	}
+	return d.closeHour(ctx, userID, deviceID, st, st.HourStart.In(loc), st.PowerSum/float64(st.Readings))
+}
+
+// closeHour judges the average power of a finished hour and learns it.
+func (d *Detector) closeHour(ctx context.Context, userID, deviceID int64, st *state, hour time.Time, avg float64) error {
+	day, h := int(hour.Weekday()), hour.Hour()
+	slots, err := d.Repo.slots(ctx, deviceID, day, h)
+	if err != nil {
+		return err
+	}
+	if ref := reference(slots, day); ref != nil {
+		if err := d.judge(ctx, userID, deviceID, st.ZScore, hour, avg, ref); err != nil {
+			return err
+		}
+	}
+	return d.Repo.learn(ctx, deviceID, day, h, avg)
+}
+
+// reference picks the slot an hour is compared with: the same hour on the same
+// day of the week once it has enough history, otherwise the same hour on any day.
+func reference(slots []Slot, day int) *Slot {
+	var anyDay *Slot
+	for i := range slots {
+		s := &slots[i]
+		switch {
+		case s.Day == day && s.Samples >= minDaySamples:
+			return s
+		case s.Day == AnyDay && s.Samples >= minHourSamples:
+			anyDay = s
+		}
+	}
+	return anyDay
+}
+
+// judge records the hour as an anomaly when it is z-score spreads or more away
+// from the reference, and keeps the device's anomaly alert in step: opened (or
+// its peak raised) on an anomalous hour, resolved on a normal one.
+func (d *Detector) judge(ctx context.Context, userID, deviceID int64, zScore float64, hour time.Time, avg float64, ref *Slot) error {
+	spread := math.Max(ref.StdDev, math.Max(relativeFloor*ref.Mean, absoluteFloor))
+	z := (avg - ref.Mean) / spread
+
+	active, err := d.Alerts.ActiveAlert(ctx, deviceID, alerts.SourceAnomaly)
+	if err != nil && !errors.Is(err, alerts.ErrNotFound) {
+		return err
+	}
+	if math.Abs(z) < zScore {
+		if active == nil {
+			return nil
+		}
+		if err := d.Alerts.Resolve(ctx, active.ID); err != nil {
+			return err
+		}
+		active.State = alerts.StateResolved
+		resolvedAt := time.Now()
+		active.ResolvedAt = &resolvedAt
+		d.publish(ctx, userID, active)
+		return nil
+	}
+
+	a := &Anomaly{DeviceID: deviceID, HourStart: hour, Observed: avg, Expected: ref.Mean, StdDev: spread, ZScore: z}
+	if _, err := d.Repo.insert(ctx, a); err != nil {
+		return err
+	}
+	if active != nil {
+		return d.Alerts.RecordPeak(ctx, active.ID, avg)
+	}
+
+	level, limit, direction := alerts.LevelWarning, ref.Mean+zScore*spread, "above"
+	if math.Abs(z) >= 2*zScore {
+		level = alerts.LevelDanger
+	}
+	if z < 0 {
+		limit, direction = ref.Mean-zScore*spread, "below"
+	}
+	if c := change(avg, ref.Mean); c != nil {
+		direction = fmt.Sprintf("%.0f%% %s", math.Abs(*c), direction)
+	}
+	alert := &alerts.Alert{
+		DeviceID: deviceID,
+		Source:   alerts.SourceAnomaly,
+		Level:    level,
+		State:    alerts.StateOpen,
+		Message: fmt.Sprintf("Average power %.1f W from %s is %s the usual %.1f W for %s (z = %.1f)",
+			avg, hour.Format("Mon 15:04"), direction, ref.Mean, hourLabel(ref, hour), z),
+		Value:     avg,
+		Threshold: limit,
+		PeakValue: avg,
+		OpenedAt:  time.Now(),
+	}
+	id, err := d.Alerts.OpenAlert(ctx, alert)
+	if err != nil {
+		return err
+	}
+	alert.ID = id
+	d.publish(ctx, userID, alert)
+	return nil
+}
+
+// hourLabel names the baseline slot, e.g. "Mondays at 14h" or "14h".
+func hourLabel(ref *Slot, hour time.Time) string {
+	if ref.Day == AnyDay {
+		return fmt.Sprintf("%02dh", ref.Hour)
+	}
+	return fmt.Sprintf("%ss at %02dh", hour.Weekday(), ref.Hour)
+}
+
+func (d *Detector) publish(ctx context.Context, userID int64, a *alerts.Alert) {
+	if d.Events != nil {
+		d.Events.Publish(realtime.AlertEvent(userID, a.DeviceID, a))
+	}
+	if d.Notify != nil {
+		d.Notify.AlertChanged(ctx, userID, a)
+	}
+}
@@ -0,0 +1,177 @@
+package anomaly
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles anomaly detection HTTP requests.
+type Handler struct {
+	Repo *Repo
+}
+
+// NewHandler creates a new anomaly handler.
+func NewHandler(repo *Repo) *Handler {
+	return &Handler{Repo: repo}
+}
+
+// RegisterRoutes registers anomaly detection routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	a := r.Group("/anomalies")
+	a.GET("", h.List)
+	a.GET("/devices", h.ListSettings)
+	a.PUT("/devices/:deviceId", h.SetSettings)
+	a.GET("/devices/:deviceId/baseline", h.GetBaseline)
+	a.DELETE("/devices/:deviceId/baseline", h.ResetBaseline)
+}
+
+// List returns the anomalous hours of the user's devices, newest first.
+// Query params: device_id, limit (default 100, max 500)
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		deviceID = id
+	}
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = min(parsed, 500)
+		}
+	}
+
+	list, err := h.Repo.List(c.Request.Context(), userID, deviceID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch anomalies"})
+		return
+	}
+	if list == nil {
+		list = []Anomaly{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ListSettings returns the anomaly detection setting of every device.
+func (h *Handler) ListSettings(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.Repo.ListSettings(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch anomaly settings"})
+		return
+	}
+	if list == nil {
+		list = []Settings{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// SetSettings enables or disables detection on a device and sets its z-score.
+func (h *Handler) SetSettings(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	var req SettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s, err := h.Repo.SetSettings(c.Request.Context(), deviceID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save anomaly settings"})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// GetBaseline returns the learnt hourly profile of a device.
+func (h *Handler) GetBaseline(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.Repo.Baseline(c.Request.Context(), deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch baseline"})
+		return
+	}
+	if list == nil {
+		list = []Slot{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ResetBaseline makes a device learn its profile from scratch.
+func (h *Handler) ResetBaseline(c *gin.Context) {
+	deviceID, ok := h.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.ResetBaseline(c.Request.Context(), deviceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset baseline"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ownedDevice reads the :deviceId path parameter and checks the user owns it,
+// writing the error response itself when not.
+func (h *Handler) ownedDevice(c *gin.Context) (int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, false
+	}
+	deviceID, err := strconv.ParseInt(c.Param("deviceId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return 0, false
+	}
+	owns, err := h.Repo.UserOwnsDevice(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return 0, false
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return 0, false
+	}
+	return deviceID, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
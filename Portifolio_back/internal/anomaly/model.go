@@ -0,0 +1,85 @@
+package anomaly
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults for devices without a setting.
+const (
+	DefaultZScore   = 3.0
+	defaultTimezone = "America/Sao_Paulo"
+)
+
+// AnyDay is the baseline day that pools every day of the week.
+const AnyDay = 7
+
+// Settings is the anomaly detection setting of a device.
+type Settings struct {
+	DeviceID   int64      `json:"device_id"`
+	DeviceName string     `json:"device_name"`
+	Enabled    bool       `json:"enabled"`
+	ZScore     float64    `json:"z_score"`
+	Timezone   string     `json:"timezone"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"` // nil while the defaults are in use
+}
+
+// SettingsRequest is the payload for setting a device's anomaly detection.
+type SettingsRequest struct {
+	Enabled  *bool   `json:"enabled"`
+	ZScore   float64 `json:"z_score"`
+	Timezone string  `json:"timezone"`
+}
+
+// Validate checks the z-score and the timezone and fills defaults.
+func (r *SettingsRequest) Validate() error {
+	if r.ZScore == 0 {
+		r.ZScore = DefaultZScore
+	}
+	if r.ZScore < 1 || r.ZScore > 10 {
+		return errors.New("z_score must be between 1 and 10")
+	}
+	if r.Timezone == "" {
+		r.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	return nil
+}
+
+// Slot is the usual hourly average power (Watts) of a device at one hour of
+// one day of the week (0 = Sunday, AnyDay = all days).
+type Slot struct {
+	Day     int     `json:"day"`
+	Hour    int     `json:"hour"`
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"std_dev"`
+}
+
+// Anomaly is an hour whose average power deviated from the device's baseline.
+type Anomaly struct {
+	ID         int64     `json:"id"`
+	DeviceID   int64     `json:"device_id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	HourStart  time.Time `json:"hour_start"`
+	Observed   float64   `json:"observed"` // average power of the hour (W)
+	Expected   float64   `json:"expected"` // baseline mean (W)
+	StdDev     float64   `json:"std_dev"`  // spread used for the z-score (W)
+	ZScore     float64   `json:"z_score"`
+	Change     *float64  `json:"change,omitempty"` // relative to expected, %
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// state is a device's setting with the hour being accumulated.
+type state struct {
+	Enabled    bool
+	ZScore     float64
+	Timezone   string
+	HourStart  *time.Time
+	Readings   int
+	PowerSum   float64
+	Backfilled bool // baseline seeded from earlier telemetry
+}
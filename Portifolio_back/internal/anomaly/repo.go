@@ -0,0 +1,317 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for anomaly detection.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new anomaly repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsDevice checks if the device belongs to the user.
+func (r *Repo) UserOwnsDevice(ctx context.Context, userID, deviceID int64) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM device WHERE id = $1 AND user_id = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, deviceID, userID).Scan(&exists)
+	return exists, err
+}
+
+const settingsColumns = `d.id, d.name, COALESCE(s.enabled, TRUE), COALESCE(s.z_score, $2), COALESCE(s.timezone, $3), s.updated_at`
+
+func scanSettings(row interface{ Scan(dest ...any) error }) (*Settings, error) {
+	var s Settings
+	if err := row.Scan(&s.DeviceID, &s.DeviceName, &s.Enabled, &s.ZScore, &s.Timezone, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSettings returns the setting of every device of the user.
+func (r *Repo) ListSettings(ctx context.Context, userID int64) ([]Settings, error) {
+	sql := `SELECT ` + settingsColumns + `
+			FROM device d
+			LEFT JOIN device_anomaly s ON s.device_id = d.id
+			WHERE d.user_id = $1
+			ORDER BY d.id`
+	rows, err := r.q.Query(ctx, sql, userID, DefaultZScore, defaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Settings
+	for rows.Next() {
+		s, err := scanSettings(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+// SetSettings creates or replaces a device's setting. The hour being
+// accumulated is kept unless the timezone changes.
+func (r *Repo) SetSettings(ctx context.Context, deviceID int64, req *SettingsRequest) (*Settings, error) {
+	sql := `WITH saved AS (
+				INSERT INTO device_anomaly (device_id, enabled, z_score, timezone) VALUES ($1, $4, $5, $6)
+				ON CONFLICT (device_id) DO UPDATE
+				SET enabled = EXCLUDED.enabled, z_score = EXCLUDED.z_score, timezone = EXCLUDED.timezone,
+					hour_start = CASE WHEN device_anomaly.timezone = EXCLUDED.timezone THEN device_anomaly.hour_start END,
+					updated_at = NOW()
+				RETURNING *
+			)
+			SELECT ` + settingsColumns + ` FROM saved s JOIN device d ON d.id = s.device_id`
+	return scanSettings(r.q.QueryRow(ctx, sql, deviceID, DefaultZScore, defaultTimezone,
+		req.Enabled == nil || *req.Enabled, req.ZScore, req.Timezone))
+}
+
+// state returns a device's setting and current hour (the defaults without a row).
+func (r *Repo) state(ctx context.Context, deviceID int64) (*state, error) {
+	sql := `SELECT enabled, z_score, timezone, hour_start, readings, power_sum, backfilled
+			FROM device_anomaly WHERE device_id = $1`
+	s := state{}
+	err := r.q.QueryRow(ctx, sql, deviceID).Scan(&s.Enabled, &s.ZScore, &s.Timezone, &s.HourStart, &s.Readings, &s.PowerSum,
+		&s.Backfilled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &state{Enabled: true, ZScore: DefaultZScore, Timezone: defaultTimezone}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// saveHour stores the hour being accumulated for a device.
+func (r *Repo) saveHour(ctx context.Context, deviceID int64, hour time.Time, readings int, powerSum float64) error {
+	sql := `INSERT INTO device_anomaly (device_id, hour_start, readings, power_sum) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (device_id) DO UPDATE
+			SET hour_start = EXCLUDED.hour_start, readings = EXCLUDED.readings, power_sum = EXCLUDED.power_sum`
+	return r.q.Exec(ctx, sql, deviceID, hour, readings, powerSum)
+}
+
+// slots returns the baseline of a device for an hour of one day and of any day.
+func (r *Repo) slots(ctx context.Context, deviceID int64, day, hour int) ([]Slot, error) {
+	sql := `SELECT day, hour, samples, mean, variance FROM anomaly_baseline
+			WHERE device_id = $1 AND day IN ($2, $4) AND hour = $3`
+	return r.listSlots(ctx, sql, deviceID, day, hour, AnyDay)
+}
+
+// Baseline returns every baseline slot of a device, by day and hour.
+func (r *Repo) Baseline(ctx context.Context, deviceID int64) ([]Slot, error) {
+	sql := `SELECT day, hour, samples, mean, variance FROM anomaly_baseline
+			WHERE device_id = $1 ORDER BY day, hour`
+	return r.listSlots(ctx, sql, deviceID)
+}
+
+func (r *Repo) listSlots(ctx context.Context, sql string, args ...any) ([]Slot, error) {
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Slot
+	for rows.Next() {
+		var s Slot
+		var variance float64
+		if err := rows.Scan(&s.Day, &s.Hour, &s.Samples, &s.Mean, &variance); err != nil {
+			return nil, err
+		}
+		s.StdDev = math.Sqrt(variance)
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// learn folds an hourly average into the day's and the any-day slots. Each
+// slot is a running mean and variance until it holds memory samples and an
+// exponentially weighted one from then on, so it follows slow changes.
+func (r *Repo) learn(ctx context.Context, deviceID int64, day, hour int, power float64) error {
+	sql := `INSERT INTO anomaly_baseline AS b (device_id, day, hour, samples, mean, variance)
+			VALUES ($1, $2, $3, 1, $4, 0), ($1, $5, $3, 1, $4, 0)
+			ON CONFLICT (device_id, day, hour) DO UPDATE
+			SET samples = b.samples + 1,
+				mean = b.mean + ($4 - b.mean) / LEAST(b.samples + 1, $6),
+				variance = (1 - 1.0 / LEAST(b.samples + 1, $6)) * (b.variance + ($4 - b.mean) ^ 2 / LEAST(b.samples + 1, $6))`
+	return r.q.Exec(ctx, sql, deviceID, day, hour, power, AnyDay, memory)
+}
+
+// backfill seeds the baseline of a device from the hourly averages of the
+// telemetry recorded before the given hour, learnt in order as learn would
+// have, and marks the device as backfilled. A device that already has a
+// baseline keeps it. Only the last memory weeks are read: older hours would
+// carry little weight.
+func (r *Repo) backfill(ctx context.Context, deviceID int64, loc *time.Location, before time.Time) error {
+	sql := `SELECT date_trunc('hour', timestamp, $2), AVG(power)
+			FROM telemetry
+			WHERE device_id = $1 AND timestamp >= $3 AND timestamp < $4
+			GROUP BY 1
+			HAVING COUNT(*) >= $5
+			ORDER BY 1`
+	rows, err := r.q.Query(ctx, sql, deviceID, loc.String(), before.AddDate(0, 0, -7*memory), before, minReadings)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var b seed
+	for rows.Next() {
+		var hour time.Time
+		var avg float64
+		if err := rows.Scan(&hour, &avg); err != nil {
+			return err
+		}
+		b.learn(hour.In(loc), avg)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	days, hours, samples, means, variances := b.columns()
+	sql = `WITH marked AS (
+				INSERT INTO device_anomaly (device_id, backfilled) VALUES ($1, TRUE)
+				ON CONFLICT (device_id) DO UPDATE SET backfilled = TRUE
+			)
+			INSERT INTO anomaly_baseline (device_id, day, hour, samples, mean, variance)
+			SELECT $1, x.* FROM unnest($2::SMALLINT[], $3::SMALLINT[], $4::INT[], $5::DOUBLE PRECISION[], $6::DOUBLE PRECISION[])
+				AS x(day, hour, samples, mean, variance)
+			WHERE NOT EXISTS (SELECT 1 FROM anomaly_baseline WHERE device_id = $1)`
+	return r.q.Exec(ctx, sql, deviceID, days, hours, samples, means, variances)
+}
+
+// seed accumulates baseline slots in memory with the update of learn.
+type seed struct {
+	order []slotKey
+	slots map[slotKey]*seedSlot
+}
+
+type slotKey struct{ day, hour int }
+
+type seedSlot struct {
+	samples        int
+	mean, variance float64
+}
+
+// learn folds the average power of an hour (in the device's timezone) into the
+// day's and the any-day slots.
+func (b *seed) learn(hour time.Time, power float64) {
+	if b.slots == nil {
+		b.slots = make(map[slotKey]*seedSlot)
+	}
+	for _, k := range []slotKey{{int(hour.Weekday()), hour.Hour()}, {AnyDay, hour.Hour()}} {
+		s, ok := b.slots[k]
+		if !ok {
+			s = &seedSlot{}
+			b.slots[k] = s
+			b.order = append(b.order, k)
+		}
+		n := float64(min(s.samples+1, memory))
+		d := power - s.mean
+		s.samples++
+		s.mean += d / n
+		s.variance = (1 - 1/n) * (s.variance + d*d/n)
+	}
+}
+
+// columns returns the slots as the column arrays of anomaly_baseline.
+func (b *seed) columns() (days, hours []int16, samples []int32, means, variances []float64) {
+	for _, k := range b.order {
+		s := b.slots[k]
+		days = append(days, int16(k.day))
+		hours = append(hours, int16(k.hour))
+		samples = append(samples, int32(s.samples))
+		means = append(means, s.mean)
+		variances = append(variances, s.variance)
+	}
+	return days, hours, samples, means, variances
+}
+
+// ResetBaseline forgets what was learnt about a device, e.g. after the
+// appliance plugged into it changed. Earlier telemetry is not learnt again.
+func (r *Repo) ResetBaseline(ctx context.Context, deviceID int64) error {
+	sql := `WITH cleared AS (
+				INSERT INTO device_anomaly (device_id, backfilled) VALUES ($1, TRUE)
+				ON CONFLICT (device_id) DO UPDATE
+				SET hour_start = NULL, readings = 0, power_sum = 0, backfilled = TRUE
+			)
+			DELETE FROM anomaly_baseline WHERE device_id = $1`
+	return r.q.Exec(ctx, sql, deviceID)
+}
+
+// insert records an anomalous hour and returns its ID.
+func (r *Repo) insert(ctx context.Context, a *Anomaly) (int64, error) {
+	sql := `INSERT INTO anomaly (device_id, hour_start, observed, expected, std_dev, z_score)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	var id int64
+	err := r.q.QueryRow(ctx, sql, a.DeviceID, a.HourStart, a.Observed, a.Expected, a.StdDev, a.ZScore).Scan(&id)
+	return id, err
+}
+
+// List returns the anomalies of the user's devices, newest first.
+// A deviceID of 0 lists every device.
+func (r *Repo) List(ctx context.Context, userID, deviceID int64, limit int) ([]Anomaly, error) {
+	sql := `SELECT a.id, a.device_id, d.name, a.hour_start, a.observed, a.expected, a.std_dev, a.z_score, a.created_at
+			FROM anomaly a
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE d.user_id = $1 AND ($2 = 0 OR a.device_id = $2)
+			ORDER BY a.hour_start DESC, a.id DESC
+			LIMIT $3`
+	rows, err := r.q.Query(ctx, sql, userID, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Anomaly
+	for rows.Next() {
+		var a Anomaly
+		if err := rows.Scan(&a.ID, &a.DeviceID, &a.DeviceName, &a.HourStart, &a.Observed, &a.Expected, &a.StdDev,
+			&a.ZScore, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Change = change(a.Observed, a.Expected)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// change returns how far observed is from expected, in percent of expected.
+func change(observed, expected float64) *float64 {
+	if expected <= 0 {
+		return nil
+	}
+	c := (observed - expected) / expected * 100
+	return &c
+}
@@ -0,0 +1,133 @@
+package anomaly
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hourlyDB answers the telemetry query of backfill with fixed hourly averages
+// and records the baseline it is asked to insert.
+type hourlyDB struct {
+	hours []hourAvg
+	query []any
+	exec  []any
+}
+
+type hourAvg struct {
+	hour  time.Time
+	power float64
+}
+
+func (db *hourlyDB) Exec(_ context.Context, _ string, args ...any) error {
+	db.exec = args
+	return nil
+}
+
+func (db *hourlyDB) QueryRow(_ context.Context, sql string, _ ...any) interface{ Scan(dest ...any) error } {
+	panic("unexpected query: " + sql)
+}
+
+func (db *hourlyDB) Query(_ context.Context, sql string, args ...any) (Rows, error) {
+	if !strings.Contains(sql, "FROM telemetry") {
+		panic("unexpected query: " + sql)
+	}
+	db.query = args
+	return &hourRows{list: db.hours}, nil
+}
+
+type hourRows struct {
+	list []hourAvg
+	i    int
+}
+
+func (r *hourRows) Next() bool { r.i++; return r.i <= len(r.list) }
+func (r *hourRows) Close()     {}
+func (r *hourRows) Err() error { return nil }
+
+func (r *hourRows) Scan(dest ...any) error {
+	*dest[0].(*time.Time) = r.list[r.i-1].hour
+	*dest[1].(*float64) = r.list[r.i-1].power
+	return nil
+}
+
+func TestBackfill(t *testing.T) {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mondays 14h over three weeks, then one Tuesday 14h; returned in UTC
+	monday := time.Date(2026, 3, 2, 14, 0, 0, 0, loc)
+	db := &hourlyDB{hours: []hourAvg{
+		{monday.UTC(), 100},
+		{monday.AddDate(0, 0, 7).UTC(), 110},
+		{monday.AddDate(0, 0, 14).UTC(), 120},
+		{monday.AddDate(0, 0, 15).UTC(), 50},
+	}}
+	before := monday.AddDate(0, 0, 16)
+
+	if err := NewRepo(db).backfill(context.Background(), 9, loc, before); err != nil {
+		t.Fatal(err)
+	}
+	if db.query[1] != "America/Sao_Paulo" || !db.query[2].(time.Time).Equal(before.AddDate(0, 0, -7*memory)) ||
+		!db.query[3].(time.Time).Equal(before) {
+		t.Errorf("telemetry query args = %v", db.query)
+	}
+
+	days, hours, samples := db.exec[1].([]int16), db.exec[2].([]int16), db.exec[3].([]int32)
+	means, variances := db.exec[4].([]float64), db.exec[5].([]float64)
+	got := map[slotKey]seedSlot{}
+	for i := range days {
+		if hours[i] != 14 {
+			t.Errorf("slot at hour %d, want 14", hours[i])
+		}
+		got[slotKey{int(days[i]), int(hours[i])}] = seedSlot{int(samples[i]), means[i], variances[i]}
+	}
+
+	tests := []struct {
+		name string
+		key  slotKey
+		want []float64 // hourly averages learnt by the slot
+	}{
+		{"monday", slotKey{1, 14}, []float64{100, 110, 120}},
+		{"tuesday", slotKey{2, 14}, []float64{50}},
+		{"any day", slotKey{AnyDay, 14}, []float64{100, 110, 120, 50}},
+	}
+	if len(got) != len(tests) {
+		t.Errorf("got %d slots, want %d", len(got), len(tests))
+	}
+	for _, tt := range tests {
+		// Below memory samples a slot holds the plain mean and variance
+		var mean, variance float64
+		for _, v := range tt.want {
+			mean += v / float64(len(tt.want))
+		}
+		for _, v := range tt.want {
+			variance += (v - mean) * (v - mean) / float64(len(tt.want))
+		}
+		s := got[tt.key]
+		if s.samples != len(tt.want) || math.Abs(s.mean-mean) > 1e-9 || math.Abs(s.variance-variance) > 1e-9 {
+			t.Errorf("%s slot = %+v, want %d samples, mean %v, variance %v", tt.name, s, len(tt.want), mean, variance)
+		}
+	}
+}
+
+func TestSeedForgets(t *testing.T) {
+	var b seed
+	hour := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	for i := 0; i < 3*memory; i++ {
+		b.learn(hour, 100)
+	}
+	b.learn(hour, 180)
+
+	s := b.slots[slotKey{AnyDay, 14}]
+	if s.samples != 3*memory+1 {
+		t.Errorf("samples = %d", s.samples)
+	}
+	// Past memory samples a new hour moves the mean by 1/memory of its distance
+	if want := 100 + 80.0/memory; math.Abs(s.mean-want) > 1e-9 {
+		t.Errorf("mean = %v, want %v", s.mean, want)
+	}
+}
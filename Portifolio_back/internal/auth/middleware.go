@@ -3,6 +3,7 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -13,13 +14,12 @@ import (
 func AuthMiddleware() gin.HandlerFunc {
 	secret := []byte(os.Getenv("JWT_SECRET"))
 	return func(c *gin.Context) {
-		auth := c.GetHeader("Authorization")
-		parts := strings.Split(auth, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		raw, ok := bearerToken(c)
+		if !ok {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		tok, err := jwt.Parse(parts[1], func(t *jwt.Token) (any, error) { return secret, nil })
+		tok, err := jwt.Parse(raw, func(t *jwt.Token) (any, error) { return secret, nil })
 		if err != nil || !tok.Valid {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
@@ -34,6 +34,65 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// bearerToken reads the token from the Authorization header. Browsers cannot set
+// headers on EventSource or WebSocket connections, so those requests may pass it
+// as the access_token query parameter instead.
+func bearerToken(c *gin.Context) (string, bool) {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		parts := strings.Split(auth, " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			return "", false
+		}
+		return parts[1], true
+	}
+	if isStreamRequest(c) {
+		if tok := c.Query("access_token"); tok != "" {
+			return tok, true
+		}
+	}
+	return "", false
+}
+
+// RequestLogger is gin's request logger with the access_token query parameter
+// masked, so tokens passed by stream clients never reach the access log.
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(logFormatter)
+}
+
+func logFormatter(p gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		p.StatusCode,
+		p.Latency,
+		p.ClientIP,
+		p.Method,
+		redactToken(p.Path),
+		p.ErrorMessage,
+	)
+}
+
+// redactToken masks the access_token query parameter of a request URI.
+func redactToken(uri string) string {
+	path, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return path + "?REDACTED" // the token cannot be located in a malformed query
+	}
+	if !q.Has("access_token") {
+		return uri
+	}
+	q.Set("access_token", "REDACTED")
+	return path + "?" + q.Encode()
+}
+
+func isStreamRequest(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream") ||
+		strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
 func toString(v any) string {
 	if v == nil {
 		return ""
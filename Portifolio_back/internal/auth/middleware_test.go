@@ -0,0 +1,22 @@
+package auth
+
+import "testing"
+
+func TestRedactToken(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"/api/stream", "/api/stream"},
+		{"/api/stream?device_id=3", "/api/stream?device_id=3"},
+		{"/api/stream?access_token=eyJhbGciOi.x.y", "/api/stream?access_token=REDACTED"},
+		{"/api/ws?device_id=3&access_token=eyJ.a.b", "/api/ws?access_token=REDACTED&device_id=3"},
+		{"/api/ws?access%5Ftoken=eyJ.a.b", "/api/ws?access_token=REDACTED"},
+		{"/api/ws?access_token=eyJ.a.b&bad=%zz", "/api/ws?REDACTED"},
+	}
+	for _, tt := range tests {
+		if got := redactToken(tt.uri); got != tt.want {
+			t.Errorf("redactToken(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
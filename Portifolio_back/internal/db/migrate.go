@@ -0,0 +1,314 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID is the pg_advisory_lock key held while migrating,
+// so replicas starting at the same time apply migrations one at a time.
+const migrationLockID int64 = 7_452_001
+
+// noTransaction is the first line of scripts that must run outside a
+// transaction, e.g. for CREATE INDEX CONCURRENTLY. Their statements run one
+// by one, so they must end with ";" at the end of a line and be safe to
+// repeat: a failure leaves the statements before it applied.
+const noTransaction = "-- migrate:no-transaction"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change, loaded from
+// migrations/<version>_<name>.up.sql and the optional .down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		file := e.Name()
+		base, direction, ok := splitMigrationName(file)
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", file)
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", file, prefix)
+		}
+
+		body, err := fs.ReadFile(migrationFiles, path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func splitMigrationName(file string) (base, direction string, ok bool) {
+	if b, found := strings.CutSuffix(file, ".up.sql"); found {
+		return b, "up", true
+	}
+	if b, found := strings.CutSuffix(file, ".down.sql"); found {
+		return b, "down", true
+	}
+	return "", "", false
+}
+
+// MigrateUp applies every pending migration and returns how many ran.
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, m, m.Up, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown reverts the last steps applied migrations and returns how many ran.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	reverted := 0
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions {
+			if reverted >= steps {
+				break
+			}
+			m, ok := known[v]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this build", v)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+			}
+			if err := apply(ctx, conn, m, m.Down, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every embedded migration with the time it was applied, if any.
+func Status(ctx context.Context, pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []MigrationStatus
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			st := MigrationStatus{Version: m.Version, Name: m.Name}
+			if at, ok := done[m.Version]; ok {
+				st.AppliedAt = &at
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// advisory lock; session-level locks are tied to the connection that took them.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock with a fresh context so a cancelled ctx doesn't leave the lock held
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations(
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions and when they ran.
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		done[v] = at
+	}
+	return done, rows.Err()
+}
+
+// apply runs one migration script and records it in a single transaction,
+// so a failing script leaves neither partial schema changes nor a version row.
+// Scripts marked with noTransaction run statement by statement instead, and
+// are recorded once they all succeeded.
+func apply(ctx context.Context, conn *pgxpool.Conn, m Migration, script string, up bool) error {
+	direction := "down"
+	record, args := `DELETE FROM schema_migrations WHERE version = $1`, []any{m.Version}
+	if up {
+		direction = "up"
+		record, args = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, []any{m.Version, m.Name}
+	}
+
+	var err error
+	if strings.HasPrefix(script, noTransaction) {
+		err = func() error {
+			// One statement per query: several in one query run as an implicit transaction
+			for _, stmt := range statements(script) {
+				if _, err := conn.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := conn.Exec(ctx, record, args...)
+			return err
+		}()
+	} else {
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			// No arguments: pgx uses the simple protocol, which allows multiple statements
+			if _, err := tx.Exec(ctx, script); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, record, args...)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("migration %d_%s (%s): %w", m.Version, m.Name, direction, err)
+	}
+	return nil
+}
+
+// statements splits a script into the statements ending with ";" at the end
+// of a line, dropping lines that hold only a comment.
+func statements(script string) []string {
+	var out []string
+	var stmt strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSpace(stmt.String()))
+			stmt.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(stmt.String()); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			"comments and blank lines",
+			"-- migrate:no-transaction\n-- why\n\nDROP INDEX CONCURRENTLY IF EXISTS a;\n\n-- next\nCREATE INDEX CONCURRENTLY b ON t(x);\n",
+			[]string{"DROP INDEX CONCURRENTLY IF EXISTS a;", "CREATE INDEX CONCURRENTLY b ON t(x);"},
+		},
+		{
+			"statement over several lines",
+			"CREATE INDEX CONCURRENTLY b\n\tON t(x)\n\tWHERE x > 0;\nDROP INDEX a;",
+			[]string{"CREATE INDEX CONCURRENTLY b\n\tON t(x)\n\tWHERE x > 0;", "DROP INDEX a;"},
+		},
+		{
+			"no trailing semicolon",
+			"DROP INDEX a;\nDROP INDEX b",
+			[]string{"DROP INDEX a;", "DROP INDEX b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// CONCURRENTLY fails inside a transaction, so only unmarked scripts may omit it.
+func TestConcurrentMigrationsRunOutsideTransactions(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		for _, script := range []string{m.Up, m.Down} {
+			if strings.Contains(script, "CONCURRENTLY") && !strings.HasPrefix(script, noTransaction) {
+				t.Errorf("migration %d_%s uses CONCURRENTLY in a transaction", m.Version, m.Name)
+			}
+		}
+		if m.Version == 11 && (!strings.HasPrefix(m.Up, noTransaction) || !strings.HasPrefix(m.Down, noTransaction)) {
+			t.Errorf("migration 11 must run outside a transaction")
+		}
+	}
+}
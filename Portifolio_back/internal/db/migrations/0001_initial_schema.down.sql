@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS telemetry;
+DROP TABLE IF EXISTS device_secret;
+DROP TABLE IF EXISTS device;
+DROP TABLE IF EXISTS app_user;
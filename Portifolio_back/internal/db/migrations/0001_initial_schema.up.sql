@@ -0,0 +1,51 @@
+-- Baseline schema. Statements are idempotent so databases created by the
+-- previous ensureSchema bootstrap can adopt migrations without changes.
+
+CREATE TABLE IF NOT EXISTS app_user(
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS device(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	room TEXT,
+	type TEXT DEFAULT 'smart_plug',
+	status TEXT DEFAULT 'offline',
+	metadata TEXT,
+	mqtt_topic TEXT,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	last_seen TIMESTAMPTZ
+);
+
+ALTER TABLE device ADD COLUMN IF NOT EXISTS mqtt_topic TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_device_mqtt_topic ON device(mqtt_topic) WHERE mqtt_topic IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_device_user_id ON device(user_id);
+
+CREATE TABLE IF NOT EXISTS device_secret(
+	device_id BIGINT PRIMARY KEY REFERENCES device(id) ON DELETE CASCADE,
+	key_id TEXT NOT NULL,
+	nonce BYTEA NOT NULL,
+	ciphertext BYTEA NOT NULL,
+	updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS telemetry(
+	id BIGSERIAL PRIMARY KEY,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	power DOUBLE PRECISION NOT NULL,
+	voltage DOUBLE PRECISION,
+	current DOUBLE PRECISION,
+	energy_total DOUBLE PRECISION,
+	energy_today DOUBLE PRECISION,
+	timestamp TIMESTAMPTZ DEFAULT NOW()
+);
+
+ALTER TABLE telemetry ADD COLUMN IF NOT EXISTS energy_total DOUBLE PRECISION;
+ALTER TABLE telemetry ADD COLUMN IF NOT EXISTS energy_today DOUBLE PRECISION;
+CREATE INDEX IF NOT EXISTS idx_telemetry_device_id ON telemetry(device_id);
+CREATE INDEX IF NOT EXISTS idx_telemetry_timestamp ON telemetry(timestamp DESC);
@@ -0,0 +1 @@
+ALTER TABLE device DROP COLUMN IF EXISTS power_state;
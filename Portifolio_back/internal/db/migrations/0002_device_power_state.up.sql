@@ -0,0 +1,2 @@
+-- Relay state reported by physical devices (NULL = unknown).
+ALTER TABLE device ADD COLUMN IF NOT EXISTS power_state BOOLEAN;
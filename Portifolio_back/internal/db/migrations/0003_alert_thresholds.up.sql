@@ -0,0 +1,30 @@
+-- Per-device power thresholds (Watts), formerly kept in the browser.
+CREATE TABLE device_threshold(
+	device_id BIGINT PRIMARY KEY REFERENCES device(id) ON DELETE CASCADE,
+	warning DOUBLE PRECISION NOT NULL,
+	danger DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (warning > 0 AND danger > warning)
+);
+
+-- Alert events: open -> acknowledged -> resolved.
+CREATE TABLE alert_event(
+	id BIGSERIAL PRIMARY KEY,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	source TEXT NOT NULL,
+	level TEXT NOT NULL,
+	state TEXT NOT NULL DEFAULT 'open',
+	message TEXT NOT NULL,
+	value DOUBLE PRECISION NOT NULL,
+	threshold DOUBLE PRECISION NOT NULL,
+	peak_value DOUBLE PRECISION NOT NULL,
+	opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	acknowledged_at TIMESTAMPTZ,
+	resolved_at TIMESTAMPTZ,
+	CHECK (state IN ('open', 'acknowledged', 'resolved'))
+);
+
+-- At most one unresolved threshold alert per device
+CREATE UNIQUE INDEX idx_alert_event_active_threshold ON alert_event(device_id)
+	WHERE source = 'threshold' AND state <> 'resolved';
+CREATE INDEX idx_alert_event_device_opened ON alert_event(device_id, opened_at DESC);
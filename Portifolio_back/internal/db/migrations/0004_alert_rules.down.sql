@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_alert_event_active_rule;
+ALTER TABLE alert_event DROP COLUMN IF EXISTS rule_id;
+DROP TABLE IF EXISTS alert_rule;
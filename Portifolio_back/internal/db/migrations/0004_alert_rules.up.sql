@@ -0,0 +1,27 @@
+-- Alert rules evaluated by the background rules engine.
+CREATE TABLE alert_rule(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	level TEXT NOT NULL DEFAULT 'warning',
+	threshold DOUBLE PRECISION,
+	min_value DOUBLE PRECISION,
+	max_value DOUBLE PRECISION,
+	window_minutes INT NOT NULL DEFAULT 0,
+	hysteresis DOUBLE PRECISION NOT NULL DEFAULT 0,
+	cooldown_minutes INT NOT NULL DEFAULT 0,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (kind IN ('avg_power_above', 'offline', 'daily_energy_above', 'voltage_outside')),
+	CHECK (level IN ('warning', 'danger'))
+);
+CREATE INDEX idx_alert_rule_user_id ON alert_rule(user_id);
+
+ALTER TABLE alert_event ADD COLUMN rule_id BIGINT REFERENCES alert_rule(id) ON DELETE SET NULL;
+
+-- At most one unresolved alert per rule
+CREATE UNIQUE INDEX idx_alert_event_active_rule ON alert_event(rule_id)
+	WHERE rule_id IS NOT NULL AND state <> 'resolved';
@@ -0,0 +1,29 @@
+-- Per-user notification preferences. Users without a row get the defaults.
+CREATE TABLE notification_preference(
+	user_id BIGINT PRIMARY KEY REFERENCES app_user(id) ON DELETE CASCADE,
+	email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	email TEXT, -- overrides the account e-mail when set
+	min_level TEXT NOT NULL DEFAULT 'warning',
+	notify_resolved BOOLEAN NOT NULL DEFAULT TRUE,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (min_level IN ('warning', 'danger'))
+);
+
+-- Outgoing messages, kept until delivered so nothing is lost on restart.
+CREATE TABLE notification_outbox(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	channel TEXT NOT NULL DEFAULT 'email',
+	recipient TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	text_body TEXT NOT NULL,
+	html_body TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	sent_at TIMESTAMPTZ,
+	CHECK (status IN ('pending', 'sent', 'failed'))
+);
+CREATE INDEX idx_notification_outbox_due ON notification_outbox(next_attempt_at) WHERE status = 'pending';
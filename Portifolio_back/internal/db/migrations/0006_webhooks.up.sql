@@ -0,0 +1,34 @@
+-- User-configured outgoing webhooks.
+CREATE TABLE webhook(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	description TEXT,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_webhook_user_id ON webhook(user_id);
+
+-- One row per event sent to a webhook; doubles as the delivery log.
+CREATE TABLE webhook_delivery(
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id BIGINT NOT NULL REFERENCES webhook(id) ON DELETE CASCADE,
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	response_status INT,
+	response_body TEXT,
+	last_error TEXT,
+	duration_ms INT,
+	redelivery_of BIGINT REFERENCES webhook_delivery(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	delivered_at TIMESTAMPTZ,
+	CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+CREATE INDEX idx_webhook_delivery_webhook ON webhook_delivery(webhook_id, created_at DESC);
+CREATE INDEX idx_webhook_delivery_due ON webhook_delivery(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS schedule_run;
+DROP TABLE IF EXISTS device_schedule;
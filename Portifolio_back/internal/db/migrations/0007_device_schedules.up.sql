@@ -0,0 +1,36 @@
+-- Weekly on/off schedules for devices, executed by the scheduler.
+CREATE TABLE device_schedule(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	weekdays INT[] NOT NULL, -- 0 = Sunday ... 6 = Saturday
+	time_of_day TEXT NOT NULL, -- HH:MM in timezone
+	timezone TEXT NOT NULL DEFAULT 'UTC',
+	missed_policy TEXT NOT NULL DEFAULT 'skip',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	next_run_at TIMESTAMPTZ,
+	last_run_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (action IN ('on', 'off')),
+	CHECK (missed_policy IN ('skip', 'run_once'))
+);
+CREATE INDEX idx_device_schedule_user_id ON device_schedule(user_id);
+CREATE INDEX idx_device_schedule_due ON device_schedule(next_run_at) WHERE enabled;
+
+-- Execution history of schedules.
+CREATE TABLE schedule_run(
+	id BIGSERIAL PRIMARY KEY,
+	schedule_id BIGINT NOT NULL REFERENCES device_schedule(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	action TEXT NOT NULL,
+	scheduled_for TIMESTAMPTZ NOT NULL,
+	executed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	status TEXT NOT NULL,
+	late BOOLEAN NOT NULL DEFAULT FALSE,
+	error TEXT,
+	CHECK (status IN ('success', 'failed', 'skipped'))
+);
+CREATE INDEX idx_schedule_run_schedule ON schedule_run(schedule_id, executed_at DESC);
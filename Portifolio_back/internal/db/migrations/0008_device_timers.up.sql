@@ -0,0 +1,21 @@
+-- One-shot device timers: countdowns and power-based auto-off.
+CREATE TABLE device_timer(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	action TEXT NOT NULL DEFAULT 'off',
+	fire_at TIMESTAMPTZ, -- countdown only
+	threshold_watts DOUBLE PRECISION, -- idle_off / overrun_off only
+	duration_minutes INT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'active',
+	error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	fired_at TIMESTAMPTZ,
+	cancelled_at TIMESTAMPTZ,
+	CHECK (kind IN ('countdown', 'idle_off', 'overrun_off')),
+	CHECK (action IN ('on', 'off')),
+	CHECK (status IN ('active', 'fired', 'failed', 'cancelled'))
+);
+CREATE INDEX idx_device_timer_user_id ON device_timer(user_id);
+CREATE INDEX idx_device_timer_active ON device_timer(id) WHERE status = 'active';
@@ -0,0 +1,12 @@
+DELETE FROM schedule_run WHERE device_id IS NULL;
+ALTER TABLE schedule_run DROP COLUMN IF EXISTS scene_id;
+ALTER TABLE schedule_run ALTER COLUMN device_id SET NOT NULL;
+
+DELETE FROM device_schedule WHERE device_id IS NULL;
+ALTER TABLE device_schedule DROP CONSTRAINT IF EXISTS device_schedule_action_check;
+ALTER TABLE device_schedule ADD CONSTRAINT device_schedule_action_check CHECK (action IN ('on', 'off'));
+ALTER TABLE device_schedule DROP COLUMN IF EXISTS scene_id;
+ALTER TABLE device_schedule ALTER COLUMN device_id SET NOT NULL;
+
+DROP TABLE IF EXISTS scene_action;
+DROP TABLE IF EXISTS scene;
@@ -0,0 +1,28 @@
+-- Named sets of desired power states applied to many devices at once.
+CREATE TABLE scene(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_scene_user_id ON scene(user_id);
+
+CREATE TABLE scene_action(
+	scene_id BIGINT NOT NULL REFERENCES scene(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	power_on BOOLEAN NOT NULL,
+	PRIMARY KEY (scene_id, device_id)
+);
+
+-- Schedules can apply a scene instead of switching one device
+ALTER TABLE device_schedule ALTER COLUMN device_id DROP NOT NULL;
+ALTER TABLE device_schedule ADD COLUMN scene_id BIGINT REFERENCES scene(id) ON DELETE CASCADE;
+ALTER TABLE device_schedule DROP CONSTRAINT device_schedule_action_check;
+ALTER TABLE device_schedule ADD CONSTRAINT device_schedule_action_check CHECK (
+	(device_id IS NOT NULL AND scene_id IS NULL AND action IN ('on', 'off'))
+	OR (device_id IS NULL AND scene_id IS NOT NULL AND action = 'scene')
+);
+
+ALTER TABLE schedule_run ALTER COLUMN device_id DROP NOT NULL;
+ALTER TABLE schedule_run ADD COLUMN scene_id BIGINT REFERENCES scene(id) ON DELETE CASCADE;
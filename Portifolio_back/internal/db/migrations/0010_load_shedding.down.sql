@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS load_shed_event;
+DROP TABLE IF EXISTS device_load_shed;
+DROP TABLE IF EXISTS power_budget;
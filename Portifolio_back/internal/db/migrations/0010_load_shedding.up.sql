@@ -0,0 +1,39 @@
+-- Whole-house power budget: when the latest readings add up to more than
+-- budget_watts, the lowest-priority devices are switched off.
+CREATE TABLE power_budget(
+	user_id BIGINT PRIMARY KEY REFERENCES app_user(id) ON DELETE CASCADE,
+	budget_watts DOUBLE PRECISION NOT NULL CHECK (budget_watts > 0),
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	auto_restore BOOLEAN NOT NULL DEFAULT TRUE,
+	restore_margin_watts DOUBLE PRECISION NOT NULL DEFAULT 0, -- headroom required before restoring a device
+	restore_delay_minutes INT NOT NULL DEFAULT 5,             -- minimum time a device stays shed
+	override_minutes INT NOT NULL DEFAULT 60,                 -- how long an override protects a device
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Per-device shedding priority and state. Devices without a row have priority 0.
+CREATE TABLE device_load_shed(
+	device_id BIGINT PRIMARY KEY REFERENCES device(id) ON DELETE CASCADE,
+	priority INT NOT NULL DEFAULT 0, -- lowest is shed first
+	never_shed BOOLEAN NOT NULL DEFAULT FALSE,
+	override_until TIMESTAMPTZ,      -- not shed before this time
+	shed_at TIMESTAMPTZ,             -- set while the device is off because of the budget
+	shed_watts DOUBLE PRECISION,     -- power drawn when it was shed
+	failed_at TIMESTAMPTZ            -- last failed command, retried after a pause
+);
+
+CREATE TABLE load_shed_event(
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	action TEXT NOT NULL,
+	status TEXT NOT NULL,
+	total_watts DOUBLE PRECISION NOT NULL,
+	budget_watts DOUBLE PRECISION NOT NULL,
+	device_watts DOUBLE PRECISION NOT NULL,
+	error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (action IN ('shed', 'restore', 'override')),
+	CHECK (status IN ('success', 'failed'))
+);
+CREATE INDEX idx_load_shed_event_user_created ON load_shed_event(user_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+DROP INDEX CONCURRENTLY IF EXISTS idx_telemetry_device_id;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_telemetry_device_id ON telemetry(device_id);
+DROP INDEX CONCURRENTLY IF EXISTS idx_telemetry_device_timestamp;
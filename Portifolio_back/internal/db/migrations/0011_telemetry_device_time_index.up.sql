@@ -0,0 +1,11 @@
+-- migrate:no-transaction
+-- Built concurrently so telemetry inserts are not blocked while the index is
+-- created. Dropping first clears an invalid index left by an interrupted run.
+DROP INDEX CONCURRENTLY IF EXISTS idx_telemetry_device_timestamp;
+
+-- Range scans per device (aggregations, windows, latest reading) use this
+-- index; power and voltage are included so aggregations can skip the heap.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_telemetry_device_timestamp ON telemetry(device_id, timestamp DESC) INCLUDE (power, voltage);
+
+-- Covered by the index above
+DROP INDEX CONCURRENTLY IF EXISTS idx_telemetry_device_id;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tariff_flag;
+DROP TABLE IF EXISTS tariff;
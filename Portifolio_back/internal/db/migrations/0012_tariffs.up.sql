@@ -0,0 +1,33 @@
+-- Electricity tariff per user: tarifa convencional (one rate) or tarifa branca
+-- (time-of-use rates for ponta, intermediário and fora ponta). Rates are R$/kWh
+-- before taxes; flag surcharges are R$/kWh; taxes are percentages.
+CREATE TABLE tariff(
+	user_id BIGINT PRIMARY KEY REFERENCES app_user(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	rate DOUBLE PRECISION,
+	peak_rate DOUBLE PRECISION,
+	intermediate_rate DOUBLE PRECISION,
+	off_peak_rate DOUBLE PRECISION,
+	peak_start TEXT NOT NULL,
+	peak_end TEXT NOT NULL,
+	intermediate_minutes INT NOT NULL,
+	flag_yellow DOUBLE PRECISION NOT NULL,
+	flag_red_1 DOUBLE PRECISION NOT NULL,
+	flag_red_2 DOUBLE PRECISION NOT NULL,
+	flag_scarcity DOUBLE PRECISION NOT NULL,
+	icms DOUBLE PRECISION NOT NULL DEFAULT 0,
+	pis DOUBLE PRECISION NOT NULL DEFAULT 0,
+	cofins DOUBLE PRECISION NOT NULL DEFAULT 0,
+	timezone TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (kind IN ('convencional', 'branca'))
+);
+
+-- Bandeira tarifária in force each month (months without a row are verde).
+CREATE TABLE tariff_flag(
+	user_id BIGINT NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+	month DATE NOT NULL, -- first day of the month
+	flag TEXT NOT NULL,
+	PRIMARY KEY (user_id, month),
+	CHECK (flag IN ('verde', 'amarela', 'vermelha_1', 'vermelha_2', 'escassez_hidrica'))
+);
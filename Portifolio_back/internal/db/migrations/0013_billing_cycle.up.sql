@@ -0,0 +1,9 @@
+-- Billing cycle per user: the cycle starts on start_day of each month (the
+-- utility's reading date; clamped to the last day in shorter months).
+CREATE TABLE billing_cycle(
+	user_id BIGINT PRIMARY KEY REFERENCES app_user(id) ON DELETE CASCADE,
+	start_day INT NOT NULL,
+	timezone TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (start_day BETWEEN 1 AND 31)
+);
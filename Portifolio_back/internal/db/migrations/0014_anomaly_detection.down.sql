@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_alert_event_active_anomaly;
+DROP TABLE IF EXISTS anomaly;
+DROP TABLE IF EXISTS anomaly_baseline;
+DROP TABLE IF EXISTS device_anomaly;
@@ -0,0 +1,41 @@
+-- Per-device anomaly detection setting and the hour being accumulated. Devices
+-- without a row are checked with the defaults.
+CREATE TABLE device_anomaly(
+	device_id BIGINT PRIMARY KEY REFERENCES device(id) ON DELETE CASCADE,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	z_score DOUBLE PRECISION NOT NULL DEFAULT 3 CHECK (z_score > 0), -- deviation that is flagged
+	timezone TEXT NOT NULL DEFAULT 'America/Sao_Paulo',               -- for hour of day and day of week
+	hour_start TIMESTAMPTZ,                                           -- hour whose readings are being summed
+	readings INT NOT NULL DEFAULT 0,
+	power_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Usual hourly average power per device, hour of day and day of week (0 =
+-- Sunday; 7 = any day), as an exponentially weighted mean and variance.
+CREATE TABLE anomaly_baseline(
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	day SMALLINT NOT NULL CHECK (day BETWEEN 0 AND 7),
+	hour SMALLINT NOT NULL CHECK (hour BETWEEN 0 AND 23),
+	samples INT NOT NULL,
+	mean DOUBLE PRECISION NOT NULL,
+	variance DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (device_id, day, hour)
+);
+
+-- Hours whose average power deviated from the baseline.
+CREATE TABLE anomaly(
+	id BIGSERIAL PRIMARY KEY,
+	device_id BIGINT NOT NULL REFERENCES device(id) ON DELETE CASCADE,
+	hour_start TIMESTAMPTZ NOT NULL,
+	observed DOUBLE PRECISION NOT NULL,
+	expected DOUBLE PRECISION NOT NULL,
+	std_dev DOUBLE PRECISION NOT NULL,
+	z_score DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_anomaly_device_hour ON anomaly(device_id, hour_start DESC);
+
+-- At most one unresolved anomaly alert per device
+CREATE UNIQUE INDEX idx_alert_event_active_anomaly ON alert_event(device_id)
+	WHERE source = 'anomaly' AND state <> 'resolved';
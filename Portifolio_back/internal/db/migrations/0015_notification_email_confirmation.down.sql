@@ -0,0 +1,4 @@
+ALTER TABLE notification_preference
+	DROP COLUMN IF EXISTS email_token_expires_at,
+	DROP COLUMN IF EXISTS email_token_hash,
+	DROP COLUMN IF EXISTS pending_email;
@@ -0,0 +1,9 @@
+-- A notification address other than the account e-mail is only used once its
+-- owner confirms it with the token e-mailed to it (kept as a SHA-256 hash).
+ALTER TABLE notification_preference
+	ADD COLUMN pending_email TEXT,
+	ADD COLUMN email_token_hash TEXT,
+	ADD COLUMN email_token_expires_at TIMESTAMPTZ;
+
+-- Addresses set before confirmation existed were never verified
+UPDATE notification_preference SET pending_email = email, email = NULL WHERE email IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Daily rules (daily_energy_above) start "today" at midnight in this zone
+ALTER TABLE alert_rule ADD COLUMN timezone TEXT NOT NULL DEFAULT 'America/Sao_Paulo';
@@ -0,0 +1 @@
+ALTER TABLE device_anomaly DROP COLUMN IF EXISTS backfilled;
@@ -0,0 +1,3 @@
+-- Whether the baseline was seeded from the telemetry recorded before detection
+-- started. Rows that already exist are seeded too unless they have a baseline.
+ALTER TABLE device_anomaly ADD COLUMN backfilled BOOLEAN NOT NULL DEFAULT FALSE;
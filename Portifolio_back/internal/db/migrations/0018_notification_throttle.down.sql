@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notification_outbox_alert;
+ALTER TABLE notification_outbox
+	DROP COLUMN IF EXISTS device_id,
+	DROP COLUMN IF EXISTS alert_source;
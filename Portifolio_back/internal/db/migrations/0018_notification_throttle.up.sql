@@ -0,0 +1,7 @@
+-- Alert e-mails remember their device and alert source, so a device flapping
+-- around a limit is throttled instead of sending a message per reading.
+ALTER TABLE notification_outbox
+	ADD COLUMN device_id BIGINT,
+	ADD COLUMN alert_source TEXT;
+CREATE INDEX idx_notification_outbox_alert ON notification_outbox(device_id, alert_source, created_at)
+	WHERE device_id IS NOT NULL;
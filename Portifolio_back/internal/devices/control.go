@@ -0,0 +1,179 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+)
+
+// Errors returned by Controller. Driver errors are wrapped after them, so
+// errors.Is works on both and ErrorDetail recovers the driver message.
+var (
+	ErrCredentials          = errors.New("failed to load device credentials")
+	ErrIncompleteConnection = errors.New("device connection is incomplete")
+	ErrNotConfigured        = errors.New("device not configured for live reads")
+	ErrNotSwitchable        = errors.New("device does not support remote switching")
+	ErrDeviceControl        = errors.New("failed to control physical device")
+	ErrDeviceRead           = errors.New("failed to read power from device")
+	ErrStateUpdate          = errors.New("failed to update device state")
+)
+
+// Controller runs commands against devices: through their driver when the
+// metadata has a connection block, otherwise by updating the stored state only.
+// It is shared by the HTTP handlers and the WebSocket channel.
+type Controller struct {
+	Repo    *Repo
+	Drivers *drivers.Registry
+	Secrets CredentialStore    // nil when no encryption key is configured
+	Events  realtime.Publisher // nil when nobody listens for live updates
+}
+
+// NewController creates a device controller.
+func NewController(repo *Repo, registry *drivers.Registry, store CredentialStore, events realtime.Publisher) *Controller {
+	return &Controller{Repo: repo, Drivers: registry, Secrets: store, Events: events}
+}
+
+// DeviceForUser loads a device, returning ErrNotFound unless userID owns it.
+func (c *Controller) DeviceForUser(ctx context.Context, userID, deviceID int64) (*Device, error) {
+	d, err := c.Repo.GetByID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if d.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return d, nil
+}
+
+// Toggle flips the relay of a physical device, or the online status of a virtual one,
+// and returns the updated device.
+func (c *Controller) Toggle(ctx context.Context, d *Device) (*Device, error) {
+	driver, conn, physical, err := c.connection(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if !physical {
+		newStatus := "online"
+		if d.Status == "online" {
+			newStatus = "offline"
+		}
+		if err := c.Repo.UpdateStatus(ctx, d.ID, newStatus); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+		}
+		return c.refresh(ctx, d.ID)
+	}
+	return c.switchPhysical(ctx, d.ID, driver, conn, !isOn(d.PowerState))
+}
+
+// SetPower turns a device on or off. Virtual devices record the requested
+// state and go online when on, offline when off.
+func (c *Controller) SetPower(ctx context.Context, d *Device, on bool) (*Device, error) {
+	driver, conn, physical, err := c.connection(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if physical {
+		return c.switchPhysical(ctx, d.ID, driver, conn, on)
+	}
+
+	status := "offline"
+	if on {
+		status = "online"
+	}
+	if err := c.Repo.UpdatePowerState(ctx, d.ID, on); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+	}
+	if err := c.Repo.UpdateStatus(ctx, d.ID, status); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+	}
+	return c.refresh(ctx, d.ID)
+}
+
+// Controllable reports whether SetPower switches a physical device: it has a
+// registered driver, a complete connection and the driver can switch it.
+func (c *Controller) Controllable(ctx context.Context, d *Device) bool {
+	driver, _, physical, err := c.connection(ctx, d)
+	return err == nil && physical && driver.Capabilities().Switch
+}
+
+// Read performs a live read of a physical device.
+func (c *Controller) Read(ctx context.Context, d *Device) (*drivers.Reading, error) {
+	driver, conn, err := ResolveConnection(ctx, c.Drivers, c.Secrets, d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCredentials, err)
+	}
+	if driver == nil || driver.Validate(conn) != nil {
+		return nil, ErrNotConfigured
+	}
+
+	reading, err := driver.ReadPower(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDeviceRead, err)
+	}
+	return reading, nil
+}
+
+// connection resolves the driver connection of a device. physical is false for
+// devices without a connection block; an incomplete block is an error rather
+// than a silent fallback to the virtual behaviour.
+func (c *Controller) connection(ctx context.Context, d *Device) (drivers.Driver, drivers.Connection, bool, error) {
+	driver, conn, err := ResolveConnection(ctx, c.Drivers, c.Secrets, d)
+	if err != nil {
+		return nil, conn, false, fmt.Errorf("%w: %w", ErrCredentials, err)
+	}
+	if driver == nil || !drivers.HasConnection(d.Metadata, driver.Name()) {
+		return nil, conn, false, nil
+	}
+	if err := driver.Validate(conn); err != nil {
+		return nil, conn, false, fmt.Errorf("%w: %w", ErrIncompleteConnection, err)
+	}
+	return driver, conn, true, nil
+}
+
+func (c *Controller) switchPhysical(ctx context.Context, id int64, driver drivers.Driver, conn drivers.Connection, on bool) (*Device, error) {
+	if !driver.Capabilities().Switch {
+		return nil, ErrNotSwitchable
+	}
+	if err := driver.SetPower(ctx, conn, on); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDeviceControl, err)
+	}
+	if err := c.Repo.UpdatePowerState(ctx, id, on); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+	}
+	if err := c.Repo.UpdateStatus(ctx, id, "online"); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+	}
+	return c.refresh(ctx, id)
+}
+
+// refresh reloads a device after a command and publishes its new status.
+func (c *Controller) refresh(ctx context.Context, id int64) (*Device, error) {
+	d, err := c.Repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateUpdate, err)
+	}
+	if c.Events != nil {
+		c.Events.Publish(realtime.CommandEvent(d.UserID, d.ID, d.Status, d.PowerState))
+	}
+	return d, nil
+}
+
+// PublishStatus pushes the device status to live subscribers.
+func (c *Controller) PublishStatus(d *Device) {
+	if c.Events != nil {
+		c.Events.Publish(realtime.StatusEvent(d.UserID, d.ID, d.Status, d.PowerState))
+	}
+}
+
+// ErrorDetail returns the driver message wrapped after one of the Controller errors.
+func ErrorDetail(err, kind error) string {
+	return strings.TrimPrefix(err.Error(), kind.Error()+": ")
+}
+
+func isOn(powerState *bool) bool {
+	return powerState != nil && *powerState
+}
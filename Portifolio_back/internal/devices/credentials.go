@@ -0,0 +1,153 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/secrets"
+)
+
+// redactedValue replaces secrets left in metadata when it is returned to clients.
+const redactedValue = "********"
+
+// CredentialStore keeps device credentials outside the device row (satisfied by secrets.Store).
+type CredentialStore interface {
+	Get(ctx context.Context, deviceID int64) (*secrets.Credentials, error)
+	Put(ctx context.Context, deviceID int64, c secrets.Credentials) error
+	Delete(ctx context.Context, deviceID int64) error
+}
+
+// ResolveConnection returns the driver for the device type and its connection:
+// the host comes from the driver's metadata block, the credentials from the store
+// when one is configured. driver is nil when the device type has no driver.
+func ResolveConnection(ctx context.Context, registry *drivers.Registry, store CredentialStore, d *Device) (drivers.Driver, drivers.Connection, error) {
+	if registry == nil {
+		return nil, drivers.Connection{}, nil
+	}
+	driver, ok := registry.Lookup(d.Type)
+	if !ok {
+		return nil, drivers.Connection{}, nil
+	}
+
+	conn := drivers.ParseConnection(d.Metadata, driver.Name())
+	if store == nil {
+		return driver, conn, nil
+	}
+
+	creds, err := store.Get(ctx, d.ID)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return driver, conn, nil
+	}
+	if err != nil {
+		return driver, conn, err
+	}
+	conn.Username = creds.Username
+	conn.Password = creds.Password
+	return driver, conn, nil
+}
+
+// splitCredentials removes "username" and "password" from every block of the
+// metadata and returns the cleaned JSON plus the first credentials found.
+// Invalid JSON is returned unchanged.
+func splitCredentials(metadata string) (string, *secrets.Credentials) {
+	if metadata == "" {
+		return metadata, nil
+	}
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return metadata, nil
+	}
+
+	var found *secrets.Credentials
+	changed := false
+	for _, v := range meta {
+		block, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		user, hasUser := block["username"].(string)
+		pass, hasPass := block["password"].(string)
+		if !hasUser && !hasPass {
+			continue
+		}
+		if found == nil {
+			found = &secrets.Credentials{Username: user, Password: pass}
+		}
+		delete(block, "username")
+		delete(block, "password")
+		changed = true
+	}
+	if !changed {
+		return metadata, nil
+	}
+
+	clean, err := json.Marshal(meta)
+	if err != nil {
+		return metadata, nil
+	}
+	return string(clean), found
+}
+
+// redactMetadata masks any password still stored in plain metadata.
+func redactMetadata(metadata string) string {
+	if metadata == "" {
+		return metadata
+	}
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return metadata
+	}
+
+	changed := false
+	for _, v := range meta {
+		if block, ok := v.(map[string]any); ok {
+			if _, ok := block["password"]; ok {
+				block["password"] = redactedValue
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return metadata
+	}
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// redact prepares a device for an API response.
+func redact(d *Device) *Device {
+	d.Metadata = redactMetadata(d.Metadata)
+	return d
+}
+
+// MigratePlaintextCredentials moves credentials still kept in device metadata
+// into the credential store. It returns how many devices were migrated.
+func MigratePlaintextCredentials(ctx context.Context, repo *Repo, store CredentialStore) (int, error) {
+	list, err := repo.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, d := range list {
+		clean, creds := splitCredentials(d.Metadata)
+		if creds == nil {
+			continue
+		}
+		if err := store.Put(ctx, d.ID, *creds); err != nil {
+			return migrated, err
+		}
+		if err := repo.UpdateMetadata(ctx, d.ID, clean); err != nil {
+			return migrated, err
+		}
+		migrated++
+		log.Printf("Moved plaintext credentials of device %d into the credential store", d.ID)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,169 @@
+package devices
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers/driverstest"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/secrets"
+)
+
+// memSecrets is an in-memory device_secret table answering the queries of secrets.Store.
+type memSecrets struct {
+	mu   sync.Mutex
+	rows map[int64][3]any // key_id, nonce, ciphertext
+	err  error            // returned by writes when set
+}
+
+func (m *memSecrets) Exec(_ context.Context, sql string, args ...any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	switch {
+	case strings.HasPrefix(sql, "INSERT INTO device_secret"):
+		m.rows[args[0].(int64)] = [3]any{args[1], args[2], args[3]}
+	case strings.HasPrefix(sql, "DELETE FROM device_secret"):
+		delete(m.rows, args[0].(int64))
+	default:
+		return fmt.Errorf("unexpected exec: %s", sql)
+	}
+	return nil
+}
+
+func (m *memSecrets) QueryRow(_ context.Context, _ string, args ...any) interface{ Scan(dest ...any) error } {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row, ok := m.rows[args[0].(int64)]
+	if !ok {
+		return errRow{pgx.ErrNoRows}
+	}
+	return secretRow(row)
+}
+
+func (m *memSecrets) Query(_ context.Context, sql string, _ ...any) (secrets.Rows, error) {
+	return nil, fmt.Errorf("unexpected query: %s", sql)
+}
+
+type secretRow [3]any
+
+func (r secretRow) Scan(dest ...any) error {
+	*dest[0].(*string) = r[0].(string)
+	*dest[1].(*[]byte) = r[1].([]byte)
+	*dest[2].(*[]byte) = r[2].([]byte)
+	return nil
+}
+
+func newTestStore(t *testing.T) (*secrets.Store, *memSecrets) {
+	t.Helper()
+	keys, err := secrets.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &memSecrets{rows: make(map[int64][3]any)}
+	return secrets.NewStore(db, keys), db
+}
+
+func TestToggleUsesStoredCredentials(t *testing.T) {
+	store, table := newTestStore(t)
+	ctx := context.Background()
+	if err := store.Put(ctx, 1, secrets.Credentials{Username: "owner@example.com", Password: "s3cret-one"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, 2, secrets.Credentials{Username: "other@example.com", Password: "s3cret-two"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, row := range table.rows {
+		if bytes.Contains(row[2].([]byte), []byte("s3cret")) {
+			t.Fatalf("device %d credentials stored in plaintext", id)
+		}
+	}
+
+	db := newMemDevices(
+		Device{ID: 1, UserID: 7, Name: "TV", Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.11"}}`},
+		// Stale plaintext credentials left in metadata must not win over the store
+		Device{ID: 2, UserID: 7, Name: "Heater", Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.12","username":"old","password":"old"}}`},
+	)
+	fake := driverstest.NewFake("fake")
+	r := newTestRouter(db, fake, store, 7)
+
+	for _, id := range []int64{1, 2} {
+		if w := toggle(t, r, id); w.Code != http.StatusOK {
+			t.Fatalf("toggle device %d: status %d, body %s", id, w.Code, w.Body)
+		}
+	}
+
+	want := []struct{ host, user, pass string }{
+		{"10.0.0.11", "owner@example.com", "s3cret-one"},
+		{"10.0.0.12", "other@example.com", "s3cret-two"},
+	}
+	calls := fake.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("got %d driver calls, want %d: %+v", len(calls), len(want), calls)
+	}
+	for i, w := range want {
+		conn := calls[i].Conn
+		if conn.Host != w.host || conn.Username != w.user || conn.Password != w.pass {
+			t.Errorf("call %d reached %+v, want %s as %s/%s", i, conn, w.host, w.user, w.pass)
+		}
+	}
+}
+
+func TestToggleCredentialStoreFailure(t *testing.T) {
+	store, table := newTestStore(t)
+	if err := store.Put(context.Background(), 1, secrets.Credentials{Username: "u", Password: "p"}); err != nil {
+		t.Fatal(err)
+	}
+	row := table.rows[1]
+	row[0] = "k0" // sealed with a key that is no longer loaded
+	table.rows[1] = row
+
+	db := newMemDevices(Device{ID: 1, UserID: 7, Name: "TV", Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.11"}}`})
+	fake := driverstest.NewFake("fake")
+	r := newTestRouter(db, fake, store, 7)
+
+	if w := toggle(t, r, 1); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), ErrCredentials.Error()) {
+		t.Fatalf("status %d, body %s; want the credentials error", w.Code, w.Body)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("driver was called without credentials: %+v", calls)
+	}
+}
+
+func TestCreateRemovesDeviceWhenCredentialsFail(t *testing.T) {
+	store, table := newTestStore(t)
+	table.err = errors.New("connection reset")
+	db := newMemDevices()
+	r := newTestRouter(db, driverstest.NewFake("fake"), store, 7)
+
+	body := `{"name":"TV","type":"smart_plug","metadata":"{\"fake\":{\"ip\":\"10.0.0.11\",\"username\":\"u\",\"password\":\"p\"}}"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/devices", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, body %s; want 500", w.Code, w.Body)
+	}
+	if len(db.rows) != 0 {
+		t.Errorf("device kept without its credentials: %+v", db.rows)
+	}
+
+	// Once the store works again the retry creates a single device
+	table.err = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/devices", strings.NewReader(body)))
+	if w.Code != http.StatusCreated || len(db.rows) != 1 || len(table.rows) != 1 {
+		t.Errorf("retry: status %d, %d devices, %d secrets", w.Code, len(db.rows), len(table.rows))
+	}
+}
@@ -1,26 +1,43 @@
 package devices
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	integrations_tapo "github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/integrations/tapo"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/secrets"
 )
 
 // Handler handles device HTTP requests.
 type Handler struct {
-	Repo *Repo
+	Repo    *Repo
+	Drivers *drivers.Registry
+	Secrets CredentialStore // nil when no encryption key is configured
+	Control *Controller
+
+	// TopicAllowed reports whether a device of a user may claim an MQTT topic,
+	// e.g. only its own topic of the configured patterns; nil accepts any topic.
+	TopicAllowed func(deviceID, userID int64, topic string) bool
 }
 
 const invalidID = "invalid id"
 const NotFoundDevice = "device not found"
 const unauthorizedError = "unauthorized"
+const credentialsDisabled = "credential storage not configured"
+const reservedTopic = "mqtt_topic is reserved: it contains wildcards or belongs to another device under a configured topic pattern"
 
 // NewHandler creates a new device handler.
-func NewHandler(repo *Repo) *Handler {
-	return &Handler{Repo: repo}
+func NewHandler(repo *Repo, registry *drivers.Registry, store CredentialStore, events realtime.Publisher) *Handler {
+	return &Handler{
+		Repo:    repo,
+		Drivers: registry,
+		Secrets: store,
+		Control: NewController(repo, registry, store, events),
+	}
 }
 
 // RegisterRoutes registers device routes on the Gin engine.
@@ -33,6 +50,9 @@ func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
 	g.DELETE("/:id", h.Delete)
 	g.POST("/:id/toggle", h.Toggle)
 	g.GET("/:id/read", h.ReadPower)
+	g.GET("/:id/credentials", h.GetCredentials)
+	g.PUT("/:id/credentials", h.SetCredentials)
+	g.DELETE("/:id/credentials", h.DeleteCredentials)
 }
 
 // List returns all devices for the authenticated user.
@@ -52,6 +72,9 @@ func (h *Handler) List(c *gin.Context) {
 	if devices == nil {
 		devices = []Device{}
 	}
+	for i := range devices {
+		redact(&devices[i])
+	}
 	c.JSON(http.StatusOK, devices)
 }
 
@@ -82,7 +105,7 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, device)
+	c.JSON(http.StatusOK, redact(device))
 }
 
 // Create adds a new device for the authenticated user.
@@ -98,14 +121,29 @@ func (h *Handler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: name is required"})
 		return
 	}
+	if req.MQTTTopic != nil && *req.MQTTTopic == "" {
+		req.MQTTTopic = nil
+	}
+	// The device has no id yet, so it cannot claim a pattern topic before it is created
+	if !h.topicAllowed(0, userID, req.MQTTTopic) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reservedTopic})
+		return
+	}
+
+	// Credentials never stay in metadata when the encrypted store is available
+	var creds *secrets.Credentials
+	if h.Secrets != nil {
+		req.Metadata, creds = splitCredentials(req.Metadata)
+	}
 
 	device := &Device{
-		UserID:   userID,
-		Name:     req.Name,
-		Room:     req.Room,
-		Type:     req.Type,
-		Status:   "offline",
-		Metadata: req.Metadata,
+		UserID:    userID,
+		Name:      req.Name,
+		Room:      req.Room,
+		Type:      req.Type,
+		Status:    "offline",
+		Metadata:  req.Metadata,
+		MQTTTopic: req.MQTTTopic,
 	}
 
 	id, err := h.Repo.Create(c.Request.Context(), device)
@@ -115,7 +153,17 @@ func (h *Handler) Create(c *gin.Context) {
 	}
 
 	device.ID = id
-	c.JSON(http.StatusCreated, device)
+	if creds != nil {
+		if err := h.Secrets.Put(c.Request.Context(), id, *creds); err != nil {
+			// A device without its credentials is unusable, and a retry would duplicate it
+			if err := h.Repo.Delete(c.Request.Context(), userID, id); err != nil {
+				log.Printf("Warning: failed to remove device %d after storing its credentials failed: %v", id, err)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store device credentials"})
+			return
+		}
+	}
+	c.JSON(http.StatusCreated, redact(device))
 }
 
 // Update modifies an existing device.
@@ -138,6 +186,16 @@ func (h *Handler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
+	if !h.topicAllowed(id, userID, req.MQTTTopic) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reservedTopic})
+		return
+	}
+
+	var creds *secrets.Credentials
+	if h.Secrets != nil && req.Metadata != nil {
+		clean, found := splitCredentials(*req.Metadata)
+		req.Metadata, creds = &clean, found
+	}
 
 	if err := h.Repo.Update(c.Request.Context(), userID, id, &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device"})
@@ -146,12 +204,22 @@ func (h *Handler) Update(c *gin.Context) {
 
 	// Fetch updated device
 	device, err := h.Repo.GetByID(c.Request.Context(), id)
-	if err != nil {
+	if err != nil || device.UserID != userID {
 		c.JSON(http.StatusNotFound, gin.H{"error": NotFoundDevice})
 		return
 	}
 
-	c.JSON(http.StatusOK, device)
+	if creds != nil {
+		if err := h.Secrets.Put(c.Request.Context(), id, *creds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store device credentials"})
+			return
+		}
+	}
+	if req.Status != nil || req.PowerState != nil {
+		h.Control.PublishStatus(device)
+	}
+
+	c.JSON(http.StatusOK, redact(device))
 }
 
 // Delete removes a device by ID.
@@ -177,7 +245,7 @@ func (h *Handler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// Toggle switches device status (online/offline).
+// Toggle switches a physical device on or off, or flips the status of a virtual one.
 func (h *Handler) Toggle(c *gin.Context) {
 	userID, ok := getUserID(c)
 	if !ok {
@@ -192,121 +260,138 @@ func (h *Handler) Toggle(c *gin.Context) {
 		return
 	}
 
-	// Get current device
-	device, err := h.Repo.GetByID(c.Request.Context(), id)
+	device, err := h.Control.DeviceForUser(c.Request.Context(), userID, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": NotFoundDevice})
 		return
 	}
 
-	// Ensure user owns this device
-	if device.UserID != userID {
-		c.JSON(http.StatusNotFound, gin.H{"error": NotFoundDevice})
+	device, err = h.Control.Toggle(c.Request.Context(), device)
+	if err != nil {
+		writeControlError(c, err)
 		return
 	}
 
-	desiredPower := getDesiredPowerState(device.PowerState)
-	connIP, connUser, connPass := parseTapoMetadata(device.Metadata)
+	c.JSON(http.StatusOK, redact(device))
+}
 
-	if err := h.toggleDeviceState(c, id, device, desiredPower, connIP, connUser, connPass); err != nil {
+// ReadPower performs a live read of power consumption from the device if configured.
+func (h *Handler) ReadPower(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	// Fetch updated device
-	device, err = h.Repo.GetByID(c.Request.Context(), id)
+	device, err := h.getDeviceForUser(c, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch updated device"})
 		return
 	}
 
-	c.JSON(http.StatusOK, device)
+	reading, err := h.Control.Read(c.Request.Context(), device)
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"power":        reading.Power,
+		"voltage":      reading.Voltage,
+		"current":      reading.Current,
+		"energy_total": reading.TotalEnergy,
+		"energy_today": reading.TodayEnergy,
+	})
 }
 
-func getDesiredPowerState(currentPowerState *bool) bool {
-	if currentPowerState != nil && *currentPowerState {
-		return false
+// writeControlError maps a Controller error to an HTTP response.
+func writeControlError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrIncompleteConnection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrIncompleteConnection.Error(), "detail": ErrorDetail(err, ErrIncompleteConnection)})
+	case errors.Is(err, ErrNotSwitchable), errors.Is(err, ErrNotConfigured):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrDeviceControl):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrDeviceControl.Error(), "detail": ErrorDetail(err, ErrDeviceControl)})
+	case errors.Is(err, ErrDeviceRead):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrDeviceRead.Error(), "detail": ErrorDetail(err, ErrDeviceRead)})
+	case errors.Is(err, ErrCredentials):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrCredentials.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrStateUpdate.Error()})
 	}
-	return true
 }
 
-func parseTapoMetadata(metadata string) (ip, username, password string) {
-	if metadata == "" {
-		return "", "", ""
+// GetCredentials returns the redacted credentials stored for a device.
+func (h *Handler) GetCredentials(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": unauthorizedError})
+		return
 	}
-
-	var meta map[string]any
-	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
-		return "", "", ""
+	if h.Secrets == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": credentialsDisabled})
+		return
 	}
 
-	tapoData, ok := meta["tapo"].(map[string]any)
-	if !ok {
-		return "", "", ""
+	device, err := h.getDeviceForUser(c, userID)
+	if err != nil {
+		return
 	}
 
-	if ipVal, ok := tapoData["ip"].(string); ok {
-		ip = ipVal
-	}
-	if userVal, ok := tapoData["username"].(string); ok {
-		username = userVal
+	creds, err := h.Secrets.Get(c.Request.Context(), device.ID)
+	if errors.Is(err, secrets.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no credentials stored for device"})
+		return
 	}
-	if passVal, ok := tapoData["password"].(string); ok {
-		password = passVal
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load device credentials"})
+		return
 	}
 
-	return ip, username, password
+	c.JSON(http.StatusOK, creds.Redacted())
 }
 
-func (h *Handler) toggleDeviceState(c *gin.Context, id int64, device *Device, desiredPower bool, connIP, connUser, connPass string) error {
-	if connIP != "" && connUser != "" && connPass != "" {
-		return h.togglePhysicalDevice(c, id, desiredPower, connIP, connUser, connPass)
-	}
-	return h.toggleDatabaseStatus(c, id, device.Status)
-}
-
-func (h *Handler) togglePhysicalDevice(c *gin.Context, id int64, desiredPower bool, connIP, connUser, connPass string) error {
-	// conn := integrations_tapo.Connection{IP: connIP, Username: connUser, Password: connPass}
-	connIP = "192.168.237.52"
-	connUser = "pedrohdcosta@gmail.com"
-	connPass = "gdnz8cti"
-	conn := integrations_tapo.Connection{IP: connIP, Username: connUser, Password: connPass}
-	if err := integrations_tapo.SetPower(c.Request.Context(), conn, desiredPower); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to control physical device", "detail": err.Error()})
-		return err
+// SetCredentials sets or replaces the credentials of a device.
+func (h *Handler) SetCredentials(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": unauthorizedError})
+		return
 	}
-
-	if err := h.Repo.UpdatePowerState(c.Request.Context(), id, desiredPower); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device power state"})
-		return err
+	if h.Secrets == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": credentialsDisabled})
+		return
 	}
 
-	if err := h.Repo.UpdateStatus(c.Request.Context(), id, "online"); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device status"})
-		return err
+	device, err := h.getDeviceForUser(c, userID)
+	if err != nil {
+		return
 	}
 
-	return nil
-}
-
-func (h *Handler) toggleDatabaseStatus(c *gin.Context, id int64, currentStatus string) error {
-	newStatus := "online"
-	if currentStatus == "online" {
-		newStatus = "offline"
+	var req SetCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: password is required"})
+		return
 	}
 
-	if err := h.Repo.UpdateStatus(c.Request.Context(), id, newStatus); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to toggle device status"})
-		return err
+	creds := secrets.Credentials{Username: req.Username, Password: req.Password}
+	if err := h.Secrets.Put(c.Request.Context(), device.ID, creds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store device credentials"})
+		return
 	}
 
-	return nil
+	c.JSON(http.StatusOK, creds.Redacted())
 }
 
-// ReadPower performs a live read of power consumption from the device if configured.
-func (h *Handler) ReadPower(c *gin.Context) {
+// DeleteCredentials removes the stored credentials of a device.
+func (h *Handler) DeleteCredentials(c *gin.Context) {
 	userID, ok := getUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": unauthorizedError})
+		return
+	}
+	if h.Secrets == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": credentialsDisabled})
 		return
 	}
 
@@ -315,20 +400,18 @@ func (h *Handler) ReadPower(c *gin.Context) {
 		return
 	}
 
-	connIP, connUser, connPass := parseTapoMetadata(device.Metadata)
-	if connIP == "" || connUser == "" || connPass == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "device not configured for live reads"})
+	if err := h.Secrets.Delete(c.Request.Context(), device.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete device credentials"})
 		return
 	}
 
-	conn := integrations_tapo.Connection{IP: connIP, Username: connUser, Password: connPass}
-	power, err := integrations_tapo.ReadPower(c.Request.Context(), conn)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read power from device", "detail": err.Error()})
-		return
-	}
+	c.Status(http.StatusNoContent)
+}
 
-	c.JSON(http.StatusOK, gin.H{"power": power})
+// topicAllowed reports whether a device may claim an MQTT topic.
+// Nil and empty topics (no mapping) are always allowed.
+func (h *Handler) topicAllowed(deviceID, userID int64, topic *string) bool {
+	return topic == nil || *topic == "" || h.TopicAllowed == nil || h.TopicAllowed(deviceID, userID, *topic)
 }
 
 func (h *Handler) getDeviceForUser(c *gin.Context, userID int64) (*Device, error) {
@@ -347,7 +430,7 @@ func (h *Handler) getDeviceForUser(c *gin.Context, userID int64) (*Device, error
 
 	if device.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "device not found or not owned by user"})
-		return nil, err
+		return nil, ErrNotFound
 	}
 
 	return device, nil
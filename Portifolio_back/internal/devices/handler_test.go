@@ -0,0 +1,209 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers/driverstest"
+)
+
+// memDevices is an in-memory device table answering the queries of Repo used by the handler.
+type memDevices struct {
+	mu   sync.Mutex
+	rows map[int64]*Device
+}
+
+func newMemDevices(list ...Device) *memDevices {
+	m := &memDevices{rows: make(map[int64]*Device)}
+	for i := range list {
+		d := list[i]
+		m.rows[d.ID] = &d
+	}
+	return m
+}
+
+func (m *memDevices) Exec(_ context.Context, sql string, args ...any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.HasPrefix(sql, "DELETE FROM device") {
+		if d, ok := m.rows[args[0].(int64)]; ok && d.UserID == args[1].(int64) {
+			delete(m.rows, d.ID)
+		}
+		return nil
+	}
+	d, ok := m.rows[args[0].(int64)]
+	if !ok {
+		return nil
+	}
+	switch {
+	case strings.Contains(sql, "SET power_state = $2"):
+		on := args[1].(bool)
+		d.PowerState = &on
+	case strings.Contains(sql, "SET status = $2"):
+		d.Status = args[1].(string)
+	default:
+		return fmt.Errorf("unexpected exec: %s", sql)
+	}
+	return nil
+}
+
+func (m *memDevices) QueryRow(_ context.Context, sql string, args ...any) interface{ Scan(dest ...any) error } {
+	if strings.HasPrefix(sql, "INSERT INTO device") {
+		return m.insert(args)
+	}
+	if !strings.Contains(sql, "FROM device WHERE id = $1") {
+		return errRow{fmt.Errorf("unexpected query: %s", sql)}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.rows[args[0].(int64)]
+	if !ok {
+		return errRow{pgx.ErrNoRows}
+	}
+	return deviceRow{*d}
+}
+
+// insert adds a device from the arguments of Repo.Create and returns its id.
+func (m *memDevices) insert(args []any) idRow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := &Device{ID: int64(len(m.rows) + 1), UserID: args[0].(int64), Name: args[1].(string), Room: args[2].(string),
+		Type: args[3].(string), Status: args[4].(string), Metadata: args[6].(string), MQTTTopic: args[7].(*string)}
+	for m.rows[d.ID] != nil {
+		d.ID++
+	}
+	m.rows[d.ID] = d
+	return idRow(d.ID)
+}
+
+func (m *memDevices) Query(_ context.Context, sql string, _ ...any) (Rows, error) {
+	return nil, fmt.Errorf("unexpected query: %s", sql)
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+type idRow int64
+
+func (r idRow) Scan(dest ...any) error {
+	*dest[0].(*int64) = int64(r)
+	return nil
+}
+
+type deviceRow struct{ d Device }
+
+func (r deviceRow) Scan(dest ...any) error {
+	*dest[0].(*int64) = r.d.ID
+	*dest[1].(*int64) = r.d.UserID
+	*dest[2].(*string) = r.d.Name
+	*dest[3].(*string) = r.d.Room
+	*dest[4].(*string) = r.d.Type
+	*dest[5].(*string) = r.d.Status
+	*dest[6].(**bool) = r.d.PowerState
+	*dest[7].(*string) = r.d.Metadata
+	*dest[8].(**string) = r.d.MQTTTopic
+	*dest[9].(*time.Time) = r.d.CreatedAt
+	*dest[10].(**time.Time) = r.d.LastSeen
+	return nil
+}
+
+// newTestRouter serves the device routes as userID, with every "smart_plug" driven by fake.
+func newTestRouter(db *memDevices, fake *driverstest.Fake, store CredentialStore, userID int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	registry := drivers.NewRegistry()
+	registry.Register("smart_plug", fake)
+
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) {
+		c.Set("sub", strconv.FormatInt(userID, 10))
+	})
+	NewHandler(NewRepo(db), registry, store, nil).RegisterRoutes(api)
+	return r
+}
+
+func toggle(t *testing.T, r *gin.Engine, id int64) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/devices/%d/toggle", id), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestToggleReachesEachDeviceHost(t *testing.T) {
+	db := newMemDevices(
+		Device{ID: 1, UserID: 7, Name: "TV", Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.11"}}`},
+		Device{ID: 2, UserID: 7, Name: "Heater", Type: "smart_plug", Metadata: `{"fake":{"host":"10.0.0.12"}}`},
+	)
+	fake := driverstest.NewFake("fake")
+	r := newTestRouter(db, fake, nil, 7)
+
+	for _, id := range []int64{1, 2} {
+		w := toggle(t, r, id)
+		if w.Code != http.StatusOK {
+			t.Fatalf("toggle device %d: status %d, body %s", id, w.Code, w.Body)
+		}
+		var got Device
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("toggle device %d: %v", id, err)
+		}
+		if !isOn(got.PowerState) || got.Status != "online" {
+			t.Errorf("device %d after toggle: power_state %v, status %q; want on and online", id, got.PowerState, got.Status)
+		}
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d driver calls, want 2: %+v", len(calls), calls)
+	}
+	for i, host := range []string{"10.0.0.11", "10.0.0.12"} {
+		if calls[i].Op != "set_power" || !calls[i].On || calls[i].Conn.Host != host {
+			t.Errorf("call %d = %+v, want set_power on to %s", i, calls[i], host)
+		}
+	}
+}
+
+func TestToggleIncompleteConnection(t *testing.T) {
+	db := newMemDevices(Device{ID: 3, UserID: 7, Name: "Lamp", Type: "smart_plug", Metadata: `{"fake":{}}`})
+	fake := driverstest.NewFake("fake")
+	r := newTestRouter(db, fake, nil, 7)
+
+	w := toggle(t, r, 3)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["error"] != ErrIncompleteConnection.Error() || body["detail"] != "device host is required" {
+		t.Errorf("body = %v", body)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("driver was called for an incomplete connection: %+v", calls)
+	}
+}
+
+func TestToggleOtherUsersDevice(t *testing.T) {
+	db := newMemDevices(Device{ID: 4, UserID: 8, Name: "Fridge", Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.14"}}`})
+	fake := driverstest.NewFake("fake")
+	r := newTestRouter(db, fake, nil, 7)
+
+	if w := toggle(t, r, 4); w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("driver was called for another user's device: %+v", calls)
+	}
+}
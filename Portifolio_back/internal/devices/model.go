@@ -8,20 +8,22 @@ type Device struct {
 	UserID     int64      `json:"user_id"`
 	Name       string     `json:"name"`
 	Room       string     `json:"room,omitempty"`
-	Type       string     `json:"type,omitempty"`      // e.g., "smart_plug", "sensor"
-	Status     string     `json:"status,omitempty"`    // "online" or "offline"
-	PowerState *bool      `json:"power_state"`         // true = on, false = off
-	Metadata   string     `json:"metadata,omitempty"`  // JSON string for additional config
+	Type       string     `json:"type,omitempty"`       // e.g., "smart_plug", "sensor"
+	Status     string     `json:"status,omitempty"`     // "online" or "offline"
+	PowerState *bool      `json:"power_state"`          // true = on, false = off
+	Metadata   string     `json:"metadata,omitempty"`   // JSON string for additional config
+	MQTTTopic  *string    `json:"mqtt_topic,omitempty"` // Custom MQTT topic publishing this device's telemetry
 	CreatedAt  time.Time  `json:"created_at"`
 	LastSeen   *time.Time `json:"last_seen,omitempty"`
 }
 
 // CreateDeviceRequest represents the payload for creating a device.
 type CreateDeviceRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Room     string `json:"room,omitempty"`
-	Type     string `json:"type,omitempty"`
-	Metadata string `json:"metadata,omitempty"`
+	Name      string  `json:"name" binding:"required"`
+	Room      string  `json:"room,omitempty"`
+	Type      string  `json:"type,omitempty"`
+	Metadata  string  `json:"metadata,omitempty"`
+	MQTTTopic *string `json:"mqtt_topic,omitempty"`
 }
 
 // UpdateDeviceRequest represents the payload for updating a device.
@@ -32,4 +34,11 @@ type UpdateDeviceRequest struct {
 	Status     *string `json:"status,omitempty"`
 	PowerState *bool   `json:"power_state,omitempty"`
 	Metadata   *string `json:"metadata,omitempty"`
+	MQTTTopic  *string `json:"mqtt_topic,omitempty"` // "" removes the topic mapping
+}
+
+// SetCredentialsRequest represents the payload for setting device credentials.
+type SetCredentialsRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password" binding:"required"`
 }
@@ -3,6 +3,8 @@ package devices
 import (
 	"context"
 	"errors"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // ErrNotFound is returned when a device is not found.
@@ -40,8 +42,8 @@ func NewRepo(q RowsQuerier) *Repo {
 
 // Create inserts a new device and returns its ID.
 func (r *Repo) Create(ctx context.Context, d *Device) (int64, error) {
-	sql := `INSERT INTO device (user_id, name, room, type, status, power_state, metadata)
-			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	sql := `INSERT INTO device (user_id, name, room, type, status, power_state, metadata, mqtt_topic)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
 	var id int64
 	status := d.Status
 	if status == "" {
@@ -51,27 +53,88 @@ func (r *Repo) Create(ctx context.Context, d *Device) (int64, error) {
 	if d.PowerState != nil {
 		powerState = *d.PowerState
 	}
-	err := r.q.QueryRow(ctx, sql, d.UserID, d.Name, d.Room, d.Type, status, powerState, d.Metadata).Scan(&id)
+	err := r.q.QueryRow(ctx, sql, d.UserID, d.Name, d.Room, d.Type, status, powerState, d.Metadata, d.MQTTTopic).Scan(&id)
 	return id, err
 }
 
-// GetByID returns a device by ID.
+// GetByID returns a device by ID, or ErrNotFound.
 func (r *Repo) GetByID(ctx context.Context, id int64) (*Device, error) {
-	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, created_at, last_seen
+	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, mqtt_topic, created_at, last_seen
 			FROM device WHERE id = $1`
 	var d Device
 	err := r.q.QueryRow(ctx, sql, id).Scan(
-		&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.CreatedAt, &d.LastSeen,
+		&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.MQTTTopic, &d.CreatedAt, &d.LastSeen,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetByMQTTTopic returns the device mapped to a custom MQTT topic, or ErrNotFound.
+func (r *Repo) GetByMQTTTopic(ctx context.Context, topic string) (*Device, error) {
+	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, mqtt_topic, created_at, last_seen
+			FROM device WHERE mqtt_topic = $1`
+	var d Device
+	err := r.q.QueryRow(ctx, sql, topic).Scan(
+		&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.MQTTTopic, &d.CreatedAt, &d.LastSeen,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &d, nil
 }
 
+// ListAll returns every device across all users (used by background workers).
+func (r *Repo) ListAll(ctx context.Context) ([]Device, error) {
+	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, mqtt_topic, created_at, last_seen
+			FROM device ORDER BY id`
+	rws, err := r.q.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rws.Close()
+
+	var out []Device
+	for rws.Next() {
+		var d Device
+		if err := rws.Scan(&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.MQTTTopic, &d.CreatedAt, &d.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rws.Err()
+}
+
+// ListMQTTTopics returns every custom MQTT topic mapped to a device.
+func (r *Repo) ListMQTTTopics(ctx context.Context) ([]string, error) {
+	sql := `SELECT mqtt_topic FROM device WHERE mqtt_topic IS NOT NULL AND mqtt_topic <> ''`
+	rws, err := r.q.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rws.Close()
+
+	var out []string
+	for rws.Next() {
+		var topic string
+		if err := rws.Scan(&topic); err != nil {
+			return nil, err
+		}
+		out = append(out, topic)
+	}
+	return out, rws.Err()
+}
+
 // ListByUser returns all devices for a user.
 func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Device, error) {
-	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, created_at, last_seen
+	sql := `SELECT id, user_id, name, room, type, status, power_state, metadata, mqtt_topic, created_at, last_seen
 			FROM device WHERE user_id = $1 ORDER BY created_at DESC`
 	rws, err := r.q.Query(ctx, sql, userID)
 	if err != nil {
@@ -82,7 +145,7 @@ func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Device, error) {
 	var out []Device
 	for rws.Next() {
 		var d Device
-		if err := rws.Scan(&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.CreatedAt, &d.LastSeen); err != nil {
+		if err := rws.Scan(&d.ID, &d.UserID, &d.Name, &d.Room, &d.Type, &d.Status, &d.PowerState, &d.Metadata, &d.MQTTTopic, &d.CreatedAt, &d.LastSeen); err != nil {
 			return nil, err
 		}
 		out = append(out, d)
@@ -90,7 +153,7 @@ func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Device, error) {
 	return out, rws.Err()
 }
 
-// Update updates a device's fields.
+// Update updates a device's fields. An empty MQTTTopic removes the topic mapping.
 func (r *Repo) Update(ctx context.Context, userID, deviceID int64, req *UpdateDeviceRequest) error {
 	sql := `UPDATE device SET
 			name = COALESCE($3, name),
@@ -98,9 +161,10 @@ func (r *Repo) Update(ctx context.Context, userID, deviceID int64, req *UpdateDe
 			type = COALESCE($5, type),
 			status = COALESCE($6, status),
 			power_state = COALESCE($7, power_state),
-			metadata = COALESCE($8, metadata)
+			metadata = COALESCE($8, metadata),
+			mqtt_topic = NULLIF(COALESCE($9, mqtt_topic), '')
 			WHERE id = $1 AND user_id = $2`
-	return r.q.Exec(ctx, sql, deviceID, userID, req.Name, req.Room, req.Type, req.Status, req.PowerState, req.Metadata)
+	return r.q.Exec(ctx, sql, deviceID, userID, req.Name, req.Room, req.Type, req.Status, req.PowerState, req.Metadata, req.MQTTTopic)
 }
 
 // UpdateLastSeen updates the last_seen timestamp.
@@ -115,6 +179,12 @@ func (r *Repo) UpdatePowerState(ctx context.Context, deviceID int64, powerState
 	return r.q.Exec(ctx, sql, deviceID, powerState)
 }
 
+// UpdateMetadata replaces the metadata of a device.
+func (r *Repo) UpdateMetadata(ctx context.Context, deviceID int64, metadata string) error {
+	sql := `UPDATE device SET metadata = $2 WHERE id = $1`
+	return r.q.Exec(ctx, sql, deviceID, metadata)
+}
+
 // UpdateStatus updates the status (online/offline) of a device.
 func (r *Repo) UpdateStatus(ctx context.Context, deviceID int64, status string) error {
 	sql := `UPDATE device SET status = $2 WHERE id = $1`
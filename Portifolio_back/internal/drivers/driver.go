@@ -0,0 +1,124 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrUnsupported is returned by drivers for operations the hardware cannot perform.
+var ErrUnsupported = errors.New("operation not supported by device driver")
+
+// Connection holds what a driver needs to reach a physical device.
+// It is parsed from the driver's block in Device.Metadata.
+type Connection struct {
+	Host     string
+	Username string
+	Password string
+	Channel  int // relay/switch index on multi-channel devices
+}
+
+// Reading is a live measurement returned by a driver.
+type Reading struct {
+	Power   float64  // Watts
+	Voltage *float64 // Volts (optional)
+	Current *float64 // Amps (optional)
+
+	TotalEnergy *float64 // kWh cumulative counter kept by the device (optional)
+	TodayEnergy *float64 // kWh counted by the device today (optional)
+}
+
+// Capabilities describes which operations a driver supports.
+type Capabilities struct {
+	Switch  bool `json:"switch"`  // can turn the relay on/off
+	Power   bool `json:"power"`   // reports instantaneous power
+	Voltage bool `json:"voltage"` // reports voltage
+	Current bool `json:"current"` // reports current
+}
+
+// Driver controls one brand/protocol of smart plug.
+type Driver interface {
+	// Name is the driver identifier and the Device.Metadata key holding its connection.
+	Name() string
+	ReadPower(ctx context.Context, conn Connection) (*Reading, error)
+	SetPower(ctx context.Context, conn Connection, on bool) error
+	ReadState(ctx context.Context, conn Connection) (bool, error)
+	Capabilities() Capabilities
+	// Validate reports what is missing from a connection before any network call is made.
+	Validate(conn Connection) error
+}
+
+// Registry maps device types to drivers.
+type Registry struct {
+	mu      sync.RWMutex
+	drivers map[string]Driver
+}
+
+// NewRegistry creates an empty driver registry.
+func NewRegistry() *Registry {
+	return &Registry{drivers: make(map[string]Driver)}
+}
+
+// Register associates a device type (Device.Type) with a driver.
+func (r *Registry) Register(deviceType string, d Driver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drivers[deviceType] = d
+}
+
+// Lookup returns the driver registered for a device type.
+func (r *Registry) Lookup(deviceType string) (Driver, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.drivers[deviceType]
+	return d, ok
+}
+
+// HasConnection reports whether the device metadata contains a connection block under key,
+// i.e. the device is meant to be controlled by that driver.
+func HasConnection(metadata, key string) bool {
+	var meta map[string]any
+	if metadata == "" || json.Unmarshal([]byte(metadata), &meta) != nil {
+		return false
+	}
+	_, ok := meta[key].(map[string]any)
+	return ok
+}
+
+// ParseConnection reads the connection block stored under key in the device metadata.
+// The host may be given as "ip" or "host". Missing or invalid metadata yields a zero Connection.
+func ParseConnection(metadata, key string) Connection {
+	var conn Connection
+	if metadata == "" {
+		return conn
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return conn
+	}
+
+	block, ok := meta[key].(map[string]any)
+	if !ok {
+		return conn
+	}
+
+	if v, ok := block["ip"].(string); ok {
+		conn.Host = v
+	}
+	if v, ok := block["host"].(string); ok && conn.Host == "" {
+		conn.Host = v
+	}
+	if v, ok := block["username"].(string); ok {
+		conn.Username = v
+	}
+	if v, ok := block["password"].(string); ok {
+		conn.Password = v
+	}
+	if v, ok := block["channel"].(float64); ok {
+		conn.Channel = int(v)
+	}
+
+	return conn
+}
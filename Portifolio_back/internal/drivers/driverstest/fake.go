@@ -0,0 +1,98 @@
+// Package driverstest provides an in-memory device driver for tests.
+package driverstest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+// Call records one call made to a Fake driver.
+type Call struct {
+	Op   string // "read_power", "set_power" or "read_state"
+	Conn drivers.Connection
+	On   bool // requested state for "set_power"
+}
+
+// Fake is an in-memory drivers.Driver. It tracks relay state per host
+// and records every call so tests can assert which endpoint was reached.
+type Fake struct {
+	DriverName string
+	Caps       drivers.Capabilities
+	Power      float64 // value returned by ReadPower
+	Err        error   // when set, every operation fails with it
+
+	mu     sync.Mutex
+	states map[string]bool
+	calls  []Call
+}
+
+// NewFake creates a fake driver that supports every capability.
+func NewFake(name string) *Fake {
+	return &Fake{
+		DriverName: name,
+		Caps:       drivers.Capabilities{Switch: true, Power: true, Voltage: true, Current: true},
+		states:     make(map[string]bool),
+	}
+}
+
+// Name returns the configured driver name.
+func (f *Fake) Name() string { return f.DriverName }
+
+// Capabilities returns the configured capabilities.
+func (f *Fake) Capabilities() drivers.Capabilities { return f.Caps }
+
+// Validate requires a host.
+func (f *Fake) Validate(conn drivers.Connection) error {
+	if conn.Host == "" {
+		return errors.New("device host is required")
+	}
+	return nil
+}
+
+// ReadPower returns the configured power value.
+func (f *Fake) ReadPower(_ context.Context, conn drivers.Connection) (*drivers.Reading, error) {
+	f.record(Call{Op: "read_power", Conn: conn})
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return &drivers.Reading{Power: f.Power}, nil
+}
+
+// SetPower stores the requested state for the connection's host.
+func (f *Fake) SetPower(_ context.Context, conn drivers.Connection, on bool) error {
+	f.record(Call{Op: "set_power", Conn: conn, On: on})
+	if f.Err != nil {
+		return f.Err
+	}
+	f.mu.Lock()
+	f.states[conn.Host] = on
+	f.mu.Unlock()
+	return nil
+}
+
+// ReadState returns the last state set for the connection's host.
+func (f *Fake) ReadState(_ context.Context, conn drivers.Connection) (bool, error) {
+	f.record(Call{Op: "read_state", Conn: conn})
+	if f.Err != nil {
+		return false, f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.states[conn.Host], nil
+}
+
+// Calls returns a copy of the recorded calls.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+func (f *Fake) record(c Call) {
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+	f.mu.Unlock()
+}
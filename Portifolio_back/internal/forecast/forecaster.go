@@ -0,0 +1,313 @@
+package forecast
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const (
+	historyDays     = 28    // full days before today used to fit each device
+	averageDays     = 7     // window of the moving average
+	minSeasonalDays = 14    // full days needed before weekday factors are used
+	confidence      = 0.9   // of the projection band
+	zScore          = 1.645 // two-sided z for confidence
+)
+
+// EnergySource integrates telemetry into energy slots (satisfied by telemetry.Repo).
+type EnergySource interface {
+	EnergySlots(ctx context.Context, q *telemetry.AggregateQuery, step time.Duration) ([]telemetry.EnergySlot, error)
+}
+
+// CostCalculator prices energy slots, returning nil when the user has no
+// tariff (satisfied by tariffs.Engine).
+type CostCalculator interface {
+	Cost(ctx context.Context, userID int64, slots []telemetry.EnergySlot) ([]float64, error)
+}
+
+// Forecaster projects each user's billing cycle from their devices' history.
+type Forecaster struct {
+	Repo   *Repo
+	Energy EnergySource
+	Costs  CostCalculator // nil leaves costs out
+}
+
+// NewForecaster creates a forecaster.
+func NewForecaster(repo *Repo, energy EnergySource, costs CostCalculator) *Forecaster {
+	return &Forecaster{Repo: repo, Energy: energy, Costs: costs}
+}
+
+// Forecast projects the user's current billing cycle as of now. Each device's
+// expected daily energy is the moving average of its last full days scaled
+// by a day-of-week factor once there are two weeks of history; the band
+// comes from how far past days strayed from that expectation. Remaining energy
+// is priced at the rate the device actually paid over the history.
+func (f *Forecaster) Forecast(ctx context.Context, userID int64, now time.Time) (*Forecast, error) {
+	c, err := f.Repo.GetCycle(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	local := now.In(loc)
+	start, end := cycleBounds(local, c.StartDay)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	from := today.AddDate(0, 0, -historyDays)
+	if start.Before(from) {
+		from = start
+	}
+
+	slots, err := f.Energy.EnergySlots(ctx, &telemetry.AggregateQuery{UserID: userID, From: from, To: now, Location: loc}, telemetry.SlotStep)
+	if err != nil {
+		return nil, err
+	}
+	var costs []float64
+	if f.Costs != nil {
+		if costs, err = f.Costs.Cost(ctx, userID, slots); err != nil {
+			return nil, err
+		}
+	}
+	names, err := f.Repo.deviceNames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Daily energy of each device, today (partial) last
+	todayIdx := dayIndex(from, today)
+	history := map[int64]*deviceHistory{}
+	for i, s := range slots {
+		h, ok := history[s.DeviceID]
+		if !ok {
+			h = &deviceHistory{daily: make([]float64, todayIdx+1), first: todayIdx}
+			history[s.DeviceID] = h
+		}
+		d := dayIndex(from, s.Start.In(loc))
+		if d < 0 || d > todayIdx {
+			continue
+		}
+		h.daily[d] += s.Energy
+		h.first = min(h.first, d)
+		h.energy += s.Energy
+		if !s.Start.Before(start) {
+			h.cycle.used += s.Energy
+		}
+		if costs != nil {
+			h.cost += costs[i]
+			if !s.Start.Before(start) {
+				h.cycle.cost += costs[i]
+			}
+		}
+	}
+
+	// Days left: the rest of today and every full day until the cycle ends
+	todayLeft := float64(today.AddDate(0, 0, 1).Sub(local)) / float64(24*time.Hour)
+	var ahead []time.Weekday
+	for d := today.AddDate(0, 0, 1); d.Before(end); d = d.AddDate(0, 0, 1) {
+		ahead = append(ahead, d.Weekday())
+	}
+	elapsedToday := 1 - todayLeft
+
+	// Household rate, for devices that used no energy in the history
+	var energy, cost float64
+	for _, h := range history {
+		energy += h.energy
+		cost += h.cost
+	}
+	householdRate := 0.0
+	if energy > 0 {
+		householdRate = cost / energy
+	}
+
+	out := &Forecast{
+		CycleStart:  start,
+		CycleEnd:    end,
+		GeneratedAt: now,
+		Timezone:    c.Timezone,
+		DaysElapsed: local.Sub(start).Hours() / 24,
+		DaysInCycle: float64(dayIndex(start, end)),
+		Confidence:  confidence,
+		Devices:     []DeviceForecast{},
+	}
+	var total estimate
+	for id, h := range history {
+		m := fit(h.daily, h.first, todayIdx, elapsedToday, func(i int) time.Weekday { return from.AddDate(0, 0, i).Weekday() })
+
+		e := h.cycle
+		e.remaining = todayLeft * m.expected(today.Weekday())
+		for _, w := range ahead {
+			e.remaining += m.expected(w)
+		}
+		e.variance = m.sigma * m.sigma * (todayLeft + float64(len(ahead)))
+		rate := householdRate
+		if h.energy > 0 {
+			rate = h.cost / h.energy
+		}
+		e.remainingCost = e.remaining * rate
+		e.costVariance = e.variance * rate * rate
+		total.add(&e)
+
+		out.Devices = append(out.Devices, DeviceForecast{
+			DeviceID:     id,
+			Name:         names[id],
+			DailyAverage: m.average,
+			Projection:   e.projection(costs != nil),
+		})
+	}
+	out.Total = total.projection(costs != nil)
+	sort.Slice(out.Devices, func(i, j int) bool {
+		return out.Devices[i].ProjectedEnergy > out.Devices[j].ProjectedEnergy
+	})
+	return out, nil
+}
+
+// deviceHistory is the energy a device used per day since the start of the
+// history (first is the first day with readings) and in the current cycle.
+type deviceHistory struct {
+	daily  []float64
+	first  int
+	energy float64 // kWh over the whole history
+	cost   float64 // R$ over the whole history
+	cycle  estimate
+}
+
+// estimate accumulates the used and remaining energy and cost of a cycle;
+// the variances of independent devices add up.
+type estimate struct {
+	used, remaining, variance         float64 // kWh
+	cost, remainingCost, costVariance float64 // R$
+}
+
+func (e *estimate) add(o *estimate) {
+	e.used += o.used
+	e.remaining += o.remaining
+	e.variance += o.variance
+	e.cost += o.cost
+	e.remainingCost += o.remainingCost
+	e.costVariance += o.costVariance
+}
+
+// projection turns the estimate into values with a confidence band that
+// never drops below what was already used.
+func (e *estimate) projection(withCost bool) Projection {
+	band := zScore * math.Sqrt(e.variance)
+	p := Projection{
+		Energy:          e.used,
+		ProjectedEnergy: e.used + e.remaining,
+		EnergyLow:       e.used + math.Max(0, e.remaining-band),
+		EnergyHigh:      e.used + e.remaining + band,
+	}
+	if withCost {
+		band := zScore * math.Sqrt(e.costVariance)
+		cost, projected := e.cost, e.cost+e.remainingCost
+		low, high := e.cost+math.Max(0, e.remainingCost-band), projected+band
+		p.Cost, p.ProjectedCost, p.CostLow, p.CostHigh = &cost, &projected, &low, &high
+	}
+	return p
+}
+
+// model is a device's expected daily energy (kWh) and its spread.
+type model struct {
+	average float64    // moving average of the last full days
+	factors [7]float64 // day-of-week multipliers
+	sigma   float64    // standard deviation of a day around its expectation
+}
+
+func (m *model) expected(w time.Weekday) float64 {
+	return m.average * m.factors[w]
+}
+
+// fit models the full days daily[first:today] of a device. Without a full day
+// yet, today's rate so far stands in for the average and is fully uncertain.
+func fit(daily []float64, first, today int, elapsedToday float64, weekday func(i int) time.Weekday) model {
+	m := model{factors: [7]float64{1, 1, 1, 1, 1, 1, 1}}
+	lo := max(first, today-historyDays)
+	days := daily[lo:today]
+	if len(days) == 0 {
+		if elapsedToday >= 1.0/24 {
+			m.average = daily[today] / elapsedToday
+		}
+		m.sigma = m.average
+		return m
+	}
+
+	m.average = mean(days[max(0, len(days)-averageDays):])
+
+	if len(days) >= minSeasonalDays {
+		if overall := mean(days); overall > 0 {
+			var sum [7]float64
+			var n [7]int
+			for i, v := range days {
+				w := weekday(lo + i)
+				sum[w] += v
+				n[w]++
+			}
+			for w := range m.factors {
+				if n[w] > 0 {
+					m.factors[w] = sum[w] / float64(n[w]) / overall
+				}
+			}
+		}
+	}
+
+	// Spread of each day around the expectation made from the week before it
+	var sq float64
+	var k int
+	for i := averageDays; i < len(days); i++ {
+		r := days[i] - mean(days[i-averageDays:i])*m.factors[weekday(lo+i)]
+		sq += r * r
+		k++
+	}
+	switch {
+	case k >= 3:
+		m.sigma = math.Sqrt(sq / float64(k))
+	case len(days) >= 2:
+		sq = 0 // too few residuals: use the plain spread of the days instead
+		avg := mean(days)
+		for _, v := range days {
+			sq += (v - avg) * (v - avg)
+		}
+		m.sigma = math.Sqrt(sq / float64(len(days)-1))
+	default:
+		m.sigma = m.average
+	}
+	return m
+}
+
+// cycleBounds returns the billing cycle holding t: it starts at midnight of
+// startDay (clamped to the month's last day) and ends when the next one starts.
+func cycleBounds(t time.Time, startDay int) (time.Time, time.Time) {
+	start := cycleDay(t.Year(), t.Month(), startDay, t.Location())
+	if start.After(t) {
+		start = cycleDay(t.Year(), t.Month()-1, startDay, t.Location())
+	}
+	return start, cycleDay(start.Year(), start.Month()+1, startDay, t.Location())
+}
+
+func cycleDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// dayIndex returns the number of calendar days from from to t.
+func dayIndex(from, t time.Time) int {
+	fy, fm, fd := from.Date()
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+func mean(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
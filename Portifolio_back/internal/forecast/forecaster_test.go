@@ -0,0 +1,20 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFitSigmaWithFewResiduals(t *testing.T) {
+	weekday := func(i int) time.Weekday { return time.Weekday(i % 7) }
+
+	// Eight full days leave a single residual (day 8 against the week before it),
+	// so sigma falls back to the sample spread of the days alone.
+	daily := []float64{1, 1, 1, 1, 1, 1, 1, 5, 0.4}
+	m := fit(daily, 0, 8, 0.5, weekday)
+
+	if want := math.Sqrt(2); math.Abs(m.sigma-want) > 1e-9 {
+		t.Errorf("sigma = %v, want %v", m.sigma, want)
+	}
+}
@@ -0,0 +1,98 @@
+package forecast
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles billing cycle and forecast HTTP requests.
+type Handler struct {
+	Forecaster *Forecaster
+}
+
+// NewHandler creates a new forecast handler.
+func NewHandler(forecaster *Forecaster) *Handler {
+	return &Handler{Forecaster: forecaster}
+}
+
+// RegisterRoutes registers billing cycle and forecast routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing-cycle", h.GetCycle)
+	r.PUT("/billing-cycle", h.SetCycle)
+	r.GET("/forecast", h.Forecast)
+}
+
+// GetCycle returns the user's billing cycle (the defaults when not set).
+func (h *Handler) GetCycle(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cycle, err := h.Forecaster.Repo.GetCycle(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch billing cycle"})
+		return
+	}
+	c.JSON(http.StatusOK, cycle)
+}
+
+// SetCycle sets the day of the month the user's billing cycle starts.
+func (h *Handler) SetCycle(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req CycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: start_day must be between 1 and 31"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cycle, err := h.Forecaster.Repo.SetCycle(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save billing cycle"})
+		return
+	}
+	c.JSON(http.StatusOK, cycle)
+}
+
+// Forecast projects the energy and cost of the current billing cycle, per
+// device and in total.
+func (h *Handler) Forecast(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	f, err := h.Forecaster.Forecast(c.Request.Context(), userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute forecast"})
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,71 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults used while the user has not set a billing cycle.
+const (
+	defaultStartDay = 1
+	defaultTimezone = "America/Sao_Paulo"
+)
+
+// Cycle is a user's billing cycle setting.
+type Cycle struct {
+	UserID    int64      `json:"user_id"`
+	StartDay  int        `json:"start_day"` // day of the month the utility reads the meter
+	Timezone  string     `json:"timezone"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // nil while the defaults are in use
+}
+
+// CycleRequest is the payload for setting the billing cycle.
+type CycleRequest struct {
+	StartDay int    `json:"start_day" binding:"required,min=1,max=31"`
+	Timezone string `json:"timezone"`
+}
+
+// Validate checks the timezone and fills the default.
+func (r *CycleRequest) Validate() error {
+	if r.Timezone == "" {
+		r.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	return nil
+}
+
+// Forecast projects the energy and cost of the current billing cycle.
+type Forecast struct {
+	CycleStart  time.Time        `json:"cycle_start"`
+	CycleEnd    time.Time        `json:"cycle_end"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Timezone    string           `json:"timezone"`
+	DaysElapsed float64          `json:"days_elapsed"`
+	DaysInCycle float64          `json:"days_in_cycle"`
+	Confidence  float64          `json:"confidence"` // of the low/high band
+	Total       Projection       `json:"total"`
+	Devices     []DeviceForecast `json:"devices"`
+}
+
+// Projection is the energy (kWh) and cost (R$) used so far in the cycle and
+// projected for its end. Costs are omitted when the user has no tariff.
+type Projection struct {
+	Energy          float64  `json:"energy"`
+	ProjectedEnergy float64  `json:"projected_energy"`
+	EnergyLow       float64  `json:"energy_low"`
+	EnergyHigh      float64  `json:"energy_high"`
+	Cost            *float64 `json:"cost,omitempty"`
+	ProjectedCost   *float64 `json:"projected_cost,omitempty"`
+	CostLow         *float64 `json:"cost_low,omitempty"`
+	CostHigh        *float64 `json:"cost_high,omitempty"`
+}
+
+// DeviceForecast is the projection of one device.
+type DeviceForecast struct {
+	DeviceID     int64   `json:"device_id"`
+	Name         string  `json:"name"`
+	DailyAverage float64 `json:"daily_average"` // kWh/day, mean of the last 7 full days
+	Projection
+}
@@ -0,0 +1,86 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for billing cycles.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new forecast repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// GetCycle returns the user's billing cycle, or the defaults when none is set.
+func (r *Repo) GetCycle(ctx context.Context, userID int64) (*Cycle, error) {
+	sql := `SELECT user_id, start_day, timezone, updated_at FROM billing_cycle WHERE user_id = $1`
+	c := Cycle{}
+	err := r.q.QueryRow(ctx, sql, userID).Scan(&c.UserID, &c.StartDay, &c.Timezone, &c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Cycle{UserID: userID, StartDay: defaultStartDay, Timezone: defaultTimezone}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetCycle creates or replaces the user's billing cycle.
+func (r *Repo) SetCycle(ctx context.Context, userID int64, req *CycleRequest) (*Cycle, error) {
+	sql := `INSERT INTO billing_cycle (user_id, start_day, timezone) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO UPDATE
+			SET start_day = EXCLUDED.start_day, timezone = EXCLUDED.timezone, updated_at = NOW()
+			RETURNING user_id, start_day, timezone, updated_at`
+	c := Cycle{}
+	if err := r.q.QueryRow(ctx, sql, userID, req.StartDay, req.Timezone).Scan(&c.UserID, &c.StartDay, &c.Timezone, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// deviceNames returns the name of each of the user's devices.
+func (r *Repo) deviceNames(ctx context.Context, userID int64) (map[int64]string, error) {
+	sql := `SELECT id, name FROM device WHERE user_id = $1`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		out[id] = name
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,97 @@
+package mqtt
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTopic is the topic pattern subscribed to when MQTT_TOPICS is not set.
+const DefaultTopic = "home/{device_id}/power"
+
+// Config holds the MQTT broker connection and subscription settings.
+type Config struct {
+	BrokerURL      string        // e.g. "tcp://localhost:1883" or "ssl://broker:8883"
+	ClientID       string        // MQTT client identifier
+	Username       string        // Broker username (optional)
+	Password       string        // Broker password (optional)
+	Topics         []string      // Topic patterns, may contain {device_id} and {user_id} placeholders
+	QoS            byte          // Subscription QoS (0, 1 or 2)
+	ConnectTimeout time.Duration // Timeout for the initial connection
+	MaxReconnect   time.Duration // Upper bound for the reconnect backoff
+}
+
+// ConfigFromEnv builds a Config from environment variables.
+// MQTT ingestion is disabled when MQTT_BROKER_URL is empty.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		BrokerURL:      os.Getenv("MQTT_BROKER_URL"),
+		ClientID:       os.Getenv("MQTT_CLIENT_ID"),
+		Username:       os.Getenv("MQTT_USERNAME"),
+		Password:       os.Getenv("MQTT_PASSWORD"),
+		Topics:         []string{DefaultTopic},
+		QoS:            1,
+		ConnectTimeout: 10 * time.Second,
+		MaxReconnect:   time.Minute,
+	}
+
+	if cfg.ClientID == "" {
+		cfg.ClientID = "energy-controller-api"
+	}
+
+	if topics := os.Getenv("MQTT_TOPICS"); topics != "" {
+		cfg.Topics = nil
+		for _, t := range strings.Split(topics, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				cfg.Topics = append(cfg.Topics, t)
+			}
+		}
+	}
+
+	if q := os.Getenv("MQTT_QOS"); q != "" {
+		if parsed, err := strconv.Atoi(q); err == nil && parsed >= 0 && parsed <= 2 {
+			cfg.QoS = byte(parsed)
+		}
+	}
+
+	return cfg
+}
+
+// Reserved reports whether a device topic gets no subscription of its own: it
+// contains wildcards or is already delivered through a configured pattern.
+func (c Config) Reserved(topic string) bool {
+	if strings.ContainsAny(topic, "+#") {
+		return true
+	}
+	for _, pattern := range c.Topics {
+		if filterMatches(subscriptionFilter(pattern), topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Claimable reports whether a device of userID may map a topic. Topics with
+// wildcards never can; a topic covered by the configured patterns only by the
+// device (and user) it names, which is how a device opts into its pattern topic.
+func (c Config) Claimable(deviceID, userID int64, topic string) bool {
+	if strings.ContainsAny(topic, "+#") {
+		return false
+	}
+	for _, pattern := range c.Topics {
+		if !filterMatches(subscriptionFilter(pattern), topic) {
+			continue
+		}
+		m, ok := matchTopic(pattern, topic)
+		if !ok || m.DeviceID != deviceID || (m.UserID != 0 && m.UserID != userID) {
+			return false
+		}
+	}
+	return true
+}
+
+// Enabled reports whether a broker is configured.
+func (c Config) Enabled() bool {
+	return c.BrokerURL != ""
+}
@@ -0,0 +1,81 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// payload is the JSON document published by devices.
+// Timestamp accepts RFC3339 strings or Unix seconds.
+type payload struct {
+	Power     *float64        `json:"power"`
+	Voltage   *float64        `json:"voltage,omitempty"`
+	Current   *float64        `json:"current,omitempty"`
+	Total     *float64        `json:"energy_total,omitempty"` // kWh lifetime counter
+	Today     *float64        `json:"energy_today,omitempty"` // kWh counted today
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+}
+
+// parsePayload converts a message body into a telemetry reading.
+// Both a JSON object and a bare number (power in Watts) are accepted.
+func parsePayload(deviceID int64, body []byte, received time.Time) (*telemetry.Telemetry, error) {
+	raw := strings.TrimSpace(string(body))
+	if raw == "" {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	// Plain numeric payload, e.g. "152.3"
+	if power, err := strconv.ParseFloat(raw, 64); err == nil {
+		return &telemetry.Telemetry{DeviceID: deviceID, Power: power, Timestamp: received}, nil
+	}
+
+	var p payload
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if p.Power == nil {
+		return nil, fmt.Errorf("invalid payload: power is required")
+	}
+
+	ts, err := parseTimestamp(p.Timestamp, received)
+	if err != nil {
+		return nil, err
+	}
+
+	return &telemetry.Telemetry{
+		DeviceID:    deviceID,
+		Power:       *p.Power,
+		Voltage:     p.Voltage,
+		Current:     p.Current,
+		EnergyTotal: p.Total,
+		EnergyToday: p.Today,
+		Timestamp:   ts,
+	}, nil
+}
+
+func parseTimestamp(raw json.RawMessage, fallback time.Time) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return fallback, nil
+	}
+
+	var unix float64
+	if err := json.Unmarshal(raw, &unix); err == nil {
+		sec := int64(unix)
+		return time.Unix(sec, int64((unix-float64(sec))*1e9)), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp")
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return ts, nil
+}
@@ -0,0 +1,236 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// topicRefreshInterval controls how often device topic mappings are reloaded.
+const topicRefreshInterval = time.Minute
+
+// messageTimeout bounds the database work done for a single message.
+const messageTimeout = 10 * time.Second
+
+// ErrUnknownDevice is returned when a topic cannot be mapped to a device.
+var ErrUnknownDevice = errors.New("no device mapped to topic")
+
+// ErrNotOwner is returned when a topic mapping names another device or user
+// under a configured pattern, e.g. a mapping made before the pattern existed.
+var ErrNotOwner = errors.New("topic belongs to another device under a topic pattern")
+
+// TelemetryStore persists ingested readings (satisfied by telemetry.Repo).
+type TelemetryStore interface {
+	Create(ctx context.Context, t *telemetry.Telemetry) (int64, error)
+	UpdateDeviceLastSeenAndStatus(ctx context.Context, deviceID int64) (bool, error)
+}
+
+// DeviceStore resolves topics to devices (satisfied by devices.Repo).
+// Lookups of a missing device return devices.ErrNotFound.
+type DeviceStore interface {
+	GetByMQTTTopic(ctx context.Context, topic string) (*devices.Device, error)
+	ListMQTTTopics(ctx context.Context) ([]string, error)
+}
+
+// Subscriber consumes telemetry from an MQTT broker and writes it to the database.
+type Subscriber struct {
+	cfg       Config
+	telemetry TelemetryStore
+	devices   DeviceStore
+	events    realtime.Publisher
+	alerts    telemetry.AlertEvaluator
+	client    paho.Client
+
+	mu         sync.Mutex
+	subscribed map[string]bool // device topic mappings currently subscribed
+	ctx        context.Context
+}
+
+// NewSubscriber creates a subscriber; call Start to connect.
+// events and alerts may be nil.
+func NewSubscriber(cfg Config, ts TelemetryStore, ds DeviceStore, events realtime.Publisher, alerts telemetry.AlertEvaluator) *Subscriber {
+	return &Subscriber{
+		cfg:        cfg,
+		telemetry:  ts,
+		devices:    ds,
+		events:     events,
+		alerts:     alerts,
+		subscribed: make(map[string]bool),
+		ctx:        context.Background(),
+	}
+}
+
+// Start connects to the broker and keeps the subscriptions alive until ctx is done.
+// Connection failures are retried in the background with exponential backoff.
+func (s *Subscriber) Start(ctx context.Context) error {
+	s.ctx = ctx
+
+	opts := paho.NewClientOptions().
+		AddBroker(s.cfg.BrokerURL).
+		SetClientID(s.cfg.ClientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetCleanSession(false).
+		SetOrderMatters(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(s.cfg.ConnectTimeout).
+		SetMaxReconnectInterval(s.cfg.MaxReconnect).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		}).
+		SetReconnectingHandler(func(_ paho.Client, _ *paho.ClientOptions) {
+			log.Printf("MQTT reconnecting to %s", s.cfg.BrokerURL)
+		})
+
+	s.client = paho.NewClient(opts)
+	token := s.client.Connect()
+	// With ConnectRetry the token only completes once connected; don't block startup on it.
+	if token.WaitTimeout(s.cfg.ConnectTimeout) && token.Error() != nil {
+		return fmt.Errorf("mqtt connect: %w", token.Error())
+	}
+
+	go s.refreshLoop(ctx)
+	return nil
+}
+
+// Stop disconnects from the broker.
+func (s *Subscriber) Stop() {
+	if s.client != nil {
+		s.client.Disconnect(250)
+	}
+}
+
+// onConnect (re)subscribes to the configured patterns and device topics.
+// It runs on the first connection and after every reconnect.
+func (s *Subscriber) onConnect(c paho.Client) {
+	log.Printf("MQTT connected to %s", s.cfg.BrokerURL)
+
+	filters := make(map[string]byte)
+	for _, pattern := range s.cfg.Topics {
+		filters[subscriptionFilter(pattern)] = s.cfg.QoS
+	}
+	if tok := c.SubscribeMultiple(filters, s.onMessage); tok.Wait() && tok.Error() != nil {
+		log.Printf("MQTT subscribe failed: %v", tok.Error())
+	}
+
+	s.mu.Lock()
+	s.subscribed = make(map[string]bool)
+	s.mu.Unlock()
+	s.refreshDeviceTopics()
+}
+
+// refreshLoop periodically subscribes to device topic mappings added since startup.
+func (s *Subscriber) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(topicRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+			return
+		case <-ticker.C:
+			if s.client.IsConnectionOpen() {
+				s.refreshDeviceTopics()
+			}
+		}
+	}
+}
+
+func (s *Subscriber) refreshDeviceTopics() {
+	ctx, cancel := context.WithTimeout(s.ctx, messageTimeout)
+	defer cancel()
+
+	topics, err := s.devices.ListMQTTTopics(ctx)
+	if err != nil {
+		log.Printf("MQTT: failed to load device topics: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		if s.subscribed[topic] || s.cfg.Reserved(topic) {
+			continue
+		}
+		tok := s.client.Subscribe(topic, s.cfg.QoS, s.onMessage)
+		if tok.Wait() && tok.Error() != nil {
+			log.Printf("MQTT subscribe to %s failed: %v", topic, tok.Error())
+			continue
+		}
+		s.subscribed[topic] = true
+	}
+}
+
+func (s *Subscriber) onMessage(_ paho.Client, msg paho.Message) {
+	ctx, cancel := context.WithTimeout(s.ctx, messageTimeout)
+	defer cancel()
+
+	if err := s.HandleMessage(ctx, msg.Topic(), msg.Payload()); err != nil {
+		log.Printf("MQTT: dropped message on %s: %v", msg.Topic(), err)
+	}
+}
+
+// HandleMessage resolves the device for a topic, parses the payload and persists it.
+// It is exported so ingestion can be exercised without a broker.
+func (s *Subscriber) HandleMessage(ctx context.Context, topic string, body []byte) error {
+	device, err := s.resolveDevice(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	t, err := parsePayload(device.ID, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	id, err := s.telemetry.Create(ctx, t)
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry: %w", err)
+	}
+	t.ID = id
+
+	changed, err := s.telemetry.UpdateDeviceLastSeenAndStatus(ctx, device.ID)
+	if err != nil {
+		log.Printf("Warning: failed to update device status for device %d: %v", device.ID, err)
+	}
+	if s.events != nil {
+		if changed {
+			s.events.Publish(realtime.StatusEvent(device.UserID, device.ID, "online", nil))
+		}
+		s.events.Publish(realtime.TelemetryEvent(device.UserID, device.ID, t))
+	}
+	if s.alerts != nil {
+		if err := s.alerts.Evaluate(ctx, device.UserID, device.ID, t.Power, t.Timestamp); err != nil {
+			log.Printf("Warning: failed to evaluate alerts for device %d: %v", device.ID, err)
+		}
+	}
+	return nil
+}
+
+// resolveDevice maps a topic to the device whose mqtt_topic claims it. The
+// device id in a pattern topic is easy to guess, so it never identifies the
+// device on its own: a device opts into its pattern topic by claiming it.
+func (s *Subscriber) resolveDevice(ctx context.Context, topic string) (*devices.Device, error) {
+	d, err := s.devices.GetByMQTTTopic(ctx, topic)
+	if errors.Is(err, devices.ErrNotFound) {
+		return nil, ErrUnknownDevice
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up topic mapping: %w", err)
+	}
+	if !s.cfg.Claimable(d.ID, d.UserID, topic) {
+		return nil, ErrNotOwner
+	}
+	return d, nil
+}
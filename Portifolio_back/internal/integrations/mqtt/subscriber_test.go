@@ -0,0 +1,157 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+type fakeTelemetry struct {
+	created []telemetry.Telemetry
+}
+
+func (f *fakeTelemetry) Create(_ context.Context, t *telemetry.Telemetry) (int64, error) {
+	f.created = append(f.created, *t)
+	return int64(len(f.created)), nil
+}
+
+func (f *fakeTelemetry) UpdateDeviceLastSeenAndStatus(context.Context, int64) (bool, error) {
+	return false, nil
+}
+
+type fakeDevices struct {
+	byTopic map[string]*devices.Device
+	err     error // returned by every lookup when set
+}
+
+func (f *fakeDevices) GetByMQTTTopic(_ context.Context, topic string) (*devices.Device, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if d, ok := f.byTopic[topic]; ok {
+		return d, nil
+	}
+	return nil, devices.ErrNotFound
+}
+
+func (f *fakeDevices) ListMQTTTopics(context.Context) ([]string, error) {
+	var out []string
+	for t := range f.byTopic {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func TestHandleMessage(t *testing.T) {
+	optedIn := &devices.Device{ID: 5, UserID: 1}
+	attacker := &devices.Device{ID: 9, UserID: 2}
+	custom := &devices.Device{ID: 7, UserID: 2}
+	store := &fakeDevices{
+		byTopic: map[string]*devices.Device{
+			"home/5/power":   optedIn,
+			"home/6/power":   attacker, // legacy mapping over another device's pattern topic
+			"users/2/7":      custom,
+			"users/1/9":      attacker,
+			"garage/meter/1": custom,
+		},
+	}
+	cfg := Config{Topics: []string{DefaultTopic, "users/{user_id}/{device_id}"}}
+
+	tests := []struct {
+		name   string
+		topic  string
+		device int64
+		err    error
+	}{
+		{"pattern topic claimed by its device", "home/5/power", 5, nil},
+		{"pattern topic with user claimed by its device", "users/2/7", 7, nil},
+		{"custom mapping", "garage/meter/1", 7, nil},
+		{"pattern topic of a device that did not opt in", "home/8/power", 0, ErrUnknownDevice},
+		{"mapping over another device's pattern topic", "home/6/power", 0, ErrNotOwner},
+		{"mapping over another user's pattern topic", "users/1/9", 0, ErrNotOwner},
+		{"unmapped topic", "garage/meter/2", 0, ErrUnknownDevice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &fakeTelemetry{}
+			s := NewSubscriber(cfg, ts, store, nil, nil)
+
+			err := s.HandleMessage(context.Background(), tt.topic, []byte(`{"power": 12.5}`))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("HandleMessage(%q) error = %v, want %v", tt.topic, err, tt.err)
+			}
+			if tt.err != nil {
+				if len(ts.created) != 0 {
+					t.Fatalf("stored %d readings for a rejected message", len(ts.created))
+				}
+				return
+			}
+			if len(ts.created) != 1 || ts.created[0].DeviceID != tt.device {
+				t.Fatalf("stored %+v, want one reading of device %d", ts.created, tt.device)
+			}
+		})
+	}
+}
+
+func TestHandleMessageLookupError(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	s := NewSubscriber(Config{Topics: []string{DefaultTopic}}, &fakeTelemetry{}, &fakeDevices{err: dbErr}, nil, nil)
+
+	for _, topic := range []string{"garage/meter/1", "home/5/power"} {
+		err := s.HandleMessage(context.Background(), topic, []byte(`{"power": 1}`))
+		if !errors.Is(err, dbErr) {
+			t.Errorf("HandleMessage(%q) error = %v, want the lookup error", topic, err)
+		}
+	}
+}
+
+func TestConfigClaimable(t *testing.T) {
+	cfg := Config{Topics: []string{DefaultTopic, "users/{user_id}/{device_id}", "meters/+"}}
+
+	tests := []struct {
+		device, user int64
+		topic        string
+		want         bool
+	}{
+		{5, 1, "home/5/power", true},
+		{6, 1, "home/5/power", false},
+		{0, 1, "home/5/power", false}, // a device being created has no pattern topic yet
+		{5, 1, "users/1/5", true},
+		{5, 2, "users/1/5", false},
+		{5, 1, "meters/a", false}, // the pattern names no device
+		{5, 1, "home/+/power", false},
+		{5, 1, "garage/meter/1", true},
+	}
+	for _, tt := range tests {
+		if got := cfg.Claimable(tt.device, tt.user, tt.topic); got != tt.want {
+			t.Errorf("Claimable(%d, %d, %q) = %v, want %v", tt.device, tt.user, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestConfigReserved(t *testing.T) {
+	cfg := Config{Topics: []string{DefaultTopic, "users/{user_id}/{device_id}", "meters/+/{device_id}"}}
+
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"home/5/power", true},
+		{"home/abc/power", true}, // delivered through the pattern's subscription
+		{"users/1/5", true},
+		{"meters/a/3", true},
+		{"home/#", true},
+		{"garage/+", true},
+		{"home/5/power/extra", false},
+		{"garage/meter/1", false},
+		{"users/1", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.Reserved(tt.topic); got != tt.want {
+			t.Errorf("Reserved(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
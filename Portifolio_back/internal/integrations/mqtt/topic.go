@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	deviceIDPlaceholder = "{device_id}"
+	userIDPlaceholder   = "{user_id}"
+)
+
+// topicMatch holds the identifiers extracted from a topic.
+type topicMatch struct {
+	DeviceID int64
+	UserID   int64 // zero when the pattern has no {user_id} segment
+}
+
+// subscriptionFilter converts a topic pattern into an MQTT subscription filter,
+// replacing placeholders with single-level wildcards.
+// "home/{device_id}/power" becomes "home/+/power".
+func subscriptionFilter(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, p := range parts {
+		if p == deviceIDPlaceholder || p == userIDPlaceholder {
+			parts[i] = "+"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// matchTopic checks a concrete topic against a pattern and extracts the ids.
+// Patterns without a {device_id} segment never match.
+func matchTopic(pattern, topic string) (topicMatch, bool) {
+	var m topicMatch
+	pp := strings.Split(pattern, "/")
+	tp := strings.Split(topic, "/")
+	if len(pp) != len(tp) {
+		return m, false
+	}
+
+	hasDevice := false
+	for i, p := range pp {
+		switch p {
+		case deviceIDPlaceholder:
+			id, err := strconv.ParseInt(tp[i], 10, 64)
+			if err != nil || id <= 0 {
+				return m, false
+			}
+			m.DeviceID = id
+			hasDevice = true
+		case userIDPlaceholder:
+			id, err := strconv.ParseInt(tp[i], 10, 64)
+			if err != nil || id <= 0 {
+				return m, false
+			}
+			m.UserID = id
+		case "+":
+			// single-level wildcard, matches any segment
+		default:
+			if p != tp[i] {
+				return m, false
+			}
+		}
+	}
+	return m, hasDevice
+}
+
+// filterMatches reports whether a concrete topic is delivered to a subscription filter.
+func filterMatches(filter, topic string) bool {
+	fp := strings.Split(filter, "/")
+	tp := strings.Split(topic, "/")
+	for i, f := range fp {
+		if f == "#" {
+			return true
+		}
+		if i >= len(tp) || (f != "+" && f != tp[i]) {
+			return false
+		}
+	}
+	return len(fp) == len(tp)
+}
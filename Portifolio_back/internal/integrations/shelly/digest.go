@@ -0,0 +1,133 @@
+package shelly
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge struct {
+	Realm     string
+	Nonce     string
+	Qop       string
+	Algorithm string
+	Opaque    string
+}
+
+// parseDigestChallenge parses a WWW-Authenticate header value.
+// Shelly Gen2 devices send: Digest qop="auth", realm="<device id>", nonce="...", algorithm=SHA-256
+func parseDigestChallenge(header string) (*digestChallenge, error) {
+	const prefix = "digest "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, fmt.Errorf("unsupported auth challenge: %q", header)
+	}
+
+	ch := &digestChallenge{Algorithm: "MD5"}
+	for _, part := range splitParams(header[len(prefix):]) {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(strings.TrimSpace(v), `"`)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "realm":
+			ch.Realm = v
+		case "nonce":
+			ch.Nonce = v
+		case "qop":
+			// Several qop values may be offered; we only implement "auth"
+			for _, q := range strings.Split(v, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					ch.Qop = "auth"
+				}
+			}
+		case "algorithm":
+			ch.Algorithm = v
+		case "opaque":
+			ch.Opaque = v
+		}
+	}
+
+	if ch.Nonce == "" {
+		return nil, fmt.Errorf("auth challenge without nonce")
+	}
+	return ch, nil
+}
+
+// splitParams splits comma-separated auth params, ignoring commas inside quotes.
+func splitParams(s string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ',' && !quoted:
+			out = append(out, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+// authorization builds the Authorization header answering the challenge.
+func (ch *digestChallenge) authorization(method, uri, username, password string, nc int) (string, error) {
+	var newHash func() hash.Hash
+	switch strings.ToUpper(ch.Algorithm) {
+	case "SHA-256":
+		newHash = sha256.New
+	case "MD5", "":
+		newHash = md5.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", ch.Algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	ha1 := h(username + ":" + ch.Realm + ":" + password)
+	ha2 := h(method + ":" + uri)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s`,
+		username, ch.Realm, ch.Nonce, uri, ch.Algorithm)
+
+	if ch.Qop == "auth" {
+		cnonce, err := newCnonce()
+		if err != nil {
+			return "", err
+		}
+		ncStr := fmt.Sprintf("%08x", nc)
+		response := h(strings.Join([]string{ha1, ch.Nonce, ncStr, cnonce, ch.Qop, ha2}, ":"))
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce="%s", response="%s"`, ch.Qop, ncStr, cnonce, response)
+	} else {
+		fmt.Fprintf(&b, `, response="%s"`, h(ha1+":"+ch.Nonce+":"+ha2))
+	}
+
+	if ch.Opaque != "" {
+		fmt.Fprintf(&b, `, opaque="%s"`, ch.Opaque)
+	}
+	return b.String(), nil
+}
+
+func newCnonce() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
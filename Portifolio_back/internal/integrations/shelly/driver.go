@@ -0,0 +1,71 @@
+package shelly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+// DriverName is the registry name and Device.Metadata key for Shelly Gen2 plugs.
+const DriverName = "shelly"
+
+// Driver adapts the Shelly Gen2 RPC API to the drivers.Driver interface.
+type Driver struct{}
+
+// NewDriver creates a Shelly driver.
+func NewDriver() *Driver {
+	return &Driver{}
+}
+
+// Name returns the driver name.
+func (d *Driver) Name() string { return DriverName }
+
+// Capabilities reports what Shelly Plus Plug S supports.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	return drivers.Capabilities{Switch: true, Power: true, Voltage: true, Current: true}
+}
+
+// Validate requires a host; the password is optional since auth can be disabled on the device.
+func (d *Driver) Validate(conn drivers.Connection) error {
+	if conn.Host == "" {
+		return fmt.Errorf("device host is required")
+	}
+	if conn.Channel < 0 {
+		return fmt.Errorf("invalid switch channel %d", conn.Channel)
+	}
+	return nil
+}
+
+// ReadPower reads apower, voltage, current and aenergy from Switch.GetStatus.
+func (d *Driver) ReadPower(ctx context.Context, conn drivers.Connection) (*drivers.Reading, error) {
+	status, err := GetStatus(ctx, toConnection(conn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read power from device: %w", err)
+	}
+	total := status.AEnergy.Total / 1000 // Wh -> kWh
+	return &drivers.Reading{
+		Power:       status.APower,
+		Voltage:     status.Voltage,
+		Current:     status.Current,
+		TotalEnergy: &total,
+	}, nil
+}
+
+// SetPower turns the relay on or off with Switch.Set.
+func (d *Driver) SetPower(ctx context.Context, conn drivers.Connection, on bool) error {
+	return SetPower(ctx, toConnection(conn), on)
+}
+
+// ReadState reports the relay output from Switch.GetStatus.
+func (d *Driver) ReadState(ctx context.Context, conn drivers.Connection) (bool, error) {
+	status, err := GetStatus(ctx, toConnection(conn))
+	if err != nil {
+		return false, fmt.Errorf("failed to read device state: %w", err)
+	}
+	return status.Output, nil
+}
+
+func toConnection(conn drivers.Connection) Connection {
+	return Connection{Host: conn.Host, Password: conn.Password, Channel: conn.Channel}
+}
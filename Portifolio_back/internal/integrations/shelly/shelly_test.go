@@ -0,0 +1,168 @@
+package shelly
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+const (
+	testRealm = "shellyplusplugs-a8032ab1c2d3"
+	testNonce = "1713450000"
+)
+
+// fakeShelly emulates the Gen2 RPC endpoints of a single-relay plug with
+// SHA-256 digest authentication enabled.
+type fakeShelly struct {
+	password string
+
+	mu        sync.Mutex
+	output    bool
+	challenge int // unauthenticated requests answered with a challenge
+}
+
+func (f *fakeShelly) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		f.mu.Lock()
+		f.challenge++
+		f.mu.Unlock()
+		w.Header().Set("WWW-Authenticate", `Digest qop="auth", realm="`+testRealm+`", nonce="`+testNonce+`", algorithm=SHA-256`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if id := r.URL.Query().Get("id"); id != "0" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(rpcError{Code: -105, Message: "Argument 'id', value " + id + " not found!"})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/rpc/Switch.GetStatus":
+		w.Write([]byte(`{"id":0,"source":"HTTP","output":` + boolJSON(f.output) +
+			`,"apower":42.5,"voltage":229.8,"current":0.21,"aenergy":{"total":1500.0}}`))
+	case "/rpc/Switch.Set":
+		was := f.output
+		f.output = r.URL.Query().Get("on") == "true"
+		w.Write([]byte(`{"was_on":` + boolJSON(was) + `}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(rpcError{Code: 404, Message: "No handler for " + r.URL.Path})
+	}
+}
+
+// authorized verifies the digest response the way the device does.
+func (f *fakeShelly) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+	p := map[string]string{}
+	for _, part := range splitParams(header[len("Digest "):]) {
+		k, v, _ := strings.Cut(part, "=")
+		p[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	if p["username"] != DefaultUsername || p["realm"] != testRealm || p["nonce"] != testNonce ||
+		p["algorithm"] != "SHA-256" || p["qop"] != "auth" || p["uri"] != r.URL.RequestURI() {
+		return false
+	}
+	h := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := h(DefaultUsername + ":" + testRealm + ":" + f.password)
+	ha2 := h(r.Method + ":" + p["uri"])
+	return p["response"] == h(strings.Join([]string{ha1, testNonce, p["nc"], p["cnonce"], "auth", ha2}, ":"))
+}
+
+// snapshot returns the relay output and the challenges sent so far.
+func (f *fakeShelly) snapshot() (bool, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.output, f.challenge
+}
+
+func boolJSON(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func newFakeShelly(t *testing.T) (*fakeShelly, drivers.Connection) {
+	t.Helper()
+	dev := &fakeShelly{password: "plug-pass"}
+	srv := httptest.NewServer(dev)
+	t.Cleanup(srv.Close)
+	return dev, drivers.Connection{Host: srv.URL, Password: dev.password}
+}
+
+func TestDriverReadPower(t *testing.T) {
+	dev, conn := newFakeShelly(t)
+
+	r, err := NewDriver().ReadPower(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Power != 42.5 || r.Voltage == nil || *r.Voltage != 229.8 || r.Current == nil || *r.Current != 0.21 {
+		t.Errorf("reading = %+v", r)
+	}
+	if r.TotalEnergy == nil || *r.TotalEnergy != 1.5 {
+		t.Errorf("total energy = %v, want 1.5 kWh", r.TotalEnergy)
+	}
+	if _, challenges := dev.snapshot(); challenges != 1 {
+		t.Errorf("got %d challenges, want 1", challenges)
+	}
+}
+
+func TestDriverSetPower(t *testing.T) {
+	dev, conn := newFakeShelly(t)
+	d := NewDriver()
+	ctx := context.Background()
+
+	for _, on := range []bool{true, false, true} {
+		if err := d.SetPower(ctx, conn, on); err != nil {
+			t.Fatalf("SetPower(%v): %v", on, err)
+		}
+		state, err := d.ReadState(ctx, conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, _ := dev.snapshot(); state != on || output != on {
+			t.Errorf("after SetPower(%v): ReadState = %v, device output = %v", on, state, output)
+		}
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	_, conn := newFakeShelly(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		conn drivers.Connection
+		want string
+	}{
+		{"no password", drivers.Connection{Host: conn.Host}, "authentication required"},
+		{"wrong password", drivers.Connection{Host: conn.Host, Password: "nope"}, "authentication failed"},
+		{"unknown channel", drivers.Connection{Host: conn.Host, Password: conn.Password, Channel: 3}, "Argument 'id', value 3 not found!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDriver().ReadPower(ctx, tt.conn)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package tapo
+
+import (
+	"context"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+// DriverName is the registry name and Device.Metadata key for Tapo plugs.
+const DriverName = "tapo"
+
+// Driver adapts the Tapo integration to the drivers.Driver interface.
+// Sessions are shared by every caller of the driver (HTTP handlers and the poller).
+type Driver struct {
+	sessions *Sessions
+}
+
+// NewDriver creates a Tapo driver with a default session cache.
+func NewDriver() *Driver {
+	return &Driver{sessions: NewSessions(DefaultSessionTTL, DefaultIdleTimeout)}
+}
+
+// Name returns the driver name.
+func (d *Driver) Name() string { return DriverName }
+
+// Capabilities reports what Tapo P1xx plugs support.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	return drivers.Capabilities{Switch: true, Power: true}
+}
+
+// Validate checks the IP and Tapo Cloud credentials are present.
+func (d *Driver) Validate(conn drivers.Connection) error {
+	return validate(toConnection(conn))
+}
+
+// ReadPower reads the current power consumption and, when the plug supports
+// it, the energy counted today. A failed energy read leaves TodayEnergy unset.
+func (d *Driver) ReadPower(ctx context.Context, conn drivers.Connection) (*drivers.Reading, error) {
+	power, err := d.sessions.ReadPower(ctx, toConnection(conn))
+	if err != nil {
+		return nil, err
+	}
+	reading := &drivers.Reading{Power: power}
+	if kwh, err := d.sessions.ReadTodayEnergy(ctx, toConnection(conn)); err == nil {
+		reading.TodayEnergy = &kwh
+	}
+	return reading, nil
+}
+
+// SetPower turns the relay on or off.
+func (d *Driver) SetPower(ctx context.Context, conn drivers.Connection, on bool) error {
+	return d.sessions.SetPower(ctx, toConnection(conn), on)
+}
+
+// ReadState reports whether the relay is on.
+func (d *Driver) ReadState(ctx context.Context, conn drivers.Connection) (bool, error) {
+	return d.sessions.ReadState(ctx, toConnection(conn))
+}
+
+func toConnection(conn drivers.Connection) Connection {
+	return Connection{IP: conn.Host, Username: conn.Username, Password: conn.Password}
+}
@@ -0,0 +1,178 @@
+package tapo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	tapo "github.com/tess1o/tapo-go"
+)
+
+const (
+	// DefaultSessionTTL is how long a handshake is reused before a fresh one is made.
+	// Plugs expire KLAP sessions after several hours; refreshing earlier avoids mid-request expiry.
+	DefaultSessionTTL = 30 * time.Minute
+	// DefaultIdleTimeout evicts sessions of devices nobody has talked to recently.
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+// session is one authenticated client. mu serialises calls because plugs
+// handle a single request at a time and reject concurrent ones.
+type session struct {
+	mu      sync.Mutex
+	plug    *tapo.SmartPlug
+	created time.Time
+
+	lastUsed time.Time // guarded by Sessions.mu
+}
+
+// Sessions caches authenticated SmartPlug clients per device (IP + credentials),
+// so repeated reads and toggles skip the slow KLAP handshake.
+type Sessions struct {
+	ttl         time.Duration
+	idleTimeout time.Duration
+	connect     func(ctx context.Context, conn Connection) (*tapo.SmartPlug, error) // performs the handshake
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// NewSessions creates a session cache. Zero durations use the defaults.
+func NewSessions(ttl, idleTimeout time.Duration) *Sessions {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &Sessions{
+		ttl:         ttl,
+		idleTimeout: idleTimeout,
+		connect:     createClient,
+		now:         time.Now,
+		sessions:    make(map[string]*session),
+	}
+}
+
+// ReadPower reads the current power (Watts) through a cached session.
+func (s *Sessions) ReadPower(ctx context.Context, conn Connection) (float64, error) {
+	var power float64
+	err := s.do(ctx, conn, func(sp *tapo.SmartPlug) error {
+		var err error
+		power, err = readPower(ctx, sp)
+		return err
+	})
+	return power, err
+}
+
+// ReadTodayEnergy reads the energy (kWh) counted by the device today through a cached session.
+func (s *Sessions) ReadTodayEnergy(ctx context.Context, conn Connection) (float64, error) {
+	var kwh float64
+	err := s.do(ctx, conn, func(sp *tapo.SmartPlug) error {
+		var err error
+		kwh, err = readTodayEnergy(ctx, sp)
+		return err
+	})
+	return kwh, err
+}
+
+// SetPower turns the relay on or off through a cached session.
+func (s *Sessions) SetPower(ctx context.Context, conn Connection, on bool) error {
+	return s.do(ctx, conn, func(sp *tapo.SmartPlug) error {
+		return setPower(ctx, sp, on)
+	})
+}
+
+// ReadState reports whether the relay is on through a cached session.
+func (s *Sessions) ReadState(ctx context.Context, conn Connection) (bool, error) {
+	var on bool
+	err := s.do(ctx, conn, func(sp *tapo.SmartPlug) error {
+		var err error
+		on, err = readState(ctx, sp)
+		return err
+	})
+	return on, err
+}
+
+// do runs fn with a cached client, re-handshaking once if the session was rejected.
+func (s *Sessions) do(ctx context.Context, conn Connection, fn func(*tapo.SmartPlug) error) error {
+	if err := validate(conn); err != nil {
+		return err
+	}
+
+	sess := s.get(conn)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	now := s.now()
+	if sess.plug == nil || now.Sub(sess.created) > s.ttl {
+		if err := s.handshake(ctx, sess, conn); err != nil {
+			return err
+		}
+	}
+
+	err := fn(sess.plug)
+	if err != nil && isSessionError(err) {
+		// The plug dropped our session (reboot, expiry or 403): handshake again and retry once
+		if hsErr := s.handshake(ctx, sess, conn); hsErr != nil {
+			return hsErr
+		}
+		err = fn(sess.plug)
+	}
+	return err
+}
+
+// handshake replaces the session client. On failure the session is cleared
+// so the next call retries the handshake.
+func (s *Sessions) handshake(ctx context.Context, sess *session, conn Connection) error {
+	sp, err := s.connect(ctx, conn)
+	if err != nil {
+		sess.plug = nil
+		return err
+	}
+	sess.plug = sp
+	sess.created = s.now()
+	return nil
+}
+
+// get returns the session for a connection, creating an empty one if needed,
+// and evicts sessions idle for longer than idleTimeout. A session handed out
+// here was just touched, so it cannot be evicted while its caller uses it.
+func (s *Sessions) get(conn Connection) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, sess := range s.sessions {
+		if now.Sub(sess.lastUsed) > s.idleTimeout {
+			delete(s.sessions, k)
+		}
+	}
+
+	key := sessionKey(conn)
+	sess, ok := s.sessions[key]
+	if !ok {
+		sess = &session{}
+		s.sessions[key] = sess
+	}
+	sess.lastUsed = now
+	return sess
+}
+
+// sessionKey identifies a device session; the password is hashed so it never sits in map keys.
+func sessionKey(conn Connection) string {
+	sum := sha256.Sum256([]byte(conn.Username + "\x00" + conn.Password))
+	return conn.IP + "|" + hex.EncodeToString(sum[:])
+}
+
+// isSessionError reports whether an error means the session must be re-established.
+func isSessionError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "403") ||
+		strings.Contains(msg, "session expired") ||
+		strings.Contains(msg, "handshake")
+}
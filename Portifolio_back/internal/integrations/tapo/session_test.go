@@ -0,0 +1,159 @@
+package tapo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tapo "github.com/tess1o/tapo-go"
+)
+
+var testStart = time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)
+
+// fakeClock is a time source moved forward by the test.
+type fakeClock struct{ t time.Time }
+
+func newFakeClock() *fakeClock { return &fakeClock{t: testStart} }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestSessions returns a cache whose handshakes hand out a new client each
+// time and are recorded per connection.
+func newTestSessions(clock *fakeClock) (*Sessions, map[Connection]int) {
+	handshakes := map[Connection]int{}
+	s := NewSessions(30*time.Minute, 10*time.Minute)
+	s.now = clock.now
+	s.connect = func(_ context.Context, conn Connection) (*tapo.SmartPlug, error) {
+		handshakes[conn]++
+		return new(tapo.SmartPlug), nil
+	}
+	return s, handshakes
+}
+
+// use runs a no-op call and returns the client it was given.
+func use(t *testing.T, s *Sessions, conn Connection) *tapo.SmartPlug {
+	t.Helper()
+	var got *tapo.SmartPlug
+	if err := s.do(context.Background(), conn, func(sp *tapo.SmartPlug) error {
+		got = sp
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+var plug = Connection{IP: "10.0.0.20", Username: "owner@example.com", Password: "s3cret"}
+
+func TestSessionReusedUntilTTL(t *testing.T) {
+	clock := newFakeClock()
+	s, handshakes := newTestSessions(clock)
+
+	first := use(t, s, plug)
+	for i := 0; i < 5; i++ {
+		clock.advance(5 * time.Minute) // well within the idle timeout
+		if got := use(t, s, plug); got != first {
+			t.Fatalf("call %d after %s made a new session", i+2, clock.t.Sub(testStart))
+		}
+	}
+	clock.advance(5*time.Minute + time.Second) // 30m01s since the handshake
+	if got := use(t, s, plug); got == first {
+		t.Error("session reused past its TTL")
+	}
+	if handshakes[plug] != 2 {
+		t.Errorf("%d handshakes, want 2", handshakes[plug])
+	}
+}
+
+func TestIdleSessionEvicted(t *testing.T) {
+	clock := newFakeClock()
+	s, handshakes := newTestSessions(clock)
+	other := Connection{IP: "10.0.0.21", Username: plug.Username, Password: plug.Password}
+
+	first := use(t, s, plug)
+	use(t, s, other)
+	clock.advance(10*time.Minute + time.Second)
+	use(t, s, other) // evicts the idle session of plug
+
+	s.mu.Lock()
+	_, cached := s.sessions[sessionKey(plug)]
+	size := len(s.sessions)
+	s.mu.Unlock()
+	if cached || size != 1 {
+		t.Fatalf("idle session still cached (%d sessions)", size)
+	}
+	if got := use(t, s, plug); got == first || handshakes[plug] != 2 {
+		t.Errorf("returning device reused its evicted session (%d handshakes)", handshakes[plug])
+	}
+}
+
+func TestSessionKeyFollowsCredentials(t *testing.T) {
+	clock := newFakeClock()
+	s, handshakes := newTestSessions(clock)
+
+	changed := plug
+	changed.Password = "new-s3cret"
+	if sessionKey(changed) == sessionKey(plug) {
+		t.Fatal("changing the password keeps the session key")
+	}
+	if first := use(t, s, plug); use(t, s, changed) == first {
+		t.Error("new credentials reused the session of the old ones")
+	}
+	if handshakes[changed] != 1 {
+		t.Errorf("%d handshakes with the new credentials, want 1", handshakes[changed])
+	}
+
+	changedUser := plug
+	changedUser.Username = "other@example.com"
+	if sessionKey(changedUser) == sessionKey(plug) {
+		t.Error("changing the username keeps the session key")
+	}
+}
+
+func TestSessionRefreshedOnForbidden(t *testing.T) {
+	clock := newFakeClock()
+	s, handshakes := newTestSessions(clock)
+	first := use(t, s, plug)
+
+	var clients []*tapo.SmartPlug
+	err := s.do(context.Background(), plug, func(sp *tapo.SmartPlug) error {
+		clients = append(clients, sp)
+		if sp == first {
+			return errors.New("request failed: status code 403")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 2 || clients[1] == first || handshakes[plug] != 2 {
+		t.Fatalf("%d calls and %d handshakes; want a retry on a fresh session", len(clients), handshakes[plug])
+	}
+	if got := use(t, s, plug); got != clients[1] {
+		t.Error("the refreshed session was not kept")
+	}
+
+	// Other errors are returned without a new handshake, and a retry is made only once
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"device error", errors.New("device error code -1008"), 1},
+		{"still forbidden", errors.New("status code 403"), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := s.do(context.Background(), plug, func(*tapo.SmartPlug) error {
+				calls++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) || calls != tt.calls {
+				t.Errorf("error %v after %d calls, want %v after %d", err, calls, tt.err, tt.calls)
+			}
+		})
+	}
+}
@@ -15,17 +15,25 @@ type Connection struct {
 	Password string
 }
 
-// createClient creates a SmartPlug client with enhanced error handling.
-// Returns a more detailed error message to help with troubleshooting.
-func createClient(ctx context.Context, conn Connection) (*tapo.SmartPlug, error) {
+// validate checks the connection has everything needed for a handshake.
+func validate(conn Connection) error {
 	if conn.IP == "" {
-		return nil, fmt.Errorf("device IP is required")
+		return fmt.Errorf("device IP is required")
 	}
 	if conn.Username == "" {
-		return nil, fmt.Errorf("username/email is required (use your Tapo Cloud account email)")
+		return fmt.Errorf("username/email is required (use your Tapo Cloud account email)")
 	}
 	if conn.Password == "" {
-		return nil, fmt.Errorf("password is required (use your Tapo Cloud account password)")
+		return fmt.Errorf("password is required (use your Tapo Cloud account password)")
+	}
+	return nil
+}
+
+// createClient creates a SmartPlug client with enhanced error handling.
+// Returns a more detailed error message to help with troubleshooting.
+func createClient(ctx context.Context, conn Connection) (*tapo.SmartPlug, error) {
+	if err := validate(conn); err != nil {
+		return nil, err
 	}
 
 	// Create client with default retry config
@@ -53,15 +61,26 @@ func createClient(ctx context.Context, conn Connection) (*tapo.SmartPlug, error)
 	return sp, nil
 }
 
-// ReadPower reads the current power consumption (Watts) from the device.
-// Uses tapo-go's SmartPlug client to fetch current power.
-func ReadPower(ctx context.Context, conn Connection) (float64, error) {
-	sp, err := createClient(ctx, conn)
-	if err != nil {
-		return 0, err
+// errorCodeSessionTimeout is returned in the response body when the KLAP session expired.
+const errorCodeSessionTimeout = 9999
+
+// responseError converts a non-zero Tapo error_code into an error.
+func responseError(code int) error {
+	if code == 0 {
+		return nil
+	}
+	if code == errorCodeSessionTimeout {
+		return fmt.Errorf("session expired (error_code %d)", code)
 	}
+	return fmt.Errorf("device returned error_code %d", code)
+}
 
+// readPower reads the current power consumption (Watts) from the device.
+func readPower(ctx context.Context, sp *tapo.SmartPlug) (float64, error) {
 	cp, err := sp.GetCurrentPower(ctx)
+	if err == nil && cp != nil {
+		err = responseError(cp.ErrorCode)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to read power from device: %w", err)
 	}
@@ -71,22 +90,47 @@ func ReadPower(ctx context.Context, conn Connection) (float64, error) {
 	return float64(cp.Result.CurrentPower), nil
 }
 
-// SetPower sets the relay state (on/off) on the device using tapo-go.
-func SetPower(ctx context.Context, conn Connection, on bool) error {
-	sp, err := createClient(ctx, conn)
+// readTodayEnergy reads the energy (kWh) counted by the device since local
+// midnight. Only plugs with energy monitoring (P110/P115) support it.
+func readTodayEnergy(ctx context.Context, sp *tapo.SmartPlug) (float64, error) {
+	usage, err := sp.GetEnergyUsage(ctx)
+	if err == nil && usage != nil {
+		err = responseError(usage.ErrorCode)
+	}
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to read energy usage from device: %w", err)
 	}
+	return float64(usage.Result.TodayEnergy) / 1000, nil // Wh -> kWh
+}
 
+// setPower sets the relay state (on/off) on the device.
+func setPower(ctx context.Context, sp *tapo.SmartPlug, on bool) error {
+	var resp *tapo.SetDeviceParameterResponse
+	var err error
+	state := "OFF"
 	if on {
-		if _, err := sp.TurnOn(ctx); err != nil {
-			return fmt.Errorf("failed to turn device ON: %w", err)
-		}
-		return nil
+		state = "ON"
+		resp, err = sp.TurnOn(ctx)
+	} else {
+		resp, err = sp.TurnOff(ctx)
 	}
-
-	if _, err := sp.TurnOff(ctx); err != nil {
-		return fmt.Errorf("failed to turn device OFF: %w", err)
+	if err == nil && resp != nil {
+		err = responseError(resp.ErrorCode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to turn device %s: %w", state, err)
 	}
 	return nil
 }
+
+// readState reports whether the device relay is currently on.
+func readState(ctx context.Context, sp *tapo.SmartPlug) (bool, error) {
+	info, err := sp.DeviceInfo(ctx)
+	if err == nil && info != nil {
+		err = responseError(info.ErrorCode)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read device info: %w", err)
+	}
+	return info.Result.DeviceOn, nil
+}
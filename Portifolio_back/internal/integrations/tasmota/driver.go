@@ -0,0 +1,68 @@
+package tasmota
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+// DriverName is the registry name and Device.Metadata key for Tasmota plugs.
+const DriverName = "tasmota"
+
+// Driver adapts Tasmota HTTP commands to the drivers.Driver interface.
+type Driver struct{}
+
+// NewDriver creates a Tasmota driver.
+func NewDriver() *Driver {
+	return &Driver{}
+}
+
+// Name returns the driver name.
+func (d *Driver) Name() string { return DriverName }
+
+// Capabilities reports what energy-monitoring Tasmota plugs support.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	return drivers.Capabilities{Switch: true, Power: true, Voltage: true, Current: true}
+}
+
+// Validate requires a host; credentials are only needed when a web password is set.
+func (d *Driver) Validate(conn drivers.Connection) error {
+	if conn.Host == "" {
+		return fmt.Errorf("device host is required")
+	}
+	if conn.Username != "" && conn.Password == "" {
+		return fmt.Errorf("password is required when username is set")
+	}
+	return nil
+}
+
+// ReadPower reads the ENERGY block from `Status 8`.
+func (d *Driver) ReadPower(ctx context.Context, conn drivers.Connection) (*drivers.Reading, error) {
+	e, err := ReadEnergy(ctx, toConnection(conn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read power from device: %w", err)
+	}
+	total, today := e.Total, e.Today
+	return &drivers.Reading{
+		Power:       e.Power,
+		Voltage:     e.Voltage,
+		Current:     e.Current,
+		TotalEnergy: &total,
+		TodayEnergy: &today,
+	}, nil
+}
+
+// SetPower turns the relay on or off.
+func (d *Driver) SetPower(ctx context.Context, conn drivers.Connection, on bool) error {
+	return SetPower(ctx, toConnection(conn), on)
+}
+
+// ReadState reports whether the relay is on.
+func (d *Driver) ReadState(ctx context.Context, conn drivers.Connection) (bool, error) {
+	return ReadState(ctx, toConnection(conn))
+}
+
+func toConnection(conn drivers.Connection) Connection {
+	return Connection{Host: conn.Host, Username: conn.Username, Password: conn.Password, Channel: conn.Channel}
+}
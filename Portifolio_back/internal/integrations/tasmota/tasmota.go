@@ -0,0 +1,168 @@
+package tasmota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds a single command when the context has no deadline.
+const requestTimeout = 5 * time.Second
+
+// Connection holds what is needed to reach a Tasmota device.
+type Connection struct {
+	Host     string // IP or host[:port]; a scheme may be included
+	Username string // web admin user, "admin" when a password is set
+	Password string // web admin password, empty when unprotected
+	Channel  int    // relay index; 0 or 1 address the first relay
+}
+
+// Energy is the ENERGY block of the `Status 8` response.
+type Energy struct {
+	Total   float64  `json:"Total"`   // kWh since TotalStartTime
+	Today   float64  `json:"Today"`   // kWh today
+	Power   float64  `json:"Power"`   // Watts
+	Voltage *float64 `json:"Voltage"` // Volts
+	Current *float64 `json:"Current"` // Amps
+}
+
+// httpClient is shared by all calls; timeouts are applied per request.
+var httpClient = &http.Client{}
+
+// ReadEnergy issues `Status 8` and returns the ENERGY block.
+func ReadEnergy(ctx context.Context, conn Connection) (*Energy, error) {
+	var resp struct {
+		StatusSNS struct {
+			Energy *Energy `json:"ENERGY"`
+		} `json:"StatusSNS"`
+	}
+	if err := command(ctx, conn, "Status 8", &resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusSNS.Energy == nil {
+		return nil, fmt.Errorf("device has no energy monitoring (ENERGY block missing)")
+	}
+	return resp.StatusSNS.Energy, nil
+}
+
+// ReadState issues `Power` and reports whether the relay is on.
+func ReadState(ctx context.Context, conn Connection) (bool, error) {
+	var resp map[string]any
+	if err := command(ctx, conn, powerCommand(conn.Channel), &resp); err != nil {
+		return false, err
+	}
+	return parsePowerState(resp)
+}
+
+// SetPower issues `Power ON` or `Power OFF`.
+func SetPower(ctx context.Context, conn Connection, on bool) error {
+	state := "OFF"
+	if on {
+		state = "ON"
+	}
+	var resp map[string]any
+	if err := command(ctx, conn, powerCommand(conn.Channel)+" "+state, &resp); err != nil {
+		return fmt.Errorf("failed to turn device %s: %w", state, err)
+	}
+	got, err := parsePowerState(resp)
+	if err != nil {
+		return err
+	}
+	if got != on {
+		return fmt.Errorf("device reported POWER %s after command", onOff(got))
+	}
+	return nil
+}
+
+// powerCommand returns "Power" or "Power<n>" for multi-relay devices.
+func powerCommand(channel int) string {
+	if channel > 1 {
+		return "Power" + strconv.Itoa(channel)
+	}
+	return "Power"
+}
+
+// parsePowerState reads the POWER/POWERn key from a command response.
+func parsePowerState(resp map[string]any) (bool, error) {
+	for k, v := range resp {
+		if !strings.HasPrefix(k, "POWER") {
+			continue
+		}
+		s, _ := v.(string)
+		switch strings.ToUpper(s) {
+		case "ON":
+			return true, nil
+		case "OFF":
+			return false, nil
+		}
+	}
+	if cmdErr, ok := resp["Command"].(string); ok {
+		return false, fmt.Errorf("command rejected: %s", cmdErr)
+	}
+	return false, fmt.Errorf("unexpected response: POWER missing")
+}
+
+// command runs GET /cm?cmnd=<cmd> with optional web credentials.
+func command(ctx context.Context, conn Connection, cmd string, out any) error {
+	if conn.Host == "" {
+		return fmt.Errorf("device host is required")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	base := conn.Host
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	params := url.Values{"cmnd": {cmd}}
+	if conn.Password != "" {
+		user := conn.Username
+		if user == "" {
+			user = "admin"
+		}
+		params.Set("user", user)
+		params.Set("password", conn.Password)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/cm?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach device: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication failed (401): verify the device web password")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("command %q failed: HTTP %d", cmd, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s: %w", conn.Host, err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid response to %q: %w", cmd, err)
+	}
+	return nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
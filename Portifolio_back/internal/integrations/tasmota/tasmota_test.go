@@ -0,0 +1,155 @@
+package tasmota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+)
+
+// fakeTasmota emulates the /cm command endpoint of a two-relay plug with a
+// web password set.
+type fakeTasmota struct {
+	password string
+	energy   string // ENERGY block of Status 8, omitted when empty
+
+	mu    sync.Mutex
+	power [2]bool
+}
+
+func (f *fakeTasmota) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if r.URL.Path != "/cm" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if q.Get("user") != "admin" || q.Get("password") != f.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd, arg, _ := strings.Cut(q.Get("cmnd"), " ")
+	if cmd == "Status" && arg == "8" {
+		energy := ""
+		if f.energy != "" {
+			energy = `,"ENERGY":` + f.energy
+		}
+		w.Write([]byte(`{"StatusSNS":{"Time":"2025-06-03T18:30:00"` + energy + `}}`))
+		return
+	}
+
+	channel := 1
+	if n, ok := strings.CutPrefix(cmd, "Power"); ok && n != "" {
+		channel, _ = strconv.Atoi(n)
+	}
+	if !strings.HasPrefix(cmd, "Power") || channel < 1 || channel > len(f.power) {
+		w.Write([]byte(`{"Command":"Unknown"}`))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch arg {
+	case "ON":
+		f.power[channel-1] = true
+	case "OFF":
+		f.power[channel-1] = false
+	}
+	key := "POWER"
+	if channel > 1 {
+		key += strconv.Itoa(channel)
+	}
+	w.Write([]byte(`{"` + key + `":"` + onOff(f.power[channel-1]) + `"}`))
+}
+
+// relays returns the state of both relays.
+func (f *fakeTasmota) relays() [2]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.power
+}
+
+func newFakeTasmota(t *testing.T, energy string) (*fakeTasmota, drivers.Connection) {
+	t.Helper()
+	dev := &fakeTasmota{password: "plug-pass", energy: energy}
+	srv := httptest.NewServer(dev)
+	t.Cleanup(srv.Close)
+	return dev, drivers.Connection{Host: srv.URL, Password: dev.password}
+}
+
+func TestDriverReadPower(t *testing.T) {
+	_, conn := newFakeTasmota(t, `{"TotalStartTime":"2024-01-01T00:00:00","Total":123.456,"Yesterday":1.2,"Today":0.789,"Power":42,"Voltage":229,"Current":0.183}`)
+
+	r, err := NewDriver().ReadPower(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Power != 42 || r.Voltage == nil || *r.Voltage != 229 || r.Current == nil || *r.Current != 0.183 {
+		t.Errorf("reading = %+v", r)
+	}
+	if r.TotalEnergy == nil || *r.TotalEnergy != 123.456 || r.TodayEnergy == nil || *r.TodayEnergy != 0.789 {
+		t.Errorf("energy total %v, today %v; want 123.456 and 0.789 kWh", r.TotalEnergy, r.TodayEnergy)
+	}
+}
+
+func TestReadEnergyWithoutEnergyBlock(t *testing.T) {
+	_, conn := newFakeTasmota(t, "")
+
+	_, err := NewDriver().ReadPower(context.Background(), conn)
+	if err == nil || !strings.Contains(err.Error(), "ENERGY block missing") {
+		t.Fatalf("error = %v, want the missing ENERGY block", err)
+	}
+}
+
+func TestDriverSetPower(t *testing.T) {
+	dev, conn := newFakeTasmota(t, "")
+	d := NewDriver()
+	ctx := context.Background()
+
+	for _, channel := range []int{0, 2} {
+		conn := conn
+		conn.Channel = channel
+		relay := max(channel, 1) - 1
+		for _, on := range []bool{true, false, true} {
+			if err := d.SetPower(ctx, conn, on); err != nil {
+				t.Fatalf("channel %d: SetPower(%v): %v", channel, on, err)
+			}
+			state, err := d.ReadState(ctx, conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if relays := dev.relays(); state != on || relays[relay] != on {
+				t.Errorf("channel %d after SetPower(%v): ReadState = %v, relays = %v", channel, on, state, relays)
+			}
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	_, conn := newFakeTasmota(t, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		conn drivers.Connection
+		want string
+	}{
+		{"no host", drivers.Connection{}, "device host is required"},
+		{"no password", drivers.Connection{Host: conn.Host}, "authentication failed"},
+		{"wrong password", drivers.Connection{Host: conn.Host, Password: "nope"}, "authentication failed"},
+		{"unknown relay", drivers.Connection{Host: conn.Host, Password: conn.Password, Channel: 3}, "command rejected: Unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDriver().ReadState(ctx, tt.conn)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
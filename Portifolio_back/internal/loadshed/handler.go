@@ -0,0 +1,260 @@
+package loadshed
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+)
+
+// Handler handles load shedding HTTP requests.
+type Handler struct {
+	Repo    *Repo
+	Shedder *Shedder
+}
+
+// NewHandler creates a new load shedding handler.
+func NewHandler(repo *Repo, shedder *Shedder) *Handler {
+	return &Handler{Repo: repo, Shedder: shedder}
+}
+
+// RegisterRoutes registers load shedding routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	l := r.Group("/load-shedding")
+	l.GET("", h.Status)
+	l.PUT("/budget", h.SetBudget)
+	l.DELETE("/budget", h.DeleteBudget)
+	l.PUT("/priorities", h.SetPriorities)
+	l.GET("/events", h.ListEvents)
+	l.POST("/devices/:deviceId/override", h.Override)
+	l.DELETE("/devices/:deviceId/override", h.ClearOverride)
+}
+
+// Status returns the budget, the current household load and every device's
+// priority and shedding state.
+func (h *Handler) Status(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	st, err := h.Shedder.Status(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch load shedding status"})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// SetBudget creates or replaces the user's power budget.
+func (h *Handler) SetBudget(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: budget_watts must be greater than 0"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	b, err := h.Repo.SetBudget(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save budget"})
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBudget removes the user's budget. Devices that were shed stay off.
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	err := h.Repo.DeleteBudget(c.Request.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete budget"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetPriorities sets the shedding priority of some of the user's devices.
+// Lower priorities are shed first; never_shed devices are never switched off.
+func (h *Handler) SetPriorities(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req PriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: devices must list device_id and priority"})
+		return
+	}
+	ids := make([]int64, len(req.Devices))
+	seen := make(map[int64]bool, len(req.Devices))
+	for i, e := range req.Devices {
+		if seen[e.DeviceID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "each device may appear only once"})
+			return
+		}
+		seen[e.DeviceID] = true
+		ids[i] = e.DeviceID
+	}
+
+	ctx := c.Request.Context()
+	owns, err := h.Repo.UserOwnsDevices(ctx, userID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err := h.Repo.SetPriorities(ctx, req.Devices); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save priorities"})
+		return
+	}
+
+	list, err := h.Repo.ListDevices(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch devices"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ListEvents returns the load shedding log, newest first.
+// Query params: device_id, limit (default 100, max 500)
+func (h *Handler) ListEvents(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		deviceID = id
+	}
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = min(parsed, 500)
+		}
+	}
+
+	list, err := h.Repo.ListEvents(c.Request.Context(), userID, deviceID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch load shedding events"})
+		return
+	}
+	if list == nil {
+		list = []Event{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Override keeps a device out of load shedding for a while and turns it back
+// on if it was shed. Body (optional): {"minutes": n}
+func (h *Handler) Override(c *gin.Context) {
+	userID, deviceID, ok := deviceParams(c)
+	if !ok {
+		return
+	}
+	var req OverrideRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: minutes must be between 1 and 10080"})
+			return
+		}
+	}
+
+	d, err := h.Shedder.Override(c.Request.Context(), userID, deviceID, req.Minutes)
+	if errors.Is(err, devices.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to override load shedding", "detail": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// ClearOverride makes a device eligible for load shedding again.
+func (h *Handler) ClearOverride(c *gin.Context) {
+	userID, deviceID, ok := deviceParams(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	owns, err := h.Repo.UserOwnsDevices(ctx, userID, []int64{deviceID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err := h.Shedder.ClearOverride(ctx, userID, deviceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear override"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deviceParams reads the user and the :deviceId path parameter, writing the
+// error response itself when one is missing or invalid.
+func deviceParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("deviceId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,130 @@
+package loadshed
+
+import (
+	"errors"
+	"time"
+)
+
+// Event actions.
+const (
+	ActionShed     = "shed"     // device turned off to get back under the budget
+	ActionRestore  = "restore"  // shed device turned back on once there was headroom
+	ActionOverride = "override" // user took a device out of load shedding
+)
+
+// Event states.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Budget is a user's whole-house power limit (Watts).
+type Budget struct {
+	UserID              int64     `json:"user_id"`
+	BudgetWatts         float64   `json:"budget_watts"`
+	Enabled             bool      `json:"enabled"`
+	AutoRestore         bool      `json:"auto_restore"`
+	RestoreMarginWatts  float64   `json:"restore_margin_watts"`
+	RestoreDelayMinutes int       `json:"restore_delay_minutes"`
+	OverrideMinutes     int       `json:"override_minutes"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// BudgetRequest is the payload for setting the budget.
+type BudgetRequest struct {
+	BudgetWatts         float64 `json:"budget_watts" binding:"required,gt=0"`
+	Enabled             *bool   `json:"enabled"`
+	AutoRestore         *bool   `json:"auto_restore"`
+	RestoreMarginWatts  float64 `json:"restore_margin_watts"`
+	RestoreDelayMinutes *int    `json:"restore_delay_minutes"`
+	OverrideMinutes     *int    `json:"override_minutes"`
+}
+
+// Defaults used when a budget request leaves a field out.
+const (
+	defaultRestoreDelayMinutes = 5
+	defaultOverrideMinutes     = 60
+)
+
+// Validate checks the limits and fills defaults.
+func (r *BudgetRequest) Validate() error {
+	if r.RestoreMarginWatts < 0 || r.RestoreMarginWatts >= r.BudgetWatts {
+		return errors.New("restore_margin_watts must be between 0 and budget_watts")
+	}
+	if r.RestoreDelayMinutes == nil {
+		d := defaultRestoreDelayMinutes
+		r.RestoreDelayMinutes = &d
+	}
+	if r.OverrideMinutes == nil {
+		d := defaultOverrideMinutes
+		r.OverrideMinutes = &d
+	}
+	if *r.RestoreDelayMinutes < 0 || *r.OverrideMinutes < 1 {
+		return errors.New("restore_delay_minutes must not be negative and override_minutes must be at least 1")
+	}
+	return nil
+}
+
+// Device is the load shedding setting and state of one device.
+type Device struct {
+	DeviceID      int64      `json:"device_id"`
+	DeviceName    string     `json:"device_name"`
+	PowerState    *bool      `json:"power_state"`
+	Priority      int        `json:"priority"` // lowest is shed first
+	NeverShed     bool       `json:"never_shed"`
+	OverrideUntil *time.Time `json:"override_until,omitempty"`
+	ShedAt        *time.Time `json:"shed_at,omitempty"` // set while off because of the budget
+	ShedWatts     *float64   `json:"shed_watts,omitempty"`
+	FailedAt      *time.Time `json:"-"`
+	Watts         *float64   `json:"watts,omitempty"` // latest fresh reading counted in the total
+}
+
+// Shed reports whether the device is currently off because of the budget.
+func (d *Device) Shed() bool {
+	return d.ShedAt != nil
+}
+
+// Overridden reports whether the user protected the device at now.
+func (d *Device) Overridden(now time.Time) bool {
+	return d.OverrideUntil != nil && d.OverrideUntil.After(now)
+}
+
+// PriorityRequest sets the priority of some of the user's devices.
+type PriorityRequest struct {
+	Devices []PriorityEntry `json:"devices" binding:"required,min=1,dive"`
+}
+
+// PriorityEntry is the priority of one device.
+type PriorityEntry struct {
+	DeviceID  int64 `json:"device_id" binding:"required"`
+	Priority  int   `json:"priority"`
+	NeverShed bool  `json:"never_shed"`
+}
+
+// OverrideRequest is the optional payload of an override.
+type OverrideRequest struct {
+	Minutes int `json:"minutes" binding:"omitempty,min=1,max=10080"` // 0 = the budget's override_minutes
+}
+
+// Status is the household load compared with the budget.
+type Status struct {
+	Budget     *Budget  `json:"budget"` // nil when no budget is set
+	TotalWatts float64  `json:"total_watts"`
+	OverBudget bool     `json:"over_budget"`
+	Devices    []Device `json:"devices"`
+}
+
+// Event is one logged load shedding action.
+type Event struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	DeviceID    int64     `json:"device_id"`
+	DeviceName  string    `json:"device_name,omitempty"`
+	Action      string    `json:"action"`
+	Status      string    `json:"status"`
+	TotalWatts  float64   `json:"total_watts"`  // household load when the action was taken
+	BudgetWatts float64   `json:"budget_watts"` // budget at the time
+	DeviceWatts float64   `json:"device_watts"` // power of the device involved
+	Error       *string   `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,241 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when the user has no budget.
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for load shedding.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new load shedding repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsDevices checks that every device belongs to the user.
+func (r *Repo) UserOwnsDevices(ctx context.Context, userID int64, deviceIDs []int64) (bool, error) {
+	sql := `SELECT COUNT(DISTINCT id) FROM device WHERE user_id = $1 AND id = ANY($2)`
+	var owned int
+	if err := r.q.QueryRow(ctx, sql, userID, deviceIDs).Scan(&owned); err != nil {
+		return false, err
+	}
+	unique := make(map[int64]bool, len(deviceIDs))
+	for _, id := range deviceIDs {
+		unique[id] = true
+	}
+	return owned == len(unique), nil
+}
+
+const budgetColumns = `user_id, budget_watts, enabled, auto_restore, restore_margin_watts,
+			restore_delay_minutes, override_minutes, updated_at`
+
+func scanBudget(row interface{ Scan(dest ...any) error }) (*Budget, error) {
+	var b Budget
+	err := row.Scan(&b.UserID, &b.BudgetWatts, &b.Enabled, &b.AutoRestore, &b.RestoreMarginWatts,
+		&b.RestoreDelayMinutes, &b.OverrideMinutes, &b.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBudget returns the user's budget.
+func (r *Repo) GetBudget(ctx context.Context, userID int64) (*Budget, error) {
+	sql := `SELECT ` + budgetColumns + ` FROM power_budget WHERE user_id = $1`
+	return scanBudget(r.q.QueryRow(ctx, sql, userID))
+}
+
+// SetBudget creates or replaces the user's budget.
+func (r *Repo) SetBudget(ctx context.Context, userID int64, req *BudgetRequest) (*Budget, error) {
+	sql := `INSERT INTO power_budget (user_id, budget_watts, enabled, auto_restore, restore_margin_watts,
+				restore_delay_minutes, override_minutes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (user_id) DO UPDATE
+			SET budget_watts = EXCLUDED.budget_watts, enabled = EXCLUDED.enabled,
+				auto_restore = EXCLUDED.auto_restore, restore_margin_watts = EXCLUDED.restore_margin_watts,
+				restore_delay_minutes = EXCLUDED.restore_delay_minutes, override_minutes = EXCLUDED.override_minutes,
+				updated_at = NOW()
+			RETURNING ` + budgetColumns
+	return scanBudget(r.q.QueryRow(ctx, sql, userID, req.BudgetWatts, req.Enabled == nil || *req.Enabled,
+		req.AutoRestore == nil || *req.AutoRestore, req.RestoreMarginWatts, *req.RestoreDelayMinutes, *req.OverrideMinutes))
+}
+
+// DeleteBudget removes the user's budget. Devices it shed stay off but are no
+// longer tracked as shed.
+func (r *Repo) DeleteBudget(ctx context.Context, userID int64) error {
+	sql := `WITH cleared AS (
+				UPDATE device_load_shed SET shed_at = NULL, shed_watts = NULL, failed_at = NULL
+				WHERE device_id IN (SELECT id FROM device WHERE user_id = $1)
+			)
+			DELETE FROM power_budget WHERE user_id = $1 RETURNING user_id`
+	var id int64
+	if err := r.q.QueryRow(ctx, sql, userID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListEnabledBudgets returns the budgets the shedder enforces.
+func (r *Repo) ListEnabledBudgets(ctx context.Context) ([]Budget, error) {
+	sql := `SELECT ` + budgetColumns + ` FROM power_budget WHERE enabled ORDER BY user_id`
+	rows, err := r.q.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Budget
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *b)
+	}
+	return out, rows.Err()
+}
+
+// ListDevices returns every device of the user with its load shedding setting,
+// ordered by priority (first to be shed first).
+func (r *Repo) ListDevices(ctx context.Context, userID int64) ([]Device, error) {
+	sql := `SELECT d.id, d.name, d.power_state, COALESCE(s.priority, 0), COALESCE(s.never_shed, FALSE),
+				s.override_until, s.shed_at, s.shed_watts, s.failed_at
+			FROM device d
+			LEFT JOIN device_load_shed s ON s.device_id = d.id
+			WHERE d.user_id = $1
+			ORDER BY COALESCE(s.priority, 0), d.id`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Device
+	for rows.Next() {
+		var d Device
+		if err := rows.Scan(&d.DeviceID, &d.DeviceName, &d.PowerState, &d.Priority, &d.NeverShed,
+			&d.OverrideUntil, &d.ShedAt, &d.ShedWatts, &d.FailedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// SetPriorities stores the priority of the given devices.
+func (r *Repo) SetPriorities(ctx context.Context, entries []PriorityEntry) error {
+	ids := make([]int64, len(entries))
+	priorities := make([]int32, len(entries))
+	never := make([]bool, len(entries))
+	for i, e := range entries {
+		ids[i], priorities[i], never[i] = e.DeviceID, int32(e.Priority), e.NeverShed
+	}
+	sql := `INSERT INTO device_load_shed (device_id, priority, never_shed)
+			SELECT * FROM unnest($1::BIGINT[], $2::INT[], $3::BOOLEAN[])
+			ON CONFLICT (device_id) DO UPDATE
+			SET priority = EXCLUDED.priority, never_shed = EXCLUDED.never_shed`
+	return r.q.Exec(ctx, sql, ids, priorities, never)
+}
+
+// MarkShed records that a device was turned off because of the budget.
+func (r *Repo) MarkShed(ctx context.Context, deviceID int64, watts float64, at time.Time) error {
+	sql := `INSERT INTO device_load_shed (device_id, shed_at, shed_watts) VALUES ($1, $2, $3)
+			ON CONFLICT (device_id) DO UPDATE
+			SET shed_at = EXCLUDED.shed_at, shed_watts = EXCLUDED.shed_watts, failed_at = NULL`
+	return r.q.Exec(ctx, sql, deviceID, at, watts)
+}
+
+// ClearShed forgets that a device was shed.
+func (r *Repo) ClearShed(ctx context.Context, deviceID int64) error {
+	sql := `UPDATE device_load_shed SET shed_at = NULL, shed_watts = NULL, failed_at = NULL WHERE device_id = $1`
+	return r.q.Exec(ctx, sql, deviceID)
+}
+
+// MarkFailed records a failed command so the device is skipped for a while.
+func (r *Repo) MarkFailed(ctx context.Context, deviceID int64, at time.Time) error {
+	sql := `INSERT INTO device_load_shed (device_id, failed_at) VALUES ($1, $2)
+			ON CONFLICT (device_id) DO UPDATE SET failed_at = EXCLUDED.failed_at`
+	return r.q.Exec(ctx, sql, deviceID, at)
+}
+
+// SetOverride protects a device from shedding until the given time (nil clears
+// the override). Setting an override also forgets a current shed.
+func (r *Repo) SetOverride(ctx context.Context, deviceID int64, until *time.Time) error {
+	if until == nil {
+		sql := `UPDATE device_load_shed SET override_until = NULL WHERE device_id = $1`
+		return r.q.Exec(ctx, sql, deviceID)
+	}
+	sql := `INSERT INTO device_load_shed (device_id, override_until) VALUES ($1, $2)
+			ON CONFLICT (device_id) DO UPDATE
+			SET override_until = EXCLUDED.override_until, shed_at = NULL, shed_watts = NULL, failed_at = NULL`
+	return r.q.Exec(ctx, sql, deviceID, *until)
+}
+
+// LogEvent appends an entry to the load shedding log.
+func (r *Repo) LogEvent(ctx context.Context, e *Event) error {
+	sql := `INSERT INTO load_shed_event (user_id, device_id, action, status, total_watts, budget_watts, device_watts, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	return r.q.Exec(ctx, sql, e.UserID, e.DeviceID, e.Action, e.Status, e.TotalWatts, e.BudgetWatts, e.DeviceWatts, e.Error)
+}
+
+// ListEvents returns the user's load shedding log, newest first (deviceID 0 = all devices).
+func (r *Repo) ListEvents(ctx context.Context, userID, deviceID int64, limit int) ([]Event, error) {
+	sql := `SELECT e.id, e.user_id, e.device_id, d.name, e.action, e.status, e.total_watts, e.budget_watts,
+				e.device_watts, e.error, e.created_at
+			FROM load_shed_event e
+			JOIN device d ON d.id = e.device_id
+			WHERE e.user_id = $1 AND ($2 = 0 OR e.device_id = $2)
+			ORDER BY e.created_at DESC, e.id DESC
+			LIMIT $3`
+	rows, err := r.q.Query(ctx, sql, userID, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.UserID, &e.DeviceID, &e.DeviceName, &e.Action, &e.Status, &e.TotalWatts,
+			&e.BudgetWatts, &e.DeviceWatts, &e.Error, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,384 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const (
+	tickInterval   = 10 * time.Second // how often budgets are checked
+	commandTimeout = 30 * time.Second // timeout for switching one device
+	staleAfter     = 5 * time.Minute  // older readings are not counted in the load
+	retryAfter     = 5 * time.Minute  // pause after a failed command on a device
+)
+
+// errNotControllable is returned for devices the shedder cannot switch, such as
+// meters without a driver connection; they are never shed.
+var errNotControllable = errors.New("device cannot be switched remotely")
+
+// DeviceController switches devices (satisfied by devices.Controller).
+type DeviceController interface {
+	DeviceForUser(ctx context.Context, userID, deviceID int64) (*devices.Device, error)
+	Controllable(ctx context.Context, d *devices.Device) bool
+	SetPower(ctx context.Context, d *devices.Device, on bool) (*devices.Device, error)
+}
+
+// LatestReadings returns the latest reading of each device (satisfied by telemetry.Repo).
+type LatestReadings interface {
+	GetLatestByUserDevices(ctx context.Context, userID int64) ([]telemetry.Telemetry, error)
+}
+
+// Shedder keeps each household under its power budget: it turns off the
+// lowest-priority devices while the load is above the budget and, when
+// auto_restore is on, turns them back on one at a time once there is headroom.
+type Shedder struct {
+	repo     *Repo
+	control  DeviceController
+	readings LatestReadings
+	done     chan struct{}
+
+	locks sync.Map // user id -> *sync.Mutex, serialises checks and overrides per user
+}
+
+// NewShedder creates a shedder; call Start to run it.
+func NewShedder(repo *Repo, control DeviceController, readings LatestReadings) *Shedder {
+	return &Shedder{repo: repo, control: control, readings: readings, done: make(chan struct{})}
+}
+
+// Start checks the budgets until ctx is cancelled.
+func (s *Shedder) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Wait blocks until the shedder and the commands in progress have finished.
+func (s *Shedder) Wait() {
+	<-s.done
+}
+
+func (s *Shedder) run(ctx context.Context) {
+	defer close(s.done)
+
+	tick := time.NewTicker(tickInterval)
+	defer tick.Stop()
+
+	for {
+		s.CheckAll(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// CheckAll balances every household with an enabled budget.
+func (s *Shedder) CheckAll(ctx context.Context, now time.Time) {
+	budgets, err := s.repo.ListEnabledBudgets(ctx)
+	if err != nil {
+		log.Printf("Load shedding: failed to list budgets: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range budgets {
+		b := &budgets[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.balance(ctx, b, now); err != nil {
+				log.Printf("Load shedding: failed to check user %d: %v", b.UserID, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Status returns the user's current load with every device's setting.
+func (s *Shedder) Status(ctx context.Context, userID int64) (*Status, error) {
+	b, err := s.repo.GetBudget(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	list, total, err := s.load(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []Device{}
+	}
+	return &Status{Budget: b, TotalWatts: total, OverBudget: b != nil && total > b.BudgetWatts, Devices: list}, nil
+}
+
+// load returns the user's devices and the sum of their fresh readings. Shed
+// devices and devices known to be off are not counted: their last reading
+// predates the switch-off.
+func (s *Shedder) load(ctx context.Context, userID int64, now time.Time) ([]Device, float64, error) {
+	list, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	latest, err := s.readings.GetLatestByUserDevices(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	watts := make(map[int64]float64, len(latest))
+	for _, t := range latest {
+		if now.Sub(t.Timestamp) <= staleAfter {
+			watts[t.DeviceID] = t.Power
+		}
+	}
+
+	total := 0.0
+	for i := range list {
+		d := &list[i]
+		w, ok := watts[d.DeviceID]
+		if !ok || d.Shed() || (d.PowerState != nil && !*d.PowerState) {
+			continue
+		}
+		d.Watts = &w
+		total += w
+	}
+	return list, total, nil
+}
+
+// balance sheds or restores devices of one household.
+func (s *Shedder) balance(ctx context.Context, b *Budget, now time.Time) error {
+	mu := s.lock(b.UserID)
+	defer mu.Unlock()
+
+	list, total, err := s.load(ctx, b.UserID, now)
+	if err != nil {
+		return err
+	}
+
+	// A shed device that is on again was switched on by the user: leave it alone
+	for i := range list {
+		d := &list[i]
+		if d.Shed() && d.PowerState != nil && *d.PowerState {
+			until := now.Add(time.Duration(b.OverrideMinutes) * time.Minute)
+			if err := s.repo.SetOverride(ctx, d.DeviceID, &until); err != nil {
+				return err
+			}
+			s.log(ctx, b.UserID, b.BudgetWatts, d, ActionOverride, total, valueOr(d.ShedWatts), nil)
+			d.ShedAt, d.OverrideUntil = nil, &until
+		}
+	}
+
+	if total > b.BudgetWatts {
+		s.shed(ctx, b, list, total, now)
+		return nil
+	}
+	if b.AutoRestore {
+		s.restore(ctx, b, list, total, now)
+	}
+	return nil
+}
+
+// shed turns off devices, lowest priority and then highest power first, until
+// the load fits the budget. Protected, overridden, idle and uncontrollable
+// devices are skipped.
+func (s *Shedder) shed(ctx context.Context, b *Budget, list []Device, total float64, now time.Time) {
+	var candidates []*Device
+	for i := range list {
+		d := &list[i]
+		if d.Watts == nil || *d.Watts <= 0 || d.NeverShed || d.Overridden(now) || recentlyFailed(d, now) {
+			continue
+		}
+		candidates = append(candidates, d)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return *candidates[i].Watts > *candidates[j].Watts
+	})
+
+	for _, d := range candidates {
+		if total <= b.BudgetWatts {
+			return
+		}
+		err := s.switchDevice(ctx, b.UserID, d.DeviceID, false)
+		if errors.Is(err, errNotControllable) {
+			continue
+		}
+		if err == nil {
+			err = s.repo.MarkShed(ctx, d.DeviceID, *d.Watts, now)
+		}
+		s.log(ctx, b.UserID, b.BudgetWatts, d, ActionShed, total, *d.Watts, err)
+		if err != nil {
+			s.markFailed(ctx, d.DeviceID, now)
+			continue
+		}
+		total -= *d.Watts
+	}
+}
+
+// restore turns one shed device back on, highest priority first, once it has
+// been off for the restore delay and its power fits under the budget minus the
+// margin. One device per check lets the readings catch up before the next.
+func (s *Shedder) restore(ctx context.Context, b *Budget, list []Device, total float64, now time.Time) {
+	var shed []*Device
+	for i := range list {
+		if list[i].Shed() {
+			shed = append(shed, &list[i])
+		}
+	}
+	sort.SliceStable(shed, func(i, j int) bool {
+		if shed[i].Priority != shed[j].Priority {
+			return shed[i].Priority > shed[j].Priority
+		}
+		return shed[i].ShedAt.Before(*shed[j].ShedAt)
+	})
+
+	delay := time.Duration(b.RestoreDelayMinutes) * time.Minute
+	for _, d := range shed {
+		w := valueOr(d.ShedWatts)
+		if now.Sub(*d.ShedAt) < delay || recentlyFailed(d, now) || total+w > b.BudgetWatts-b.RestoreMarginWatts {
+			continue
+		}
+		err := s.switchDevice(ctx, b.UserID, d.DeviceID, true)
+		if err == nil {
+			err = s.repo.ClearShed(ctx, d.DeviceID)
+		}
+		s.log(ctx, b.UserID, b.BudgetWatts, d, ActionRestore, total, w, err)
+		if err != nil {
+			s.markFailed(ctx, d.DeviceID, now)
+			continue
+		}
+		return
+	}
+}
+
+// Override protects a device from shedding for minutes (0 = the budget's
+// override_minutes) and turns it back on if it is currently shed.
+func (s *Shedder) Override(ctx context.Context, userID, deviceID int64, minutes int) (*Device, error) {
+	mu := s.lock(userID)
+	defer mu.Unlock()
+
+	now := time.Now()
+	b, err := s.repo.GetBudget(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	budget := 0.0 // logged as 0 when the user has no budget
+	if b != nil {
+		budget = b.BudgetWatts
+	}
+	if minutes == 0 {
+		minutes = defaultOverrideMinutes
+		if b != nil {
+			minutes = b.OverrideMinutes
+		}
+	}
+	list, total, err := s.load(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	d := findDevice(list, deviceID)
+	if d == nil {
+		return nil, devices.ErrNotFound
+	}
+
+	watts := valueOr(d.Watts)
+	if d.Shed() {
+		watts = valueOr(d.ShedWatts)
+		if err := s.switchDevice(ctx, userID, deviceID, true); err != nil {
+			s.log(ctx, userID, budget, d, ActionOverride, total, watts, err)
+			return nil, err
+		}
+	}
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	if err := s.repo.SetOverride(ctx, deviceID, &until); err != nil {
+		return nil, err
+	}
+	s.log(ctx, userID, budget, d, ActionOverride, total, watts, nil)
+	d.OverrideUntil, d.ShedAt, d.ShedWatts = &until, nil, nil
+	return d, nil
+}
+
+// ClearOverride makes a device eligible for shedding again.
+func (s *Shedder) ClearOverride(ctx context.Context, userID, deviceID int64) error {
+	mu := s.lock(userID)
+	defer mu.Unlock()
+	return s.repo.SetOverride(ctx, deviceID, nil)
+}
+
+func (s *Shedder) switchDevice(ctx context.Context, userID, deviceID int64, on bool) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	d, err := s.control.DeviceForUser(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+	if !s.control.Controllable(ctx, d) {
+		return errNotControllable
+	}
+	_, err = s.control.SetPower(ctx, d, on)
+	return err
+}
+
+// log records an action in the event log; cmdErr marks it failed.
+func (s *Shedder) log(ctx context.Context, userID int64, budget float64, d *Device, action string, total, watts float64, cmdErr error) {
+	e := &Event{
+		UserID:      userID,
+		DeviceID:    d.DeviceID,
+		Action:      action,
+		Status:      StatusSuccess,
+		TotalWatts:  total,
+		BudgetWatts: budget,
+		DeviceWatts: watts,
+	}
+	if cmdErr != nil {
+		e.Status = StatusFailed
+		msg := cmdErr.Error()
+		e.Error = &msg
+		log.Printf("Load shedding: %s of device %d for user %d failed: %v", action, d.DeviceID, userID, cmdErr)
+	} else {
+		log.Printf("Load shedding: %s device %d for user %d (load %.0f W, budget %.0f W)", action, d.DeviceID, userID, total, budget)
+	}
+	if err := s.repo.LogEvent(context.WithoutCancel(ctx), e); err != nil {
+		log.Printf("Warning: failed to log load shedding event for device %d: %v", d.DeviceID, err)
+	}
+}
+
+func (s *Shedder) markFailed(ctx context.Context, deviceID int64, now time.Time) {
+	if err := s.repo.MarkFailed(context.WithoutCancel(ctx), deviceID, now); err != nil {
+		log.Printf("Warning: failed to record load shedding failure for device %d: %v", deviceID, err)
+	}
+}
+
+func (s *Shedder) lock(userID int64) *sync.Mutex {
+	mu, _ := s.locks.LoadOrStore(userID, &sync.Mutex{})
+	m := mu.(*sync.Mutex)
+	m.Lock()
+	return m
+}
+
+func recentlyFailed(d *Device, now time.Time) bool {
+	return d.FailedAt != nil && now.Sub(*d.FailedAt) < retryAfter
+}
+
+func findDevice(list []Device, id int64) *Device {
+	for i := range list {
+		if list[i].DeviceID == id {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func valueOr(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
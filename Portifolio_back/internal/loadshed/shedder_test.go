@@ -0,0 +1,430 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const testUser = 1
+
+// memDB keeps one household's budget and load shedding rows in memory and
+// answers the queries of Repo.
+type memDB struct {
+	mu      sync.Mutex
+	budget  *Budget
+	devices map[int64]*Device
+	events  []Event
+}
+
+func (db *memDB) Exec(_ context.Context, sql string, args ...any) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if strings.Contains(sql, "INSERT INTO load_shed_event") {
+		e := Event{UserID: args[0].(int64), DeviceID: args[1].(int64), Action: args[2].(string), Status: args[3].(string),
+			TotalWatts: args[4].(float64), BudgetWatts: args[5].(float64), DeviceWatts: args[6].(float64)}
+		db.events = append(db.events, e)
+		return nil
+	}
+	d, ok := db.devices[args[0].(int64)]
+	if !ok {
+		return fmt.Errorf("no device %v", args[0])
+	}
+	switch {
+	case strings.Contains(sql, "(device_id, shed_at, shed_watts)"): // MarkShed
+		at, w := args[1].(time.Time), args[2].(float64)
+		d.ShedAt, d.ShedWatts, d.FailedAt = &at, &w, nil
+	case strings.Contains(sql, "UPDATE device_load_shed SET shed_at = NULL"): // ClearShed
+		d.ShedAt, d.ShedWatts, d.FailedAt = nil, nil, nil
+	case strings.Contains(sql, "(device_id, failed_at)"): // MarkFailed
+		at := args[1].(time.Time)
+		d.FailedAt = &at
+	case strings.Contains(sql, "(device_id, override_until)"): // SetOverride
+		until := args[1].(time.Time)
+		d.OverrideUntil, d.ShedAt, d.ShedWatts, d.FailedAt = &until, nil, nil, nil
+	case strings.Contains(sql, "SET override_until = NULL"):
+		d.OverrideUntil = nil
+	default:
+		return fmt.Errorf("unexpected exec: %s", sql)
+	}
+	return nil
+}
+
+func (db *memDB) QueryRow(_ context.Context, sql string, _ ...any) interface{ Scan(dest ...any) error } {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if !strings.Contains(sql, "FROM power_budget WHERE user_id") {
+		panic("unexpected query: " + sql)
+	}
+	if db.budget == nil {
+		return &valueRows{err: pgx.ErrNoRows}
+	}
+	rows := db.budgetRows()
+	rows.Next()
+	return rows
+}
+
+func (db *memDB) Query(_ context.Context, sql string, _ ...any) (Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch {
+	case strings.Contains(sql, "FROM power_budget WHERE enabled"):
+		return db.budgetRows(), nil
+	case strings.Contains(sql, "FROM device d"):
+		list := make([]*Device, 0, len(db.devices))
+		for _, d := range db.devices {
+			list = append(list, d)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Priority != list[j].Priority {
+				return list[i].Priority < list[j].Priority
+			}
+			return list[i].DeviceID < list[j].DeviceID
+		})
+		rows := &valueRows{}
+		for _, d := range list {
+			rows.list = append(rows.list, []any{d.DeviceID, d.DeviceName, d.PowerState, d.Priority, d.NeverShed,
+				d.OverrideUntil, d.ShedAt, d.ShedWatts, d.FailedAt})
+		}
+		return rows, nil
+	}
+	panic("unexpected query: " + sql)
+}
+
+func (db *memDB) budgetRows() *valueRows {
+	b := db.budget
+	return &valueRows{list: [][]any{{b.UserID, b.BudgetWatts, b.Enabled, b.AutoRestore, b.RestoreMarginWatts,
+		b.RestoreDelayMinutes, b.OverrideMinutes, b.UpdatedAt}}}
+}
+
+func (db *memDB) device(id int64) Device {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return *db.devices[id]
+}
+
+func (db *memDB) actions() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var out []string
+	for _, e := range db.events {
+		out = append(out, fmt.Sprintf("%s %d %s", e.Action, e.DeviceID, e.Status))
+	}
+	return out
+}
+
+// valueRows returns fixed rows, copying each value into its destination.
+type valueRows struct {
+	list [][]any
+	i    int
+	err  error
+}
+
+func (r *valueRows) Next() bool { r.i++; return r.i <= len(r.list) }
+func (r *valueRows) Close()     {}
+func (r *valueRows) Err() error { return nil }
+
+func (r *valueRows) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, v := range r.list[r.i-1] {
+		target := reflect.ValueOf(dest[i]).Elem()
+		if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+			target.SetZero()
+			continue
+		}
+		target.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// fakeControl switches the devices of memDB. Devices missing from
+// controllable have no driver connection; those in failing reject commands.
+type fakeControl struct {
+	db           *memDB
+	controllable map[int64]bool
+	failing      map[int64]bool
+	calls        []string
+}
+
+func (c *fakeControl) DeviceForUser(_ context.Context, userID, deviceID int64) (*devices.Device, error) {
+	if _, ok := c.db.devices[deviceID]; !ok || userID != testUser {
+		return nil, devices.ErrNotFound
+	}
+	return &devices.Device{ID: deviceID, UserID: userID}, nil
+}
+
+func (c *fakeControl) Controllable(_ context.Context, d *devices.Device) bool {
+	return c.controllable[d.ID]
+}
+
+func (c *fakeControl) SetPower(_ context.Context, d *devices.Device, on bool) (*devices.Device, error) {
+	c.calls = append(c.calls, fmt.Sprintf("%d %v", d.ID, on))
+	if c.failing[d.ID] {
+		return nil, devices.ErrDeviceControl
+	}
+	c.db.mu.Lock()
+	c.db.devices[d.ID].PowerState = &on
+	c.db.mu.Unlock()
+	return d, nil
+}
+
+// fakeReadings reports a fresh reading for every device that is on.
+type fakeReadings struct {
+	db    *memDB
+	watts map[int64]float64
+	at    time.Time
+}
+
+func (f *fakeReadings) GetLatestByUserDevices(context.Context, int64) ([]telemetry.Telemetry, error) {
+	var out []telemetry.Telemetry
+	for id, w := range f.watts {
+		if d := f.db.device(id); d.PowerState == nil || *d.PowerState {
+			out = append(out, telemetry.Telemetry{DeviceID: id, Power: w, Timestamp: f.at})
+		}
+	}
+	return out, nil
+}
+
+type testDevice struct {
+	id           int64
+	priority     int
+	watts        float64
+	neverShed    bool
+	controllable bool
+	shedAgo      time.Duration // > 0: shed that long ago
+}
+
+func newTestShedder(now time.Time, budget Budget, list []testDevice) (*Shedder, *memDB, *fakeControl) {
+	budget.UserID = testUser
+	db := &memDB{budget: &budget, devices: map[int64]*Device{}}
+	control := &fakeControl{db: db, controllable: map[int64]bool{}, failing: map[int64]bool{}}
+	readings := &fakeReadings{db: db, watts: map[int64]float64{}, at: now.Add(-time.Minute)}
+	for _, td := range list {
+		on := td.shedAgo == 0
+		d := &Device{DeviceID: td.id, DeviceName: fmt.Sprint("device ", td.id), PowerState: &on,
+			Priority: td.priority, NeverShed: td.neverShed}
+		if td.shedAgo > 0 {
+			at, w := now.Add(-td.shedAgo), td.watts
+			d.ShedAt, d.ShedWatts = &at, &w
+		}
+		db.devices[td.id] = d
+		readings.watts[td.id] = td.watts
+		control.controllable[td.id] = td.controllable
+	}
+	return NewShedder(NewRepo(db), control, readings), db, control
+}
+
+func TestShedRanking(t *testing.T) {
+	now := time.Date(2026, 3, 10, 19, 0, 0, 0, time.UTC)
+	s, db, control := newTestShedder(now, Budget{BudgetWatts: 2300, Enabled: true}, []testDevice{
+		{id: 1, priority: 0, watts: 300, controllable: true},
+		{id: 2, priority: 0, watts: 600, controllable: true},
+		{id: 3, priority: 1, watts: 800, controllable: true},
+		{id: 4, priority: 0, watts: 500, controllable: true, neverShed: true},
+		{id: 5, priority: 0, watts: 900}, // meter without a driver connection
+	})
+
+	s.CheckAll(context.Background(), now)
+
+	// Lowest priority first and, within it, highest power first, until 3100 W fits 2300 W
+	if want := []string{"2 false", "1 false"}; !reflect.DeepEqual(control.calls, want) {
+		t.Errorf("commands = %v, want %v", control.calls, want)
+	}
+	if want := []string{"shed 2 success", "shed 1 success"}; !reflect.DeepEqual(db.actions(), want) {
+		t.Errorf("events = %v, want %v", db.actions(), want)
+	}
+	for id, shed := range map[int64]bool{1: true, 2: true, 3: false, 4: false, 5: false} {
+		d := db.device(id)
+		if d.Shed() != shed {
+			t.Errorf("device %d shed = %v, want %v", id, d.Shed(), shed)
+		}
+		if d.FailedAt != nil {
+			t.Errorf("device %d marked failed", id)
+		}
+	}
+
+	// Under budget now: nothing more happens
+	s.CheckAll(context.Background(), now.Add(tickInterval))
+	if len(control.calls) != 2 {
+		t.Errorf("commands after the load fits = %v", control.calls)
+	}
+}
+
+func TestShedFailureIsRetriedLater(t *testing.T) {
+	now := time.Date(2026, 3, 10, 19, 0, 0, 0, time.UTC)
+	s, db, control := newTestShedder(now, Budget{BudgetWatts: 1000, Enabled: true}, []testDevice{
+		{id: 1, watts: 800, controllable: true},
+		{id: 2, watts: 700, controllable: true},
+	})
+	control.failing[1] = true
+
+	s.CheckAll(context.Background(), now)
+	if want := []string{"shed 1 failed", "shed 2 success"}; !reflect.DeepEqual(db.actions(), want) {
+		t.Fatalf("events = %v, want %v", db.actions(), want)
+	}
+	if db.device(1).FailedAt == nil {
+		t.Fatal("failed device not marked")
+	}
+
+	// Over budget again, but the failed device waits for retryAfter
+	db.devices[3] = &Device{DeviceID: 3}
+	s.readings.(*fakeReadings).watts[3] = 900
+	control.controllable[3] = true
+	s.CheckAll(context.Background(), now.Add(time.Minute))
+	if got := control.calls[len(control.calls)-1]; got != "3 false" {
+		t.Errorf("last command = %s, want device 3 shed while device 1 waits", got)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	now := time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC)
+	budget := Budget{BudgetWatts: 2000, Enabled: true, AutoRestore: true, RestoreMarginWatts: 200, RestoreDelayMinutes: 5}
+
+	tests := []struct {
+		name    string
+		devices []testDevice
+		want    []string
+	}{
+		{
+			"highest priority first, one per check",
+			[]testDevice{
+				{id: 1, watts: 1000, controllable: true},
+				{id: 2, priority: 0, watts: 400, controllable: true, shedAgo: 10 * time.Minute},
+				{id: 3, priority: 2, watts: 300, controllable: true, shedAgo: 10 * time.Minute},
+			},
+			[]string{"3 true"},
+		},
+		{
+			"restore delay not over",
+			[]testDevice{
+				{id: 1, watts: 1000, controllable: true},
+				{id: 2, watts: 400, controllable: true, shedAgo: 2 * time.Minute},
+			},
+			nil,
+		},
+		{
+			"no room under the margin",
+			[]testDevice{
+				{id: 1, watts: 1500, controllable: true},
+				{id: 2, watts: 400, controllable: true, shedAgo: 10 * time.Minute},
+			},
+			nil,
+		},
+		{
+			"a device that does not fit is skipped for a smaller one",
+			[]testDevice{
+				{id: 1, watts: 1000, controllable: true},
+				{id: 2, priority: 5, watts: 900, controllable: true, shedAgo: 10 * time.Minute},
+				{id: 3, priority: 1, watts: 300, controllable: true, shedAgo: 10 * time.Minute},
+			},
+			[]string{"3 true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db, control := newTestShedder(now, budget, tt.devices)
+			s.CheckAll(context.Background(), now)
+
+			if !reflect.DeepEqual(control.calls, tt.want) {
+				t.Errorf("commands = %v, want %v", control.calls, tt.want)
+			}
+			for _, td := range tt.devices {
+				restored := slices.Contains(tt.want, fmt.Sprintf("%d true", td.id))
+				if d := db.device(td.id); td.shedAgo > 0 && d.Shed() == restored {
+					t.Errorf("device %d shed = %v after restore %v", td.id, d.Shed(), restored)
+				}
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	now := time.Date(2026, 3, 10, 19, 0, 0, 0, time.UTC)
+	budget := Budget{BudgetWatts: 1000, Enabled: true, OverrideMinutes: 60}
+
+	t.Run("user switches a shed device back on", func(t *testing.T) {
+		s, db, control := newTestShedder(now, budget, []testDevice{
+			{id: 1, watts: 800, controllable: true, shedAgo: time.Minute},
+			{id: 2, watts: 700, controllable: true},
+		})
+		on := true
+		db.devices[1].PowerState = &on
+
+		s.CheckAll(context.Background(), now)
+		// Its reading counts from the next check on
+		s.CheckAll(context.Background(), now.Add(tickInterval))
+
+		d := db.device(1)
+		if d.Shed() || !d.Overridden(now) || !d.OverrideUntil.Equal(now.Add(time.Hour)) {
+			t.Errorf("device 1: shed %v, override until %v", d.Shed(), d.OverrideUntil)
+		}
+		// The overridden device stays on; the other one goes instead
+		if want := []string{"2 false"}; !reflect.DeepEqual(control.calls, want) {
+			t.Errorf("commands = %v, want %v", control.calls, want)
+		}
+		if want := []string{"override 1 success", "shed 2 success"}; !reflect.DeepEqual(db.actions(), want) {
+			t.Errorf("events = %v, want %v", db.actions(), want)
+		}
+	})
+
+	t.Run("override turns a shed device back on", func(t *testing.T) {
+		s, db, control := newTestShedder(now, budget, []testDevice{
+			{id: 1, watts: 800, controllable: true, shedAgo: time.Minute},
+		})
+
+		d, err := s.Override(context.Background(), testUser, 1, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Shed() || d.OverrideUntil == nil {
+			t.Errorf("returned device: shed %v, override until %v", d.Shed(), d.OverrideUntil)
+		}
+		if want := []string{"1 true"}; !reflect.DeepEqual(control.calls, want) {
+			t.Errorf("commands = %v, want %v", control.calls, want)
+		}
+		if got := db.device(1); got.Shed() || got.OverrideUntil == nil || got.OverrideUntil.Sub(time.Now()) > 30*time.Minute {
+			t.Errorf("stored device: shed %v, override until %v", got.Shed(), got.OverrideUntil)
+		}
+
+		if err := s.ClearOverride(context.Background(), testUser, 1); err != nil {
+			t.Fatal(err)
+		}
+		if db.device(1).OverrideUntil != nil {
+			t.Error("override not cleared")
+		}
+	})
+
+	t.Run("failed command leaves the device shed", func(t *testing.T) {
+		s, db, control := newTestShedder(now, budget, []testDevice{
+			{id: 1, watts: 800, controllable: true, shedAgo: time.Minute},
+		})
+		control.failing[1] = true
+
+		if _, err := s.Override(context.Background(), testUser, 1, 0); !errors.Is(err, devices.ErrDeviceControl) {
+			t.Fatalf("error = %v, want the command error", err)
+		}
+		if d := db.device(1); !d.Shed() || d.OverrideUntil != nil {
+			t.Errorf("device: shed %v, override until %v", d.Shed(), d.OverrideUntil)
+		}
+	})
+
+	t.Run("other user's device", func(t *testing.T) {
+		s, _, _ := newTestShedder(now, budget, []testDevice{{id: 1, watts: 800, controllable: true}})
+		if _, err := s.Override(context.Background(), testUser, 7, 0); !errors.Is(err, devices.ErrNotFound) {
+			t.Errorf("error = %v, want ErrNotFound", err)
+		}
+	})
+}
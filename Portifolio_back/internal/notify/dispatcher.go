@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/retry"
+)
+
+const (
+	dispatchInterval = 10 * time.Second // how often the outbox is checked
+	dispatchBatch    = 20               // messages claimed per check
+	sendTimeout      = time.Minute      // timeout for a single delivery
+	maxAttempts      = 8                // deliveries tried before a message is marked failed
+)
+
+// Dispatcher delivers outbox messages, retrying failures with exponential backoff.
+type Dispatcher struct {
+	repo   *Repo
+	sender Sender
+	done   chan struct{}
+}
+
+// NewDispatcher creates a dispatcher; call Start to run it.
+func NewDispatcher(repo *Repo, sender Sender) *Dispatcher {
+	return &Dispatcher{repo: repo, sender: sender, done: make(chan struct{})}
+}
+
+// Start delivers messages until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go d.run(ctx)
+}
+
+// Wait blocks until the dispatcher has stopped.
+func (d *Dispatcher) Wait() {
+	<-d.done
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	tick := time.NewTicker(dispatchInterval)
+	defer tick.Stop()
+
+	for {
+		d.DispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// DispatchDue sends every message whose next attempt is due.
+func (d *Dispatcher) DispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := d.repo.ClaimDue(ctx, dispatchBatch)
+		if err != nil {
+			log.Printf("Notifications: failed to read outbox: %v", err)
+			return
+		}
+		for i := range batch {
+			d.deliver(ctx, &batch[i])
+		}
+		if len(batch) < dispatchBatch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, m *Message) {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err := d.sender.Send(sendCtx, m)
+	cancel()
+
+	if err == nil {
+		if err := d.repo.MarkSent(ctx, m.ID); err != nil {
+			log.Printf("Notifications: failed to mark message %d sent: %v", m.ID, err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		return // shutting down: the claim lease expires and the message is retried
+	}
+
+	attempts := m.Attempts + 1
+	if attempts >= maxAttempts {
+		log.Printf("Notifications: giving up on message %d to %s after %d attempts: %v", m.ID, m.Recipient, attempts, err)
+		err = d.repo.MarkFailed(ctx, m.ID, err.Error())
+	} else {
+		log.Printf("Notifications: failed to send message %d to %s (attempt %d): %v", m.ID, m.Recipient, attempts, err)
+		err = d.repo.MarkRetry(ctx, m.ID, err.Error(), time.Now().Add(retry.Backoff(attempts)))
+	}
+	if err != nil {
+		log.Printf("Notifications: failed to record attempt for message %d: %v", m.ID, err)
+	}
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memOutbox is an in-memory notification_outbox answering the queries of the dispatcher.
+type memOutbox struct {
+	mu   sync.Mutex
+	msgs map[int64]*outboxRow
+}
+
+type outboxRow struct {
+	Message
+	Status    string
+	LastError string
+	Next      time.Time
+}
+
+func newMemOutbox(msgs ...Message) *memOutbox {
+	o := &memOutbox{msgs: make(map[int64]*outboxRow)}
+	for _, m := range msgs {
+		o.msgs[m.ID] = &outboxRow{Message: m, Status: StatusPending}
+	}
+	return o
+}
+
+func (o *memOutbox) Exec(_ context.Context, sql string, args ...any) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	row, ok := o.msgs[args[0].(int64)]
+	if !ok {
+		return fmt.Errorf("no message %v", args[0])
+	}
+	row.Attempts++
+	switch {
+	case strings.Contains(sql, "status = 'sent'"):
+		row.Status = StatusSent
+	case strings.Contains(sql, "status = 'failed'"):
+		row.Status, row.LastError = StatusFailed, args[1].(string)
+	case strings.Contains(sql, "next_attempt_at = $3"):
+		row.LastError, row.Next = args[1].(string), args[2].(time.Time)
+	default:
+		return fmt.Errorf("unexpected exec: %s", sql)
+	}
+	return nil
+}
+
+func (o *memOutbox) QueryRow(_ context.Context, sql string, _ ...any) interface{ Scan(dest ...any) error } {
+	panic("unexpected query: " + sql)
+}
+
+// Query answers ClaimDue with every pending message that is due, then hides them for the lease.
+func (o *memOutbox) Query(_ context.Context, _ string, args ...any) (Rows, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	rows := &messageRows{}
+	for _, row := range o.msgs {
+		if row.Status == StatusPending && !row.Next.After(now) && len(rows.list) < args[0].(int) {
+			row.Next = now.Add(claimLease)
+			rows.list = append(rows.list, row.Message)
+		}
+	}
+	return rows, nil
+}
+
+func (o *memOutbox) row(id int64) outboxRow {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return *o.msgs[id]
+}
+
+type messageRows struct {
+	list []Message
+	i    int
+}
+
+func (r *messageRows) Next() bool { r.i++; return r.i <= len(r.list) }
+func (r *messageRows) Close()     {}
+func (r *messageRows) Err() error { return nil }
+
+func (r *messageRows) Scan(dest ...any) error {
+	m := r.list[r.i-1]
+	*dest[0].(*int64) = m.ID
+	*dest[1].(*int64) = m.UserID
+	*dest[2].(*string) = m.Recipient
+	*dest[3].(*string) = m.Subject
+	*dest[4].(*string) = m.Text
+	*dest[5].(*string) = m.HTML
+	*dest[6].(*int) = m.Attempts
+	return nil
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.reject["bounce@example.com"] = true
+	sender, err := NewSMTPSender(srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outbox := newMemOutbox(
+		Message{ID: 1, UserID: 1, Recipient: "ok@example.com", Subject: "a", Text: "a", HTML: "a"},
+		Message{ID: 2, UserID: 1, Recipient: "bounce@example.com", Subject: "b", Text: "b", HTML: "b"},
+		Message{ID: 3, UserID: 1, Recipient: "bounce@example.com", Subject: "c", Text: "c", HTML: "c", Attempts: 3},
+		Message{ID: 4, UserID: 1, Recipient: "bounce@example.com", Subject: "d", Text: "d", HTML: "d", Attempts: maxAttempts - 1},
+	)
+	d := NewDispatcher(NewRepo(outbox), sender)
+
+	start := time.Now()
+	d.DispatchDue(context.Background())
+	end := time.Now()
+
+	if got := outbox.row(1); got.Status != StatusSent || got.Attempts != 1 {
+		t.Errorf("delivered message: status %s, attempts %d", got.Status, got.Attempts)
+	}
+	if msgs := srv.messages(); len(msgs) != 1 || msgs[0].To[0] != "ok@example.com" {
+		t.Errorf("server got %+v, want one message to ok@example.com", msgs)
+	}
+
+	for _, tt := range []struct {
+		id       int64
+		attempts int
+		delay    time.Duration
+	}{
+		{2, 1, 30 * time.Second}, // first failure
+		{3, 4, 4 * time.Minute},  // fourth failure: 30s doubled three times
+	} {
+		got := outbox.row(tt.id)
+		if got.Status != StatusPending || got.Attempts != tt.attempts || !strings.Contains(got.LastError, "550") {
+			t.Errorf("message %d: status %s, attempts %d, error %q", tt.id, got.Status, got.Attempts, got.LastError)
+		}
+		if got.Next.Before(start.Add(tt.delay)) || got.Next.After(end.Add(tt.delay)) {
+			t.Errorf("message %d: next attempt in %v, want %v", tt.id, got.Next.Sub(start), tt.delay)
+		}
+	}
+
+	if got := outbox.row(4); got.Status != StatusFailed || got.Attempts != maxAttempts {
+		t.Errorf("exhausted message: status %s, attempts %d", got.Status, got.Attempts)
+	}
+
+	// Nothing is due again until the backoff elapses
+	d.DispatchDue(context.Background())
+	if got := outbox.row(2); got.Attempts != 1 {
+		t.Errorf("message retried before its backoff: %d attempts", got.Attempts)
+	}
+}
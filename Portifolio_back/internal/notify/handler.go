@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles notification preference HTTP requests.
+type Handler struct {
+	Service *Service
+}
+
+// NewHandler creates a new notifications handler.
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+// RegisterRoutes registers notification routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	n := r.Group("/notifications")
+	n.GET("/preferences", h.GetPreferences)
+	n.PUT("/preferences", h.SetPreferences)
+	n.POST("/email/confirm", h.ConfirmEmail)
+	n.POST("/test", h.SendTest)
+}
+
+// GetPreferences returns the user's notification preferences.
+func (h *Handler) GetPreferences(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	p, err := h.Service.Repo.GetPreferences(c.Request.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SetPreferences replaces the user's notification preferences. A new e-mail
+// address is only used after ConfirmEmail; until then it is returned as pending_email.
+func (h *Handler) SetPreferences(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: min_level must be warning or danger"})
+		return
+	}
+	if req.MinLevel == "" {
+		req.MinLevel = "warning"
+	}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			req.Email = nil
+		} else if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+			return
+		} else {
+			req.Email = &email
+		}
+	}
+
+	ctx := c.Request.Context()
+	if err := h.Service.Repo.UpsertPreferences(ctx, userID, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save notification preferences"})
+		return
+	}
+	err := h.Service.SetEmail(ctx, userID, req.Email)
+	if errors.Is(err, ErrNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "e-mail notifications are not configured: the address cannot be confirmed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change notification e-mail"})
+		return
+	}
+	p, err := h.Service.Repo.GetPreferences(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// ConfirmEmail activates the pending notification address with the token e-mailed to it.
+func (h *Handler) ConfirmEmail(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ConfirmEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.Service.ConfirmEmail(ctx, userID, req.Token)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm notification e-mail"})
+		return
+	}
+	p, err := h.Service.Repo.GetPreferences(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SendTest queues a test e-mail to the user's notification address.
+func (h *Handler) SendTest(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if !h.Service.Enabled {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "e-mail notifications are not configured"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	p, err := h.Service.Repo.GetPreferences(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notification preferences"})
+		return
+	}
+	id, err := h.Service.Repo.Enqueue(ctx, &Message{
+		UserID:    userID,
+		Recipient: p.Recipient,
+		Subject:   "Test notification",
+		Text:      "E-mail notifications from Energy Controller are working.\n",
+		HTML:      "<p>E-mail notifications from Energy Controller are working.</p>\n",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue test e-mail"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"id": id, "recipient": p.Recipient})
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
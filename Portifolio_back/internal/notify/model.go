@@ -0,0 +1,56 @@
+package notify
+
+import "time"
+
+// Outbox message states.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed" // gave up after the maximum number of attempts
+)
+
+// Preferences controls which alerts a user is e-mailed about.
+type Preferences struct {
+	EmailEnabled   bool       `json:"email_enabled"`
+	Email          *string    `json:"email,omitempty"`         // confirmed address overriding the account e-mail
+	PendingEmail   *string    `json:"pending_email,omitempty"` // address waiting for confirmation
+	MinLevel       string     `json:"min_level"`               // "warning" (all alerts) or "danger"
+	NotifyResolved bool       `json:"notify_resolved"`
+	Recipient      string     `json:"recipient"` // address messages are sent to
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// PreferencesRequest is the payload for updating notification preferences.
+// An Email other than the account and the confirmed address is only used once
+// confirmed with the token e-mailed to it; empty goes back to the account e-mail.
+type PreferencesRequest struct {
+	EmailEnabled   bool    `json:"email_enabled"`
+	Email          *string `json:"email"`
+	MinLevel       string  `json:"min_level" binding:"omitempty,oneof=warning danger"`
+	NotifyResolved bool    `json:"notify_resolved"`
+}
+
+// ConfirmEmailRequest is the payload for confirming a notification address.
+type ConfirmEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// recipient is a user with the preferences that apply to them.
+type recipient struct {
+	Name         string
+	AccountEmail string
+	Prefs        Preferences
+}
+
+// Message is an e-mail waiting in the outbox.
+type Message struct {
+	ID          int64
+	UserID      int64
+	DeviceID    *int64 // set for alert e-mails, with AlertSource
+	AlertSource string
+	Recipient   string
+	Subject     string
+	Text        string
+	HTML        string
+	Attempts    int
+}
@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a user does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// claimLease is how long a claimed message stays hidden from other dispatchers.
+// A message whose send is interrupted (e.g. by a crash) is retried after it.
+const claimLease = 5 * time.Minute
+
+// Repo provides database operations for notification preferences and the outbox.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new notifications repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// GetPreferences returns the notification preferences of a user, with defaults when unset.
+func (r *Repo) GetPreferences(ctx context.Context, userID int64) (*Preferences, error) {
+	rc, err := r.recipient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &rc.Prefs, nil
+}
+
+func (r *Repo) recipient(ctx context.Context, userID int64) (*recipient, error) {
+	sql := `SELECT u.name, u.email, COALESCE(p.email_enabled, TRUE), p.email, p.pending_email,
+				COALESCE(p.min_level, 'warning'), COALESCE(p.notify_resolved, TRUE), p.updated_at
+			FROM app_user u
+			LEFT JOIN notification_preference p ON p.user_id = u.id
+			WHERE u.id = $1`
+	var rc recipient
+	p := &rc.Prefs
+	err := r.q.QueryRow(ctx, sql, userID).Scan(&rc.Name, &rc.AccountEmail, &p.EmailEnabled, &p.Email, &p.PendingEmail,
+		&p.MinLevel, &p.NotifyResolved, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	p.Recipient = rc.AccountEmail
+	if p.Email != nil && *p.Email != "" {
+		p.Recipient = *p.Email
+	}
+	return &rc, nil
+}
+
+// UpsertPreferences creates or replaces the notification preferences of a user,
+// except the notification address (see ClearEmail, RequestEmail and ConfirmEmail).
+func (r *Repo) UpsertPreferences(ctx context.Context, userID int64, req *PreferencesRequest) error {
+	sql := `INSERT INTO notification_preference (user_id, email_enabled, min_level, notify_resolved, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (user_id) DO UPDATE
+			SET email_enabled = EXCLUDED.email_enabled, min_level = EXCLUDED.min_level,
+				notify_resolved = EXCLUDED.notify_resolved, updated_at = NOW()`
+	return r.q.Exec(ctx, sql, userID, req.EmailEnabled, req.MinLevel, req.NotifyResolved)
+}
+
+// ClearEmail sends notifications back to the account e-mail, dropping any
+// confirmed or pending address.
+func (r *Repo) ClearEmail(ctx context.Context, userID int64) error {
+	sql := `UPDATE notification_preference
+			SET email = NULL, pending_email = NULL, email_token_hash = NULL, email_token_expires_at = NULL
+			WHERE user_id = $1`
+	return r.q.Exec(ctx, sql, userID)
+}
+
+// CancelPendingEmail drops an address waiting for confirmation.
+func (r *Repo) CancelPendingEmail(ctx context.Context, userID int64) error {
+	sql := `UPDATE notification_preference
+			SET pending_email = NULL, email_token_hash = NULL, email_token_expires_at = NULL
+			WHERE user_id = $1`
+	return r.q.Exec(ctx, sql, userID)
+}
+
+// RequestEmail stores an address waiting for confirmation with the hash of its
+// token, replacing any earlier request.
+func (r *Repo) RequestEmail(ctx context.Context, userID int64, email, tokenHash string, expires time.Time) error {
+	sql := `UPDATE notification_preference
+			SET pending_email = $2, email_token_hash = $3, email_token_expires_at = $4
+			WHERE user_id = $1`
+	return r.q.Exec(ctx, sql, userID, email, tokenHash, expires)
+}
+
+// ConfirmEmail makes the pending address the notification address when the
+// token hash matches and has not expired. It returns ErrNotFound otherwise.
+func (r *Repo) ConfirmEmail(ctx context.Context, userID int64, tokenHash string) error {
+	sql := `UPDATE notification_preference
+			SET email = pending_email, pending_email = NULL, email_token_hash = NULL,
+				email_token_expires_at = NULL, updated_at = NOW()
+			WHERE user_id = $1 AND email_token_hash = $2 AND email_token_expires_at > NOW()
+			RETURNING user_id`
+	var id int64
+	err := r.q.QueryRow(ctx, sql, userID, tokenHash).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeviceName returns the name of a device ("" when it no longer exists).
+func (r *Repo) DeviceName(ctx context.Context, deviceID int64) (string, error) {
+	var name string
+	err := r.q.QueryRow(ctx, `SELECT name FROM device WHERE id = $1`, deviceID).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return name, err
+}
+
+// Enqueue stores a message in the outbox and returns its ID.
+func (r *Repo) Enqueue(ctx context.Context, m *Message) (int64, error) {
+	sql := `INSERT INTO notification_outbox (user_id, device_id, alert_source, channel, recipient, subject, text_body, html_body)
+			VALUES ($1, $2, NULLIF($3, ''), 'email', $4, $5, $6, $7) RETURNING id`
+	var id int64
+	err := r.q.QueryRow(ctx, sql, m.UserID, m.DeviceID, m.AlertSource, m.Recipient, m.Subject, m.Text, m.HTML).Scan(&id)
+	return id, err
+}
+
+// AlertQueuedSince reports whether an e-mail about an alert of the device and
+// source was queued at or after since.
+func (r *Repo) AlertQueuedSince(ctx context.Context, deviceID int64, source string, since time.Time) (bool, error) {
+	sql := `SELECT EXISTS(
+				SELECT 1 FROM notification_outbox
+				WHERE device_id = $1 AND alert_source = $2 AND created_at >= $3
+			)`
+	var queued bool
+	err := r.q.QueryRow(ctx, sql, deviceID, source, since).Scan(&queued)
+	return queued, err
+}
+
+// ClaimDue returns up to limit pending messages whose next attempt is due and
+// pushes their next attempt back by claimLease so no other dispatcher takes them.
+func (r *Repo) ClaimDue(ctx context.Context, limit int) ([]Message, error) {
+	sql := `UPDATE notification_outbox SET next_attempt_at = NOW() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT id FROM notification_outbox
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, user_id, recipient, subject, text_body, html_body, attempts`
+	rows, err := r.q.Query(ctx, sql, limit, claimLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Recipient, &m.Subject, &m.Text, &m.HTML, &m.Attempts); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// MarkSent records a successful delivery.
+func (r *Repo) MarkSent(ctx context.Context, id int64) error {
+	sql := `UPDATE notification_outbox SET status = 'sent', attempts = attempts + 1, sent_at = NOW(), last_error = NULL
+			WHERE id = $1`
+	return r.q.Exec(ctx, sql, id)
+}
+
+// MarkRetry records a failed attempt and schedules the next one.
+func (r *Repo) MarkRetry(ctx context.Context, id int64, sendErr string, next time.Time) error {
+	sql := `UPDATE notification_outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+			WHERE id = $1`
+	return r.q.Exec(ctx, sql, id, sendErr, next)
+}
+
+// MarkFailed records a failed attempt and gives up on the message.
+func (r *Repo) MarkFailed(ctx context.Context, id int64, sendErr string) error {
+	sql := `UPDATE notification_outbox SET status = 'failed', attempts = attempts + 1, last_error = $2
+			WHERE id = $1`
+	return r.q.Exec(ctx, sql, id, sendErr)
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+)
+
+// emailTokenTTL is how long a notification address can be confirmed after it is set.
+const emailTokenTTL = 24 * time.Hour
+
+// defaultAlertThrottle is the shortest time between two e-mails about the alerts
+// of one device and source, so a device hovering around a limit does not send
+// one per reading. Changes in between are only shown in the app.
+const defaultAlertThrottle = 15 * time.Minute
+
+// ErrNotConfigured is returned when e-mail must be sent but no SMTP server is configured.
+var ErrNotConfigured = errors.New("e-mail notifications are not configured")
+
+// Service turns alert changes into outbox messages according to each user's preferences.
+type Service struct {
+	Repo     *Repo
+	Enabled  bool          // false when no SMTP server is configured: nothing is queued
+	Throttle time.Duration // shortest time between alert e-mails per device and source; 0 sends every change
+}
+
+// NewService creates a notification service.
+func NewService(repo *Repo, enabled bool) *Service {
+	return &Service{Repo: repo, Enabled: enabled, Throttle: defaultAlertThrottle}
+}
+
+// AlertChanged queues an e-mail for an alert that was opened or resolved.
+// Failures are logged: a notification problem must not fail alert evaluation.
+func (s *Service) AlertChanged(ctx context.Context, userID int64, a *alerts.Alert) {
+	if !s.Enabled {
+		return
+	}
+	if err := s.queueAlert(ctx, userID, a); err != nil {
+		log.Printf("Warning: failed to queue notification for alert %d: %v", a.ID, err)
+	}
+}
+
+func (s *Service) queueAlert(ctx context.Context, userID int64, a *alerts.Alert) error {
+	rc, err := s.Repo.recipient(ctx, userID)
+	if err != nil {
+		return err
+	}
+	resolved := a.State == alerts.StateResolved
+	if !wants(&rc.Prefs, a.Level, resolved) {
+		return nil
+	}
+	if s.Throttle > 0 {
+		queued, err := s.Repo.AlertQueuedSince(ctx, a.DeviceID, a.Source, time.Now().Add(-s.Throttle))
+		if err != nil || queued {
+			return err
+		}
+	}
+
+	deviceName := a.DeviceName
+	if deviceName == "" {
+		if deviceName, err = s.Repo.DeviceName(ctx, a.DeviceID); err != nil {
+			return err
+		}
+	}
+	subject, text, html, err := renderAlert(alertData{
+		UserName:   rc.Name,
+		DeviceName: deviceName,
+		Resolved:   resolved,
+		Alert:      a,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Repo.Enqueue(ctx, &Message{
+		UserID:      userID,
+		DeviceID:    &a.DeviceID,
+		AlertSource: a.Source,
+		Recipient:   rc.Prefs.Recipient,
+		Subject:     subject,
+		Text:        text,
+		HTML:        html,
+	})
+	return err
+}
+
+// wants reports whether the preferences ask for an e-mail about an alert of this level.
+func wants(p *Preferences, level string, resolved bool) bool {
+	if !p.EmailEnabled || p.Recipient == "" {
+		return false
+	}
+	if resolved && !p.NotifyResolved {
+		return false
+	}
+	return p.MinLevel != alerts.LevelDanger || level == alerts.LevelDanger
+}
+
+// SetEmail changes the notification address. Clearing it, the account e-mail
+// and the already confirmed address apply at once; any other address is kept
+// pending and sent a token that ConfirmEmail must receive before it is used.
+func (s *Service) SetEmail(ctx context.Context, userID int64, email *string) error {
+	rc, err := s.Repo.recipient(ctx, userID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case email == nil || strings.EqualFold(*email, rc.AccountEmail):
+		return s.Repo.ClearEmail(ctx, userID)
+	case rc.Prefs.Email != nil && strings.EqualFold(*email, *rc.Prefs.Email):
+		return s.Repo.CancelPendingEmail(ctx, userID)
+	case !s.Enabled:
+		return ErrNotConfigured
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+	expires := time.Now().Add(emailTokenTTL)
+	if err := s.Repo.RequestEmail(ctx, userID, *email, hashToken(token), expires); err != nil {
+		return err
+	}
+
+	subject, text, html, err := renderConfirmation(confirmData{UserName: rc.Name, Email: *email, Token: token, Expires: expires})
+	if err != nil {
+		return err
+	}
+	_, err = s.Repo.Enqueue(ctx, &Message{
+		UserID:    userID,
+		Recipient: *email,
+		Subject:   subject,
+		Text:      text,
+		HTML:      html,
+	})
+	return err
+}
+
+// ConfirmEmail makes the pending notification address the active one.
+// It returns ErrNotFound when the token is wrong or has expired.
+func (s *Service) ConfirmEmail(ctx context.Context, userID int64, token string) error {
+	return s.Repo.ConfirmEmail(ctx, userID, hashToken(strings.TrimSpace(token)))
+}
+
+// hashToken returns the form a confirmation token is stored in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+)
+
+// memQueue answers the queries of queueing an alert e-mail for one user with
+// the default preferences, keeping the queued messages in memory.
+type memQueue struct {
+	queued []queuedAlert
+}
+
+type queuedAlert struct {
+	deviceID int64
+	source   string
+	at       time.Time
+	subject  string
+}
+
+func (q *memQueue) Exec(_ context.Context, sql string, _ ...any) error {
+	return fmt.Errorf("unexpected exec: %s", sql)
+}
+
+func (q *memQueue) Query(_ context.Context, sql string, _ ...any) (Rows, error) {
+	return nil, fmt.Errorf("unexpected query: %s", sql)
+}
+
+func (q *memQueue) QueryRow(_ context.Context, sql string, args ...any) interface{ Scan(dest ...any) error } {
+	switch {
+	case strings.Contains(sql, "FROM app_user u"):
+		return scanFunc(func(dest ...any) error {
+			*dest[0].(*string) = "Ana"
+			*dest[1].(*string) = "ana@example.com"
+			*dest[2].(*bool) = true
+			*dest[5].(*string) = alerts.LevelWarning
+			*dest[6].(*bool) = true
+			return nil
+		})
+	case strings.Contains(sql, "SELECT EXISTS"):
+		deviceID, source, since := args[0].(int64), args[1].(string), args[2].(time.Time)
+		return scanFunc(func(dest ...any) error {
+			*dest[0].(*bool) = false
+			for _, m := range q.queued {
+				if m.deviceID == deviceID && m.source == source && !m.at.Before(since) {
+					*dest[0].(*bool) = true
+				}
+			}
+			return nil
+		})
+	case strings.Contains(sql, "INSERT INTO notification_outbox"):
+		q.queued = append(q.queued, queuedAlert{*args[1].(*int64), args[2].(string), time.Now(), args[4].(string)})
+		return scanFunc(func(dest ...any) error {
+			*dest[0].(*int64) = int64(len(q.queued))
+			return nil
+		})
+	}
+	panic("unexpected query: " + sql)
+}
+
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error { return f(dest...) }
+
+func TestAlertChangedThrottle(t *testing.T) {
+	alert := func(deviceID int64, source, state string) *alerts.Alert {
+		return &alerts.Alert{DeviceID: deviceID, DeviceName: "Geladeira", Source: source, Level: alerts.LevelWarning,
+			State: state, Message: "limit", OpenedAt: time.Now()}
+	}
+	flapping := []*alerts.Alert{
+		alert(1, alerts.SourceThreshold, alerts.StateOpen),
+		alert(1, alerts.SourceThreshold, alerts.StateResolved),
+		alert(1, alerts.SourceThreshold, alerts.StateOpen),
+		alert(1, alerts.SourceThreshold, alerts.StateResolved),
+	}
+
+	tests := []struct {
+		name     string
+		throttle time.Duration
+		changes  []*alerts.Alert
+		want     int
+	}{
+		{"flapping device sends once", defaultAlertThrottle, flapping, 1},
+		{"no throttle sends every change", 0, flapping, 4},
+		{"other devices and sources are not throttled", defaultAlertThrottle, []*alerts.Alert{
+			alert(1, alerts.SourceThreshold, alerts.StateOpen),
+			alert(2, alerts.SourceThreshold, alerts.StateOpen),
+			alert(1, alerts.SourceAnomaly, alerts.StateOpen),
+			alert(1, alerts.SourceThreshold, alerts.StateResolved),
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &memQueue{}
+			s := NewService(NewRepo(q), true)
+			s.Throttle = tt.throttle
+
+			for _, a := range tt.changes {
+				s.AlertChanged(context.Background(), 1, a)
+			}
+			if len(q.queued) != tt.want {
+				t.Errorf("queued %d e-mails, want %d: %+v", len(q.queued), tt.want, q.queued)
+			}
+		})
+	}
+}
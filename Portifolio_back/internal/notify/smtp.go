@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS modes for the SMTP connection.
+const (
+	TLSStartTLS = "starttls" // plain connection upgraded with STARTTLS (usually port 587)
+	TLSImplicit = "tls"      // TLS from the first byte (usually port 465)
+	TLSNone     = "none"     // no encryption, for local SMTP stand-ins such as Mailpit
+)
+
+const smtpTimeout = 30 * time.Second
+
+// Sender delivers one e-mail.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// SMTPConfig holds the SMTP server settings.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLS      string
+}
+
+// SMTPConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD,
+// SMTP_FROM and SMTP_TLS. E-mail is disabled when SMTP_HOST is empty.
+func SMTPConfigFromEnv() SMTPConfig {
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     587,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		TLS:      strings.ToLower(os.Getenv("SMTP_TLS")),
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if port, err := strconv.Atoi(v); err == nil && port > 0 {
+			cfg.Port = port
+		}
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	if cfg.From == "" {
+		cfg.From = "Energy Controller <no-reply@localhost>"
+	}
+	return cfg
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// Validate checks the settings can be used to send mail.
+func (c SMTPConfig) Validate() error {
+	switch c.TLS {
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return fmt.Errorf("SMTP_TLS must be %s, %s or %s", TLSStartTLS, TLSImplicit, TLSNone)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+	return nil
+}
+
+// SMTPSender sends mail through an SMTP server.
+type SMTPSender struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPSender creates an SMTP sender.
+func NewSMTPSender(cfg SMTPConfig) (*SMTPSender, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	from, _ := mail.ParseAddress(cfg.From)
+	return &SMTPSender{cfg: cfg, from: from}, nil
+}
+
+// Send delivers m, opening a new connection for each message.
+func (s *SMTPSender) Send(ctx context.Context, m *Message) error {
+	to, err := mail.ParseAddress(m.Recipient)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	body, err := buildMessage(s.from, to, m)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	if s.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection except to localhost
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage renders m as a multipart/alternative MIME message with text and HTML parts.
+func buildMessage(from, to *mail.Address, m *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server that records the messages it accepts.
+// Recipients listed in reject are refused with a permanent error.
+type fakeSMTP struct {
+	ln     net.Listener
+	reject map[string]bool
+
+	mu   sync.Mutex
+	msgs []smtpMessage
+}
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, reject: map[string]bool{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// config returns settings to send through the server without TLS.
+func (s *fakeSMTP) config() SMTPConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: "Energy Controller <alerts@example.com>", TLS: TLSNone}
+}
+
+func (s *fakeSMTP) messages() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.msgs...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var msg smtpMessage
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-fake greets you")
+			reply("250 8BITMIME")
+		case "MAIL":
+			msg = smtpMessage{From: addrParam(cmd)}
+			reply("250 OK")
+		case "RCPT":
+			to := addrParam(cmd)
+			if s.reject[to] {
+				reply("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			msg.To = append(msg.To, to)
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.msgs = append(s.msgs, msg)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// addrParam extracts the address of "MAIL FROM:<a>" or "RCPT TO:<a>".
+func addrParam(cmd string) string {
+	start, end := strings.Index(cmd, "<"), strings.Index(cmd, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return cmd[start+1 : end]
+}
+
+func TestSMTPSenderMessage(t *testing.T) {
+	srv := newFakeSMTP(t)
+	sender, err := NewSMTPSender(srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Message{
+		Recipient: "Ana Souza <ana@example.com>",
+		Subject:   "Atenção: Geladeira (danger)",
+		Text:      "Consumo acima do limite: 1.500 W — verifique o aparelho.\n",
+		HTML:      "<p>Consumo acima do limite: <strong>1.500 W</strong> — verifique o aparelho.</p>\n",
+	}
+	if err := sender.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := srv.messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(msgs))
+	}
+	got := msgs[0]
+	if got.From != "alerts@example.com" || len(got.To) != 1 || got.To[0] != "ana@example.com" {
+		t.Errorf("envelope from %q to %v", got.From, got.To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := parsed.Header
+	if from, err := h.AddressList("From"); err != nil || from[0].Address != "alerts@example.com" || from[0].Name != "Energy Controller" {
+		t.Errorf("From = %q", h.Get("From"))
+	}
+	if to, err := h.AddressList("To"); err != nil || to[0].Address != "ana@example.com" || to[0].Name != "Ana Souza" {
+		t.Errorf("To = %q", h.Get("To"))
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(h.Get("Subject")); err != nil || subject != m.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, m.Subject)
+	}
+	if _, err := h.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if id := h.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+	if h.Get("MIME-Version") != "1.0" {
+		t.Errorf("MIME-Version = %q", h.Get("MIME-Version"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", h.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", want.contentType, err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", ct, want.contentType)
+		}
+		if enc := part.Header.Get("Content-Transfer-Encoding"); enc != "quoted-printable" {
+			t.Errorf("part Content-Transfer-Encoding = %q", enc)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Line breaks travel as CRLF
+		if string(body) != strings.ReplaceAll(want.body, "\n", "\r\n") {
+			t.Errorf("%s body = %q, want %q", want.contentType, body, want.body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra MIME part: %v", err)
+	}
+}
+
+func TestSMTPSenderRejectedRecipient(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.reject["gone@example.com"] = true
+	sender, err := NewSMTPSender(srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sender.Send(context.Background(), &Message{Recipient: "gone@example.com", Subject: "s", Text: "t", HTML: "h"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("error = %v, want the 550 reply", err)
+	}
+	if n := len(srv.messages()); n != 0 {
+		t.Errorf("server accepted %d messages", n)
+	}
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+)
+
+// alertData is the data passed to the alert templates.
+type alertData struct {
+	UserName   string
+	DeviceName string
+	Resolved   bool
+	Alert      *alerts.Alert
+}
+
+const subjectTemplate = `{{if .Resolved}}Resolved{{else}}Alert{{end}}: {{.DeviceName}} ({{.Alert.Level}})`
+
+const textTemplate = `Hello {{.UserName}},
+
+{{if .Resolved -}}
+The {{.Alert.Level}} alert on {{.DeviceName}} has been resolved.
+{{- else -}}
+A {{.Alert.Level}} alert was raised on {{.DeviceName}}.
+{{- end}}
+
+{{.Alert.Message}}
+
+Value:     {{printf "%.2f" .Alert.Value}}
+Limit:     {{printf "%.2f" .Alert.Threshold}}
+Peak:      {{printf "%.2f" .Alert.PeakValue}}
+Opened at: {{fmtTime .Alert.OpenedAt}}
+{{- with .Alert.ResolvedAt}}
+Resolved:  {{fmtTime .}}
+{{- end}}
+
+You can change which alerts you are e-mailed about in the notification settings.
+`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; color: #222;">
+<p>Hello {{.UserName}},</p>
+{{if .Resolved}}
+<p>The <strong>{{.Alert.Level}}</strong> alert on <strong>{{.DeviceName}}</strong> has been resolved.</p>
+{{else}}
+<p>A <strong style="color: {{if eq .Alert.Level "danger"}}#c62828{{else}}#ef6c00{{end}};">{{.Alert.Level}}</strong>
+alert was raised on <strong>{{.DeviceName}}</strong>.</p>
+{{end}}
+<p>{{.Alert.Message}}</p>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr><td>Value</td><td>{{printf "%.2f" .Alert.Value}}</td></tr>
+<tr><td>Limit</td><td>{{printf "%.2f" .Alert.Threshold}}</td></tr>
+<tr><td>Peak</td><td>{{printf "%.2f" .Alert.PeakValue}}</td></tr>
+<tr><td>Opened at</td><td>{{fmtTime .Alert.OpenedAt}}</td></tr>
+{{with .Alert.ResolvedAt}}<tr><td>Resolved at</td><td>{{fmtTime .}}</td></tr>{{end}}
+</table>
+<p style="color: #777; font-size: 12px;">You can change which alerts you are e-mailed about in the notification settings.</p>
+</body>
+</html>
+`
+
+// confirmData is the data passed to the address confirmation templates.
+type confirmData struct {
+	UserName string
+	Email    string
+	Token    string
+	Expires  time.Time
+}
+
+const confirmSubject = "Confirm your notification address"
+
+const confirmTextTemplate = `Hello {{.UserName}},
+
+{{.Email}} was set to receive your Energy Controller alerts.
+To confirm it, enter this code in the notification settings:
+
+    {{.Token}}
+
+The code expires at {{fmtTime .Expires}}. If you did not ask for this, ignore this message.
+`
+
+const confirmHTMLTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; color: #222;">
+<p>Hello {{.UserName}},</p>
+<p><strong>{{.Email}}</strong> was set to receive your Energy Controller alerts.
+To confirm it, enter this code in the notification settings:</p>
+<p style="font-family: monospace; font-size: 18px;">{{.Token}}</p>
+<p style="color: #777; font-size: 12px;">The code expires at {{fmtTime .Expires}}. If you did not ask for this, ignore this message.</p>
+</body>
+</html>
+`
+
+func fmtTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05 MST")
+}
+
+var (
+	subjectTmpl = texttemplate.Must(texttemplate.New("subject").Parse(subjectTemplate))
+	textTmpl    = texttemplate.Must(texttemplate.New("text").Funcs(texttemplate.FuncMap{"fmtTime": fmtTime}).Parse(textTemplate))
+	htmlTmpl    = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{"fmtTime": fmtTime}).Parse(htmlTemplate))
+
+	confirmTextTmpl = texttemplate.Must(texttemplate.New("confirm-text").Funcs(texttemplate.FuncMap{"fmtTime": fmtTime}).Parse(confirmTextTemplate))
+	confirmHTMLTmpl = htmltemplate.Must(htmltemplate.New("confirm-html").Funcs(htmltemplate.FuncMap{"fmtTime": fmtTime}).Parse(confirmHTMLTemplate))
+)
+
+// renderAlert builds the subject and bodies of an alert e-mail.
+func renderAlert(d alertData) (subject, text, html string, err error) {
+	var s, t, h bytes.Buffer
+	if err := subjectTmpl.Execute(&s, d); err != nil {
+		return "", "", "", err
+	}
+	if err := textTmpl.Execute(&t, d); err != nil {
+		return "", "", "", err
+	}
+	if err := htmlTmpl.Execute(&h, d); err != nil {
+		return "", "", "", err
+	}
+	return s.String(), t.String(), h.String(), nil
+}
+
+// renderConfirmation builds the subject and bodies of an address confirmation e-mail.
+func renderConfirmation(d confirmData) (subject, text, html string, err error) {
+	var t, h bytes.Buffer
+	if err := confirmTextTmpl.Execute(&t, d); err != nil {
+		return "", "", "", err
+	}
+	if err := confirmHTMLTmpl.Execute(&h, d); err != nil {
+		return "", "", "", err
+	}
+	return confirmSubject, t.String(), h.String(), nil
+}
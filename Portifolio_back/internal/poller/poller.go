@@ -0,0 +1,332 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const (
+	minInterval     = 10 * time.Second // lower bound for a per-device interval
+	maxBackoff      = 15 * time.Minute // upper bound for the failure backoff
+	offlineAfter    = 3                // consecutive failures before marking a device offline
+	refreshInterval = time.Minute      // how often the device list is reloaded
+	tickInterval    = time.Second      // scheduler resolution
+	readTimeout     = 15 * time.Second // timeout for a single device read
+)
+
+// DeviceStore lists devices and updates their status (satisfied by devices.Repo).
+type DeviceStore interface {
+	ListAll(ctx context.Context) ([]devices.Device, error)
+	UpdateStatus(ctx context.Context, deviceID int64, status string) error
+}
+
+// TelemetryStore persists readings (satisfied by telemetry.Repo).
+type TelemetryStore interface {
+	Create(ctx context.Context, t *telemetry.Telemetry) (int64, error)
+	UpdateDeviceLastSeenAndStatus(ctx context.Context, deviceID int64) (bool, error)
+}
+
+// Config controls the polling schedule.
+type Config struct {
+	Interval    time.Duration // default interval when the device sets none
+	Concurrency int           // maximum simultaneous device reads
+}
+
+// ConfigFromEnv reads POLL_INTERVAL_SEC and POLL_CONCURRENCY.
+// Polling is disabled when POLL_INTERVAL_SEC is 0.
+func ConfigFromEnv() Config {
+	cfg := Config{Interval: time.Minute, Concurrency: 8}
+	if v := os.Getenv("POLL_INTERVAL_SEC"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+			cfg.Interval = time.Duration(sec) * time.Second
+		}
+	}
+	if v := os.Getenv("POLL_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Concurrency = n
+		}
+	}
+	return cfg
+}
+
+// Enabled reports whether polling should run.
+func (c Config) Enabled() bool {
+	return c.Interval > 0
+}
+
+// target is a pollable device and its schedule.
+type target struct {
+	device   devices.Device
+	driver   drivers.Driver
+	conn     drivers.Connection
+	interval time.Duration
+	next     time.Time
+	failures int
+	inFlight bool
+}
+
+// Poller periodically reads physical devices and records their telemetry.
+type Poller struct {
+	cfg       Config
+	devices   DeviceStore
+	telemetry TelemetryStore
+	drivers   *drivers.Registry
+	secrets   devices.CredentialStore
+	events    realtime.Publisher
+	alerts    telemetry.AlertEvaluator
+
+	mu      sync.Mutex
+	targets map[int64]*target
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+// New creates a poller; call Start to run it.
+// creds may be nil when credentials are kept in device metadata; events and alerts may be nil.
+func New(cfg Config, ds DeviceStore, ts TelemetryStore, registry *drivers.Registry, creds devices.CredentialStore, events realtime.Publisher, alerts telemetry.AlertEvaluator) *Poller {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Interval < minInterval {
+		cfg.Interval = minInterval
+	}
+	return &Poller{
+		cfg:       cfg,
+		devices:   ds,
+		telemetry: ts,
+		drivers:   registry,
+		secrets:   creds,
+		events:    events,
+		alerts:    alerts,
+		targets:   make(map[int64]*target),
+		sem:       make(chan struct{}, cfg.Concurrency),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler until ctx is cancelled.
+func (p *Poller) Start(ctx context.Context) {
+	go p.run(ctx)
+}
+
+// Wait blocks until the scheduler and all in-flight reads have finished.
+func (p *Poller) Wait() {
+	<-p.done
+}
+
+func (p *Poller) run(ctx context.Context) {
+	defer close(p.done)
+	defer p.wg.Wait()
+
+	p.refresh(ctx)
+
+	tick := time.NewTicker(tickInterval)
+	defer tick.Stop()
+	reload := time.NewTicker(refreshInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload.C:
+			p.refresh(ctx)
+		case now := <-tick.C:
+			p.dispatchDue(ctx, now)
+		}
+	}
+}
+
+// refresh reloads the device list, keeping the schedule of devices already known.
+func (p *Poller) refresh(ctx context.Context) {
+	list, err := p.devices.ListAll(ctx)
+	if err != nil {
+		log.Printf("Poller: failed to list devices: %v", err)
+		return
+	}
+
+	// Resolve connections before taking the lock: credential lookups hit the database
+	var pollable []target
+	for _, d := range list {
+		driver, conn, err := devices.ResolveConnection(ctx, p.drivers, p.secrets, &d)
+		if err != nil {
+			log.Printf("Poller: failed to load credentials for device %d: %v", d.ID, err)
+			continue
+		}
+		if driver == nil || driver.Validate(conn) != nil || !driver.Capabilities().Power {
+			continue
+		}
+		pollable = append(pollable, target{
+			device:   d,
+			driver:   driver,
+			conn:     conn,
+			interval: pollInterval(d.Metadata, p.cfg.Interval),
+		})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := make(map[int64]bool, len(pollable))
+	for _, n := range pollable {
+		seen[n.device.ID] = true
+		if t, ok := p.targets[n.device.ID]; ok {
+			t.device, t.driver, t.conn, t.interval = n.device, n.driver, n.conn, n.interval
+			continue
+		}
+		n.next = time.Now().Add(jitter(n.device.ID, n.interval))
+		p.targets[n.device.ID] = &n
+	}
+
+	for id, t := range p.targets {
+		if !seen[id] && !t.inFlight {
+			delete(p.targets, id)
+		}
+	}
+}
+
+// dispatchDue starts reads for every due device, bounded by the semaphore.
+func (p *Poller) dispatchDue(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	var due []*target
+	for _, t := range p.targets {
+		if !t.inFlight && !now.Before(t.next) {
+			t.inFlight = true
+			due = append(due, t)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, t := range due {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			p.mu.Lock()
+			t.inFlight = false
+			p.mu.Unlock()
+			return
+		}
+		p.wg.Add(1)
+		go func(t *target) {
+			defer p.wg.Done()
+			defer func() { <-p.sem }()
+			p.poll(ctx, t)
+		}(t)
+	}
+}
+
+// poll reads one device, stores the reading and schedules the next attempt.
+func (p *Poller) poll(ctx context.Context, t *target) {
+	p.mu.Lock()
+	deviceID, userID, driver, conn := t.device.ID, t.device.UserID, t.driver, t.conn
+	p.mu.Unlock()
+
+	readCtx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	reading, err := driver.ReadPower(readCtx, conn)
+
+	p.mu.Lock()
+	t.inFlight = false
+	if err != nil {
+		t.failures++
+		t.next = time.Now().Add(backoff(t.interval, t.failures))
+		failures := t.failures
+		p.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Poller: read failed for device %d (attempt %d): %v", deviceID, failures, err)
+		if failures == offlineAfter {
+			if err := p.devices.UpdateStatus(ctx, deviceID, "offline"); err != nil {
+				log.Printf("Warning: failed to mark device %d offline: %v", deviceID, err)
+			} else {
+				p.publish(realtime.StatusEvent(userID, deviceID, "offline", nil))
+			}
+		}
+		return
+	}
+	t.failures = 0
+	t.next = time.Now().Add(t.interval)
+	p.mu.Unlock()
+
+	tel := &telemetry.Telemetry{
+		DeviceID:    deviceID,
+		Power:       reading.Power,
+		Voltage:     reading.Voltage,
+		Current:     reading.Current,
+		EnergyTotal: reading.TotalEnergy,
+		EnergyToday: reading.TodayEnergy,
+		Timestamp:   time.Now(),
+	}
+	id, err := p.telemetry.Create(ctx, tel)
+	if err != nil {
+		log.Printf("Poller: failed to store telemetry for device %d: %v", deviceID, err)
+		return
+	}
+	tel.ID = id
+	changed, err := p.telemetry.UpdateDeviceLastSeenAndStatus(ctx, deviceID)
+	if err != nil {
+		log.Printf("Warning: failed to update device status for device %d: %v", deviceID, err)
+	}
+	if changed {
+		p.publish(realtime.StatusEvent(userID, deviceID, "online", nil))
+	}
+	p.publish(realtime.TelemetryEvent(userID, deviceID, tel))
+	if p.alerts != nil {
+		if err := p.alerts.Evaluate(ctx, userID, deviceID, tel.Power, tel.Timestamp); err != nil {
+			log.Printf("Warning: failed to evaluate alerts for device %d: %v", deviceID, err)
+		}
+	}
+}
+
+func (p *Poller) publish(e realtime.Event) {
+	if p.events != nil {
+		p.events.Publish(e)
+	}
+}
+
+// pollInterval reads "poll_interval_sec" from the device metadata.
+func pollInterval(metadata string, fallback time.Duration) time.Duration {
+	var meta struct {
+		PollIntervalSec *int `json:"poll_interval_sec"`
+	}
+	if metadata == "" || json.Unmarshal([]byte(metadata), &meta) != nil || meta.PollIntervalSec == nil {
+		return fallback
+	}
+	d := time.Duration(*meta.PollIntervalSec) * time.Second
+	if d < minInterval {
+		return minInterval
+	}
+	return d
+}
+
+// backoff doubles the interval per consecutive failure, capped at maxBackoff.
+func backoff(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for i := 0; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// jitter spreads the first poll of each device across its interval
+// so a restart doesn't hit every plug at once.
+func jitter(deviceID int64, interval time.Duration) time.Duration {
+	return time.Duration(deviceID%97) * interval / 97
+}
@@ -0,0 +1,251 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/drivers/driverstest"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+type fakeDevices struct {
+	list []devices.Device
+
+	mu       sync.Mutex
+	statuses []string // statuses set through UpdateStatus
+}
+
+func (f *fakeDevices) ListAll(context.Context) ([]devices.Device, error) {
+	return f.list, nil
+}
+
+func (f *fakeDevices) UpdateStatus(_ context.Context, _ int64, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+type fakeTelemetry struct {
+	mu      sync.Mutex
+	created []telemetry.Telemetry
+	offline bool // the device was not online before its next reading
+}
+
+func (f *fakeTelemetry) Create(_ context.Context, t *telemetry.Telemetry) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, *t)
+	return int64(len(f.created)), nil
+}
+
+func (f *fakeTelemetry) UpdateDeviceLastSeenAndStatus(context.Context, int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := f.offline
+	f.offline = false
+	return changed, nil
+}
+
+func (f *fakeTelemetry) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.created)
+}
+
+type fakeEvents struct {
+	mu     sync.Mutex
+	events []realtime.Event
+}
+
+func (f *fakeEvents) Publish(e realtime.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, e)
+}
+
+// blockingDriver holds every read until release is closed or the read is cancelled.
+type blockingDriver struct {
+	*driverstest.Fake
+	release chan struct{}
+	started chan struct{}
+
+	mu           sync.Mutex
+	active, peak int
+	finished     int
+}
+
+func newBlockingDriver() *blockingDriver {
+	return &blockingDriver{Fake: driverstest.NewFake("fake"), release: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (d *blockingDriver) ReadPower(ctx context.Context, conn drivers.Connection) (*drivers.Reading, error) {
+	d.mu.Lock()
+	d.active++
+	d.peak = max(d.peak, d.active)
+	d.mu.Unlock()
+	d.started <- struct{}{}
+
+	var err error
+	select {
+	case <-d.release:
+	case <-ctx.Done():
+		time.Sleep(20 * time.Millisecond) // a read that takes a moment to notice
+		err = ctx.Err()
+	}
+
+	d.mu.Lock()
+	d.active--
+	d.finished++
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &drivers.Reading{Power: 10}, nil
+}
+
+func (d *blockingDriver) stats() (active, peak, finished int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active, d.peak, d.finished
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{time.Minute, 1, 2 * time.Minute},
+		{time.Minute, 2, 4 * time.Minute},
+		{time.Minute, 3, 8 * time.Minute},
+		{time.Minute, 4, maxBackoff},
+		{time.Minute, 50, maxBackoff},
+		{minInterval, 1, 2 * minInterval},
+		{20 * time.Minute, 1, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.interval, tt.failures); got != tt.want {
+			t.Errorf("backoff(%s, %d) = %s, want %s", tt.interval, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestOfflineAfterConsecutiveFailures(t *testing.T) {
+	ds, ts, events := &fakeDevices{}, &fakeTelemetry{}, &fakeEvents{}
+	p := New(Config{Interval: time.Minute, Concurrency: 1}, ds, ts, nil, nil, events, nil)
+	driver := driverstest.NewFake("fake")
+	driver.Err = errors.New("i/o timeout")
+	tg := &target{device: devices.Device{ID: 5, UserID: 7}, driver: driver, conn: drivers.Connection{Host: "10.0.0.5"}, interval: time.Minute}
+	ctx := context.Background()
+
+	for failures := 1; failures <= offlineAfter+1; failures++ {
+		before := time.Now()
+		p.poll(ctx, tg)
+		if tg.failures != failures {
+			t.Fatalf("failures = %d, want %d", tg.failures, failures)
+		}
+		if wait := tg.next.Sub(before); wait < backoff(time.Minute, failures) || wait > backoff(time.Minute, failures)+time.Second {
+			t.Errorf("after failure %d the next read is in %s, want %s", failures, wait, backoff(time.Minute, failures))
+		}
+		wantOffline := 0
+		if failures >= offlineAfter {
+			wantOffline = 1 // marked once, not again on later failures
+		}
+		if len(ds.statuses) != wantOffline || len(events.events) != wantOffline {
+			t.Errorf("after failure %d: statuses %v, %d events; want %d offline", failures, ds.statuses, len(events.events), wantOffline)
+		}
+	}
+	if e := events.events[0]; e.Type != realtime.EventDeviceStatus || e.Data.(realtime.DeviceStatus).Status != "offline" {
+		t.Errorf("event = %+v, want device_status offline", e)
+	}
+
+	// The next successful read resets the backoff and announces the device is back
+	driver.Err = nil
+	ts.offline = true
+	before := time.Now()
+	p.poll(ctx, tg)
+	if tg.failures != 0 || tg.next.Sub(before) > time.Minute+time.Second {
+		t.Errorf("after a success: failures %d, next read in %s", tg.failures, tg.next.Sub(before))
+	}
+	if len(events.events) != 3 || events.events[1].Data.(realtime.DeviceStatus).Status != "online" || events.events[2].Type != realtime.EventTelemetry {
+		t.Errorf("events = %+v, want offline, online and the reading", events.events)
+	}
+}
+
+func TestDispatchBoundsConcurrency(t *testing.T) {
+	ts := &fakeTelemetry{}
+	p := New(Config{Interval: time.Minute, Concurrency: 2}, &fakeDevices{}, ts, nil, nil, nil, nil)
+	driver := newBlockingDriver()
+	for id := int64(1); id <= 5; id++ {
+		p.targets[id] = &target{device: devices.Device{ID: id, UserID: 7}, driver: driver, conn: drivers.Connection{Host: "10.0.0.1"}, interval: time.Minute}
+	}
+
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		p.dispatchDue(context.Background(), time.Now())
+	}()
+	for i := 0; i < 2; i++ {
+		<-driver.started
+	}
+	select {
+	case <-driver.started:
+		t.Fatal("a third read started while two were in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(driver.release)
+	<-dispatched
+	p.wg.Wait()
+	if _, peak, finished := driver.stats(); peak != 2 || finished != 5 {
+		t.Errorf("peak %d concurrent reads, %d finished; want 2 and 5", peak, finished)
+	}
+	if n := ts.count(); n != 5 {
+		t.Errorf("stored %d readings, want 5", n)
+	}
+}
+
+func TestWaitForInFlightReads(t *testing.T) {
+	driver := newBlockingDriver()
+	registry := drivers.NewRegistry()
+	registry.Register("smart_plug", driver)
+	// Device 97 has no jitter: it is read on the first tick
+	ds := &fakeDevices{list: []devices.Device{{ID: 97, UserID: 7, Type: "smart_plug", Metadata: `{"fake":{"ip":"10.0.0.97"}}`}}}
+	ts := &fakeTelemetry{}
+	p := New(Config{Interval: time.Minute, Concurrency: 1}, ds, ts, registry, nil, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Start(ctx)
+	select {
+	case <-driver.started:
+	case <-time.After(3 * tickInterval):
+		t.Fatal("device was not read")
+	}
+
+	cancel()
+	waited := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after shutdown")
+	}
+
+	if active, _, finished := driver.stats(); active != 0 || finished != 1 {
+		t.Errorf("Wait returned with %d reads in flight, %d finished", active, finished)
+	}
+	// A read cut short by shutdown is neither stored nor counted against the device
+	if ts.count() != 0 || len(ds.statuses) != 0 {
+		t.Errorf("stored %d readings and set statuses %v during shutdown", ts.count(), ds.statuses)
+	}
+}
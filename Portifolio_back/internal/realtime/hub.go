@@ -0,0 +1,166 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types pushed to clients.
+const (
+	EventTelemetry    = "telemetry"
+	EventDeviceStatus = "device_status"
+	EventAlert        = "alert"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before new ones are dropped.
+const subscriberBuffer = 64
+
+// Event is a change pushed to the owner of a device.
+type Event struct {
+	Type     string    `json:"type"`
+	UserID   int64     `json:"-"`
+	DeviceID int64     `json:"device_id"`
+	Data     any       `json:"data"`
+	Time     time.Time `json:"time"`
+}
+
+// DeviceStatus is the payload of a device_status event.
+type DeviceStatus struct {
+	Status     string `json:"status"`
+	PowerState *bool  `json:"power_state,omitempty"`
+	Commanded  bool   `json:"commanded,omitempty"` // the change came from a toggle/on/off command
+}
+
+// TelemetryEvent builds a telemetry event for a stored reading.
+func TelemetryEvent(userID, deviceID int64, reading any) Event {
+	return Event{Type: EventTelemetry, UserID: userID, DeviceID: deviceID, Data: reading, Time: time.Now()}
+}
+
+// StatusEvent builds a device_status event.
+func StatusEvent(userID, deviceID int64, status string, powerState *bool) Event {
+	return Event{
+		Type:     EventDeviceStatus,
+		UserID:   userID,
+		DeviceID: deviceID,
+		Data:     DeviceStatus{Status: status, PowerState: powerState},
+		Time:     time.Now(),
+	}
+}
+
+// CommandEvent builds the device_status event that follows a toggle/on/off command.
+func CommandEvent(userID, deviceID int64, status string, powerState *bool) Event {
+	e := StatusEvent(userID, deviceID, status, powerState)
+	e.Data = DeviceStatus{Status: status, PowerState: powerState, Commanded: true}
+	return e
+}
+
+// AlertEvent builds an alert event; alert is the opened, updated or resolved alert.
+func AlertEvent(userID, deviceID int64, alert any) Event {
+	return Event{Type: EventAlert, UserID: userID, DeviceID: deviceID, Data: alert, Time: time.Now()}
+}
+
+// Publisher accepts events for delivery (satisfied by *Hub).
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Subscription receives the events of one user, optionally limited to one device.
+type Subscription struct {
+	C <-chan Event
+
+	ch       chan Event
+	userID   int64
+	deviceID int64 // 0 = all devices of the user
+	dropped  atomic.Int64
+}
+
+// Dropped returns how many events were discarded because the subscriber was too slow.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Hub is an in-process pub/sub fan-out of events to subscribers, keyed by user.
+type Hub struct {
+	mu        sync.RWMutex
+	subs      map[int64]map[*Subscription]struct{}
+	observers []func(Event)
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int64]map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for a user's events. deviceID 0 receives all devices.
+// Call Unsubscribe when done.
+func (h *Hub) Subscribe(userID, deviceID int64) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: ch, ch: ch, userID: userID, deviceID: deviceID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Observe registers fn to be called with every event of every user, for
+// server-side consumers such as webhooks. fn runs inside Publish and must not block.
+func (h *Hub) Observe(fn func(Event)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.observers = append(h.observers, fn)
+}
+
+// Unsubscribe removes a subscriber and closes its channel.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s.userID][s]; !ok {
+		return
+	}
+	delete(h.subs[s.userID], s)
+	if len(h.subs[s.userID]) == 0 {
+		delete(h.subs, s.userID)
+	}
+	close(s.ch)
+}
+
+// Publish delivers an event to the matching subscribers without blocking:
+// a subscriber whose buffer is full misses the event instead of stalling the publisher.
+// A nil hub discards events.
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.observers {
+		fn(e)
+	}
+	for s := range h.subs[e.UserID] {
+		if s.deviceID != 0 && s.deviceID != e.DeviceID {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Close ends every subscription, letting long-lived streams return on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for userID, subs := range h.subs {
+		for s := range subs {
+			close(s.ch)
+		}
+		delete(h.subs, userID)
+	}
+}
@@ -0,0 +1,81 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	slow := h.Subscribe(1, 0) // never read
+	other := h.Subscribe(2, 0)
+
+	const total = subscriberBuffer + 10
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < total; i++ {
+			h.Publish(TelemetryEvent(1, 5, i))
+		}
+		h.Publish(TelemetryEvent(2, 9, nil))
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a subscriber whose buffer is full")
+	}
+
+	if got := slow.Dropped(); got != total-subscriberBuffer {
+		t.Errorf("slow subscriber dropped %d events, want %d", got, total-subscriberBuffer)
+	}
+	if got := len(slow.C); got != subscriberBuffer {
+		t.Errorf("slow subscriber buffered %d events, want %d", got, subscriberBuffer)
+	}
+	if e := <-slow.C; e.Data != 0 {
+		t.Errorf("oldest buffered event = %v, want the first one published", e.Data)
+	}
+	if got := len(other.C); got != 1 || other.Dropped() != 0 {
+		t.Errorf("other subscriber got %d events and dropped %d, want 1 and 0", got, other.Dropped())
+	}
+}
+
+func TestPublishFiltersByUserAndDevice(t *testing.T) {
+	h := NewHub()
+	all := h.Subscribe(1, 0)
+	one := h.Subscribe(1, 5)
+	other := h.Subscribe(2, 0)
+
+	h.Publish(StatusEvent(1, 5, "online", nil))
+	h.Publish(StatusEvent(1, 6, "offline", nil))
+	h.Publish(TelemetryEvent(3, 5, nil))
+
+	tests := []struct {
+		name    string
+		sub     *Subscription
+		devices []int64
+	}{
+		{"user's devices", all, []int64{5, 6}},
+		{"single device", one, []int64{5}},
+		{"other user", other, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.Unsubscribe(tt.sub) // closes the channel so the buffered events can be drained
+			var got []int64
+			for e := range tt.sub.C {
+				if e.UserID != tt.sub.userID {
+					t.Errorf("received an event of user %d", e.UserID)
+				}
+				got = append(got, e.DeviceID)
+			}
+			if len(got) != len(tt.devices) {
+				t.Fatalf("received events for devices %v, want %v", got, tt.devices)
+			}
+			for i := range got {
+				if got[i] != tt.devices[i] {
+					t.Errorf("received events for devices %v, want %v", got, tt.devices)
+				}
+			}
+		})
+	}
+}
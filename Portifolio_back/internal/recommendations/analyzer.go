@@ -0,0 +1,235 @@
+package recommendations
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const (
+	minWatts        = 0.3            // lower readings count as switched off (plug noise)
+	idleBand        = 1.5            // readings up to this multiple of the low level are idle...
+	idleTolerance   = 1.0            // ...plus this many Watts
+	activeRatio     = 3.0            // the active level must be this many times the low level
+	minStandbyWatts = 1.0            // smaller standby loads are not worth a recommendation
+	maxStandbyWatts = 30.0           // larger "idle" loads are not standby
+	minIdleHours    = 1.0            // per day
+	minObserved     = 48 * time.Hour // telemetry needed to judge a device
+	offShare        = 0.8            // an hour belongs to an off-period when idle this much of the time
+	minOffHours     = 3              // shortest off-period worth scheduling
+	daysPerMonth    = 30.0
+)
+
+// CostCalculator prices energy slots, returning nil when the user has no
+// tariff (satisfied by tariffs.Engine).
+type CostCalculator interface {
+	Cost(ctx context.Context, userID int64, slots []telemetry.EnergySlot) ([]float64, error)
+}
+
+// Analyzer finds devices that waste energy in standby.
+type Analyzer struct {
+	Repo  *Repo
+	Costs CostCalculator // nil leaves costs out
+}
+
+// NewAnalyzer creates a standby analyzer.
+func NewAnalyzer(repo *Repo, costs CostCalculator) *Analyzer {
+	return &Analyzer{Repo: repo, Costs: costs}
+}
+
+// Standby analyses the last days of the user's telemetry. A device has a
+// standby load when its readings have a low, steady band clearly below its
+// active level; the band's average power and the share of each hour of the day
+// spent in it give the monthly waste, priced as a typical week at the user's
+// tariff. Devices idle at the same hours every day get an off-period to schedule.
+func (a *Analyzer) Standby(ctx context.Context, userID int64, days int, loc *time.Location, now time.Time) (*Report, error) {
+	stats, err := a.Repo.hourlyStats(ctx, userID, now.AddDate(0, 0, -days), now, loc)
+	if err != nil {
+		return nil, err
+	}
+	names, err := a.Repo.deviceNames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byDevice := map[int64][]hourStats{}
+	for _, s := range stats {
+		byDevice[s.DeviceID] = append(byDevice[s.DeviceID], s)
+	}
+
+	rep := &Report{GeneratedAt: now, Days: days, Timezone: loc.String(), Items: []Recommendation{}}
+	priced := false
+	var totalCost float64
+	for id, hours := range byDevice {
+		rec, err := a.analyze(ctx, userID, hours, loc, now)
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			continue
+		}
+		rec.DeviceID, rec.DeviceName = id, names[id]
+		rec.Message = message(rec)
+		rep.MonthlyEnergy += rec.MonthlyEnergy
+		if rec.MonthlyCost != nil {
+			priced = true
+			totalCost += *rec.MonthlyCost
+		}
+		rep.Items = append(rep.Items, *rec)
+	}
+	if priced {
+		rep.MonthlyCost = &totalCost
+	}
+
+	sort.Slice(rep.Items, func(i, j int) bool {
+		ci, cj := rep.Items[i].MonthlyCost, rep.Items[j].MonthlyCost
+		if ci != nil && cj != nil && *ci != *cj {
+			return *ci > *cj
+		}
+		return rep.Items[i].MonthlyEnergy > rep.Items[j].MonthlyEnergy
+	})
+	for i := range rep.Items {
+		rep.Items[i].Rank = i + 1
+	}
+	return rep, nil
+}
+
+// analyze returns the standby recommendation of one device, or nil when it
+// has no standby load worth acting on.
+func (a *Analyzer) analyze(ctx context.Context, userID int64, hours []hourStats, loc *time.Location, now time.Time) (*Recommendation, error) {
+	var observed, idle, idleWs float64
+	for _, h := range hours {
+		observed += h.Observed
+		idle += h.Idle
+		idleWs += h.IdleWs
+	}
+	low, high := hours[0].Low, hours[0].High
+	if observed < minObserved.Seconds() || idle == 0 || high < activeRatio*low {
+		return nil, nil
+	}
+	watts := idleWs / idle
+	if watts < minStandbyWatts || watts > maxStandbyWatts {
+		return nil, nil
+	}
+
+	// Share of each hour of the day spent idle; hours never observed take the overall share
+	var share [24]float64
+	for h := range share {
+		share[h] = idle / observed
+	}
+	for _, h := range hours {
+		if h.Observed > 0 {
+			share[h.Hour] = h.Idle / h.Observed
+		}
+	}
+	rec := &Recommendation{StandbyWatts: watts}
+	for _, s := range share {
+		rec.IdleHoursPerDay += s
+	}
+	if rec.IdleHoursPerDay < minIdleHours {
+		return nil, nil
+	}
+
+	start, length := offPeriod(share)
+	switch {
+	case length == 24:
+		rec.Kind = KindUnplug
+	case length >= minOffHours:
+		rec.Kind = KindSchedule
+		rec.OffPeriod = &OffPeriod{StartHour: start, EndHour: (start + length) % 24}
+	default:
+		rec.Kind = KindIdleOff
+		threshold := math.Ceil(watts*idleBand + idleTolerance)
+		rec.ThresholdWatts = &threshold
+	}
+
+	// Price a typical week of the standby draw and scale it to a month
+	y, m, d := now.In(loc).Date()
+	week := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	slots := make([]telemetry.EnergySlot, 0, 7*24*4)
+	for t := week; t.Before(week.AddDate(0, 0, 7)); t = t.Add(telemetry.SlotStep) {
+		kwh := watts * share[t.Hour()] * telemetry.SlotStep.Hours() / 1000
+		slots = append(slots, telemetry.EnergySlot{Start: t, Energy: kwh})
+	}
+	var costs []float64
+	if a.Costs != nil {
+		var err error
+		if costs, err = a.Costs.Cost(ctx, userID, slots); err != nil {
+			return nil, err
+		}
+	}
+
+	scale := daysPerMonth / 7
+	var cost, offEnergy, offCost float64
+	for i, s := range slots {
+		rec.MonthlyEnergy += s.Energy * scale
+		if costs != nil {
+			cost += costs[i] * scale
+		}
+		if rec.OffPeriod != nil && inPeriod(s.Start.Hour(), start, length) {
+			offEnergy += s.Energy * scale
+			if costs != nil {
+				offCost += costs[i] * scale
+			}
+		}
+	}
+	if costs != nil {
+		rec.MonthlyCost = &cost
+	}
+	if rec.OffPeriod != nil {
+		rec.OffPeriod.MonthlyEnergy = offEnergy
+		if costs != nil {
+			rec.OffPeriod.MonthlyCost = &offCost
+		}
+	}
+	return rec, nil
+}
+
+// offPeriod returns the longest run of consecutive hours (wrapping past
+// midnight) that are idle at least offShare of the time, as its first hour
+// and length; a length of 24 means every hour.
+func offPeriod(share [24]float64) (int, int) {
+	bestStart, best := 0, 0
+	for start := 0; start < 24; start++ {
+		if share[(start+23)%24] >= offShare && share[start] >= offShare {
+			continue // not the beginning of a run
+		}
+		n := 0
+		for n < 24 && share[(start+n)%24] >= offShare {
+			n++
+		}
+		if n > best {
+			bestStart, best = start, n
+		}
+	}
+	if best == 0 && share[0] >= offShare {
+		return 0, 24 // every hour qualifies, so no run has a beginning
+	}
+	return bestStart, best
+}
+
+func inPeriod(hour, start, length int) bool {
+	return (hour-start+24)%24 < length
+}
+
+// message describes the recommendation in a sentence, e.g. "TV draws 8.0 W
+// idle for 20 h/day: R$ 4.90/month; schedule an off-period from 00h to 07h".
+func message(r *Recommendation) string {
+	waste := fmt.Sprintf("%.1f kWh/month", r.MonthlyEnergy)
+	if r.MonthlyCost != nil {
+		waste = fmt.Sprintf("R$ %.2f/month", *r.MonthlyCost)
+	}
+	msg := fmt.Sprintf("%s draws %.1f W idle for %.0f h/day: %s; ", r.DeviceName, r.StandbyWatts, r.IdleHoursPerDay, waste)
+	switch r.Kind {
+	case KindSchedule:
+		return msg + fmt.Sprintf("schedule an off-period from %02dh to %02dh", r.OffPeriod.StartHour, r.OffPeriod.EndHour)
+	case KindUnplug:
+		return msg + "it is idle nearly all day, switch it off when not in use"
+	default:
+		return msg + fmt.Sprintf("add an idle-off timer below %.0f W", *r.ThresholdWatts)
+	}
+}
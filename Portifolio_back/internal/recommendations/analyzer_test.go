@@ -0,0 +1,117 @@
+package recommendations
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// shares builds an idle share per hour: 1 for the listed hours, 0 elsewhere.
+func shares(idle ...int) [24]float64 {
+	var s [24]float64
+	for _, h := range idle {
+		s[h] = 1
+	}
+	return s
+}
+
+func hourRange(from, n int) []int {
+	var out []int
+	for i := 0; i < n; i++ {
+		out = append(out, (from+i)%24)
+	}
+	return out
+}
+
+func TestOffPeriod(t *testing.T) {
+	threshold := shares(hourRange(9, 4)...)
+	threshold[9] = offShare // exactly at the share still counts
+
+	tests := []struct {
+		name        string
+		share       [24]float64
+		start, size int
+	}{
+		{"never idle", shares(), 0, 0},
+		{"night", shares(hourRange(0, 7)...), 0, 7},
+		{"wraps past midnight", shares(hourRange(22, 8)...), 22, 8},
+		{"ends at midnight", shares(hourRange(20, 4)...), 20, 4},
+		{"longest run wins", shares(append(hourRange(1, 2), hourRange(10, 5)...)...), 10, 5},
+		{"all but one hour", shares(append(hourRange(13, 11), hourRange(0, 12)...)...), 13, 23},
+		{"all day", shares(hourRange(0, 24)...), 0, 24},
+		{"share at the threshold", threshold, 9, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, size := offPeriod(tt.share)
+			if start != tt.start || size != tt.size {
+				t.Errorf("offPeriod = (%d, %d), want (%d, %d)", start, size, tt.start, tt.size)
+			}
+		})
+	}
+}
+
+// observedHours returns two days of stats per hour of a device drawing 5 W
+// when idle, with idle[h] the share of hour h spent idle.
+func observedHours(idle [24]float64) []hourStats {
+	hours := make([]hourStats, 24)
+	for h := range hours {
+		observed := 2 * time.Hour.Seconds()
+		hours[h] = hourStats{DeviceID: 1, Hour: h, Low: 5, High: 100, Observed: observed,
+			Idle: idle[h] * observed, IdleWs: 5 * idle[h] * observed}
+	}
+	return hours
+}
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)
+
+	var scattered [24]float64
+	for h := range scattered {
+		scattered[h] = 0.3
+	}
+	// Hour 3 was never observed; every observed hour was idle
+	unobserved := observedHours(shares(hourRange(0, 24)...))
+	for i := range unobserved {
+		unobserved[i].Observed *= 1.2
+		unobserved[i].Idle, unobserved[i].IdleWs = unobserved[i].Observed, 5*unobserved[i].Observed
+	}
+	unobserved[3].Observed, unobserved[3].Idle, unobserved[3].IdleWs = 0, 0, 0
+
+	tests := []struct {
+		name      string
+		hours     []hourStats
+		kind      string
+		offPeriod *OffPeriod
+		idleHours float64
+	}{
+		{"idle at night", observedHours(shares(hourRange(0, 7)...)), KindSchedule, &OffPeriod{StartHour: 0, EndHour: 7}, 7},
+		{"idle across midnight", observedHours(shares(hourRange(22, 8)...)), KindSchedule, &OffPeriod{StartHour: 22, EndHour: 6}, 8},
+		{"idle all day", observedHours(shares(hourRange(0, 24)...)), KindUnplug, nil, 24},
+		{"idle at varying hours", observedHours(scattered), KindIdleOff, nil, 7.2},
+		{"unobserved hour takes the overall share", unobserved, KindUnplug, nil, 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := (&Analyzer{}).analyze(context.Background(), 7, tt.hours, time.UTC, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec == nil {
+				t.Fatal("no recommendation")
+			}
+			if rec.Kind != tt.kind || math.Abs(rec.IdleHoursPerDay-tt.idleHours) > 1e-9 {
+				t.Errorf("kind %s, %.2f idle h/day; want %s, %.2f", rec.Kind, rec.IdleHoursPerDay, tt.kind, tt.idleHours)
+			}
+			if (rec.OffPeriod == nil) != (tt.offPeriod == nil) ||
+				rec.OffPeriod != nil && (rec.OffPeriod.StartHour != tt.offPeriod.StartHour || rec.OffPeriod.EndHour != tt.offPeriod.EndHour) {
+				t.Errorf("off-period = %+v, want %+v", rec.OffPeriod, tt.offPeriod)
+			}
+			// 5 W for the idle hours of 30 days
+			if want := 5 * tt.idleHours * daysPerMonth / 1000; math.IsNaN(rec.MonthlyEnergy) || math.Abs(rec.MonthlyEnergy-want) > 1e-9 {
+				t.Errorf("monthly energy %.4f kWh, want %.4f", rec.MonthlyEnergy, want)
+			}
+		})
+	}
+}
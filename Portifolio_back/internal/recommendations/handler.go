@@ -0,0 +1,74 @@
+package recommendations
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDays     = 14
+	maxDays         = 60
+	defaultTimezone = "America/Sao_Paulo"
+)
+
+// Handler handles savings recommendation HTTP requests.
+type Handler struct {
+	Analyzer *Analyzer
+}
+
+// NewHandler creates a new recommendations handler.
+func NewHandler(analyzer *Analyzer) *Handler {
+	return &Handler{Analyzer: analyzer}
+}
+
+// RegisterRoutes registers recommendation routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/recommendations", h.List)
+}
+
+// List returns the user's standby savings recommendations, ranked by monthly waste.
+// Query params: days (telemetry analysed, default 14, max 60), tz (IANA name, default America/Sao_Paulo)
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	days := defaultDays
+	if v := c.Query("days"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 3 || d > maxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days: use 3 to 60"})
+			return
+		}
+		days = d
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", defaultTimezone))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tz"})
+		return
+	}
+
+	rep, err := h.Analyzer.Standby(c.Request.Context(), userID, days, loc, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute recommendations"})
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
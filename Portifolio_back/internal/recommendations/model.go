@@ -0,0 +1,57 @@
+package recommendations
+
+import "time"
+
+// Recommendation kinds.
+const (
+	KindSchedule = "schedule_off" // idle at the same hours every day: switch off on a schedule
+	KindIdleOff  = "idle_off"     // idle at varying hours: switch off with an idle-off timer
+	KindUnplug   = "unplug"       // idle nearly all day: switch off at the plug
+)
+
+// Report lists standby savings recommendations, most expensive first.
+// Monthly figures assume 30 days; costs are omitted when the user has no tariff.
+type Report struct {
+	GeneratedAt   time.Time        `json:"generated_at"`
+	Days          int              `json:"days"` // telemetry analysed
+	Timezone      string           `json:"timezone"`
+	MonthlyEnergy float64          `json:"monthly_energy"` // kWh wasted in standby
+	MonthlyCost   *float64         `json:"monthly_cost,omitempty"`
+	Items         []Recommendation `json:"recommendations"`
+}
+
+// Recommendation is the standby consumption of one device and what to do about it.
+type Recommendation struct {
+	Rank            int        `json:"rank"`
+	DeviceID        int64      `json:"device_id"`
+	DeviceName      string     `json:"device_name"`
+	Kind            string     `json:"kind"`
+	StandbyWatts    float64    `json:"standby_watts"`
+	IdleHoursPerDay float64    `json:"idle_hours_per_day"`
+	MonthlyEnergy   float64    `json:"monthly_energy"` // kWh
+	MonthlyCost     *float64   `json:"monthly_cost,omitempty"`
+	OffPeriod       *OffPeriod `json:"off_period,omitempty"`      // schedule_off only
+	ThresholdWatts  *float64   `json:"threshold_watts,omitempty"` // idle_off only
+	Message         string     `json:"message"`
+}
+
+// OffPeriod is a daily window, in local hours, in which the device is almost
+// always idle, with what switching it off then would save.
+type OffPeriod struct {
+	StartHour     int      `json:"start_hour"`
+	EndHour       int      `json:"end_hour"`
+	MonthlyEnergy float64  `json:"monthly_energy"`
+	MonthlyCost   *float64 `json:"monthly_cost,omitempty"`
+}
+
+// hourStats is the telemetry of one device at one hour of the day: how long it
+// was observed and how long (and with how much energy) it sat in its low band.
+type hourStats struct {
+	DeviceID int64
+	Hour     int
+	Low      float64 // 10th percentile of the device's non-zero readings (W)
+	High     float64 // 90th percentile (W)
+	Observed float64 // seconds
+	Idle     float64 // seconds
+	IdleWs   float64 // watt-seconds while idle
+}
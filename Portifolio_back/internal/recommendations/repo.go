@@ -0,0 +1,108 @@
+package recommendations
+
+import (
+	"context"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides the telemetry statistics recommendations are made from.
+type Repo struct {
+	q      RowsQuerier
+	maxGap time.Duration // longer reading intervals are not counted (device state unknown)
+}
+
+// NewRepo creates a new recommendations repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q, maxGap: telemetry.MaxGapFromEnv()}
+}
+
+// hourlyStats returns, per device and local hour of day, the time covered by
+// readings in [from, to) and the part of it spent in the device's low band:
+// readings of at least minWatts and within band times (plus tolerance W) its
+// 10th percentile. Each reading covers the time until the next one.
+func (r *Repo) hourlyStats(ctx context.Context, userID int64, from, to time.Time, loc *time.Location) ([]hourStats, error) {
+	sql := `WITH r AS (
+				SELECT t.device_id, t.timestamp AS ts, t.power,
+					EXTRACT(EPOCH FROM LEAST(LEAD(t.timestamp) OVER w, $3::TIMESTAMPTZ) - t.timestamp)::FLOAT8 AS secs
+				FROM telemetry t
+				JOIN device d ON d.id = t.device_id
+				WHERE d.user_id = $1 AND t.timestamp >= $2 AND t.timestamp < $3
+				WINDOW w AS (PARTITION BY t.device_id ORDER BY t.timestamp)
+			), levels AS (
+				SELECT device_id,
+					percentile_cont(0.1) WITHIN GROUP (ORDER BY power) FILTER (WHERE power >= $5) AS low,
+					percentile_cont(0.9) WITHIN GROUP (ORDER BY power) FILTER (WHERE power >= $5) AS high
+				FROM r
+				GROUP BY device_id
+			), s AS (
+				SELECT r.device_id, EXTRACT(HOUR FROM r.ts AT TIME ZONE $4)::INT AS hour, l.low, l.high, r.secs, r.power,
+					r.power >= $5 AND r.power <= l.low * $6 + $7 AS idle
+				FROM r
+				JOIN levels l ON l.device_id = r.device_id
+				WHERE l.low IS NOT NULL AND r.secs > 0 AND r.secs <= $8
+			)
+			SELECT device_id, hour, low, high, SUM(secs),
+				COALESCE(SUM(secs) FILTER (WHERE idle), 0),
+				COALESCE(SUM(power * secs) FILTER (WHERE idle), 0)
+			FROM s
+			GROUP BY device_id, hour, low, high
+			ORDER BY device_id, hour`
+	rows, err := r.q.Query(ctx, sql, userID, from, to, loc.String(), minWatts, idleBand, idleTolerance, r.maxGap.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []hourStats
+	for rows.Next() {
+		var s hourStats
+		if err := rows.Scan(&s.DeviceID, &s.Hour, &s.Low, &s.High, &s.Observed, &s.Idle, &s.IdleWs); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// deviceNames returns the name of each of the user's devices.
+func (r *Repo) deviceNames(ctx context.Context, userID int64) (map[int64]string, error) {
+	sql := `SELECT id, name FROM device WHERE user_id = $1`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		out[id] = name
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,19 @@
+// Package retry holds the backoff policy shared by the outboxes that retry
+// failed deliveries (alert e-mails and webhooks).
+package retry
+
+import "time"
+
+const (
+	BaseDelay = 30 * time.Second // delay after the first failure, doubled after each one
+	MaxDelay  = time.Hour        // upper bound for the retry delay
+)
+
+// Backoff returns the delay before the next attempt after n failures.
+func Backoff(n int) time.Duration {
+	delay := BaseDelay
+	for i := 1; i < n && delay < MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxDelay)
+}
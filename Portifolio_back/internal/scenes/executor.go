@@ -0,0 +1,82 @@
+package scenes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+)
+
+const (
+	concurrency    = 8                // simultaneous device commands per scene
+	commandTimeout = 20 * time.Second // timeout for switching one device
+)
+
+// DeviceController switches devices (satisfied by devices.Controller).
+type DeviceController interface {
+	DeviceForUser(ctx context.Context, userID, deviceID int64) (*devices.Device, error)
+	SetPower(ctx context.Context, d *devices.Device, on bool) (*devices.Device, error)
+}
+
+// Executor applies scenes by switching their devices concurrently.
+type Executor struct {
+	Repo    *Repo
+	Control DeviceController
+}
+
+// NewExecutor creates a scene executor.
+func NewExecutor(repo *Repo, control DeviceController) *Executor {
+	return &Executor{Repo: repo, Control: control}
+}
+
+// Apply runs every action of a user's scene and reports the outcome per device.
+// It returns ErrNotFound when there is no such scene; device failures are
+// reported in the results, not as an error.
+func (e *Executor) Apply(ctx context.Context, userID, sceneID int64) (*Execution, error) {
+	s, err := e.Repo.GetForUser(ctx, userID, sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := &Execution{SceneID: s.ID, StartedAt: time.Now(), Results: make([]Result, len(s.Actions))}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, a := range s.Actions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			exec.Results[i] = e.apply(ctx, userID, a)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range exec.Results {
+		if r.OK {
+			exec.Succeeded++
+		} else {
+			exec.Failed++
+		}
+	}
+	exec.DurationMs = time.Since(exec.StartedAt).Milliseconds()
+	return exec, nil
+}
+
+func (e *Executor) apply(ctx context.Context, userID int64, a Action) Result {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	r := Result{DeviceID: a.DeviceID, DeviceName: a.DeviceName, PowerOn: a.PowerOn}
+	d, err := e.Control.DeviceForUser(ctx, userID, a.DeviceID)
+	if err == nil {
+		d, err = e.Control.SetPower(ctx, d, a.PowerOn)
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.OK = true
+	r.PowerState = d.PowerState
+	return r
+}
@@ -0,0 +1,208 @@
+package scenes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles scene HTTP requests.
+type Handler struct {
+	Executor *Executor
+}
+
+// NewHandler creates a new scenes handler.
+func NewHandler(executor *Executor) *Handler {
+	return &Handler{Executor: executor}
+}
+
+// RegisterRoutes registers scene routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	s := r.Group("/scenes")
+	s.GET("", h.List)
+	s.POST("", h.Create)
+	s.GET("/:id", h.Get)
+	s.PUT("/:id", h.Update)
+	s.DELETE("/:id", h.Delete)
+	s.POST("/:id/apply", h.Apply)
+}
+
+// List returns the user's scenes.
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.Executor.Repo.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch scenes"})
+		return
+	}
+	if list == nil {
+		list = []Scene{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Get returns one of the user's scenes.
+func (h *Handler) Get(c *gin.Context) {
+	userID, id, ok := sceneParams(c)
+	if !ok {
+		return
+	}
+	h.respondScene(c, userID, id, http.StatusOK)
+}
+
+// Create adds a scene.
+func (h *Handler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	req, ok := h.bindScene(c, userID)
+	if !ok {
+		return
+	}
+
+	id, err := h.Executor.Repo.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create scene"})
+		return
+	}
+	h.respondScene(c, userID, id, http.StatusCreated)
+}
+
+// Update replaces the name and actions of one of the user's scenes.
+func (h *Handler) Update(c *gin.Context) {
+	userID, id, ok := sceneParams(c)
+	if !ok {
+		return
+	}
+	req, ok := h.bindScene(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := h.Executor.Repo.Update(c.Request.Context(), userID, id, req); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "scene not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update scene"})
+		return
+	}
+	h.respondScene(c, userID, id, http.StatusOK)
+}
+
+// Delete removes one of the user's scenes.
+func (h *Handler) Delete(c *gin.Context) {
+	userID, id, ok := sceneParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Executor.Repo.Delete(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "scene not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete scene"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Apply runs a scene and returns the per-device results.
+func (h *Handler) Apply(c *gin.Context) {
+	userID, id, ok := sceneParams(c)
+	if !ok {
+		return
+	}
+
+	exec, err := h.Executor.Apply(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scene not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply scene"})
+		return
+	}
+	c.JSON(http.StatusOK, exec)
+}
+
+// bindScene parses and validates a scene payload and checks the user owns its devices.
+func (h *Handler) bindScene(c *gin.Context, userID int64) (*SceneRequest, bool) {
+	var req SceneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: name and at least one action are required"})
+		return nil, false
+	}
+
+	ids, _ := split(req.Actions)
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "each device may appear only once in a scene"})
+			return nil, false
+		}
+		seen[id] = true
+	}
+
+	owns, err := h.Executor.Repo.UserOwnsDevices(c.Request.Context(), userID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return nil, false
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return nil, false
+	}
+	return &req, true
+}
+
+func (h *Handler) respondScene(c *gin.Context, userID, id int64, status int) {
+	s, err := h.Executor.Repo.GetForUser(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scene not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch scene"})
+		return
+	}
+	c.JSON(status, s)
+}
+
+// sceneParams extracts the user ID and the :id parameter, writing the error response if invalid.
+func sceneParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,46 @@
+package scenes
+
+import "time"
+
+// Scene is a named set of desired power states for a user's devices.
+type Scene struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Actions   []Action  `json:"actions"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Action is the desired power state of one device in a scene.
+type Action struct {
+	DeviceID   int64  `json:"device_id" binding:"required"`
+	DeviceName string `json:"device_name,omitempty"`
+	PowerOn    bool   `json:"power_on"`
+}
+
+// SceneRequest is the payload for creating or replacing a scene.
+type SceneRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Actions []Action `json:"actions" binding:"required,min=1,dive"`
+}
+
+// Execution is the outcome of applying a scene.
+type Execution struct {
+	SceneID    int64     `json:"scene_id"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Succeeded  int       `json:"succeeded"`
+	Failed     int       `json:"failed"`
+	Results    []Result  `json:"results"`
+}
+
+// Result is the outcome of one device command of a scene.
+type Result struct {
+	DeviceID   int64  `json:"device_id"`
+	DeviceName string `json:"device_name,omitempty"`
+	PowerOn    bool   `json:"power_on"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	PowerState *bool  `json:"power_state,omitempty"` // state after a successful command
+}
@@ -0,0 +1,202 @@
+package scenes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a scene does not exist (or belongs to another user).
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for scenes.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new scenes repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsDevices checks that every device belongs to the user.
+func (r *Repo) UserOwnsDevices(ctx context.Context, userID int64, deviceIDs []int64) (bool, error) {
+	sql := `SELECT COUNT(DISTINCT id) FROM device WHERE user_id = $1 AND id = ANY($2)`
+	var owned int
+	if err := r.q.QueryRow(ctx, sql, userID, deviceIDs).Scan(&owned); err != nil {
+		return false, err
+	}
+	unique := make(map[int64]bool, len(deviceIDs))
+	for _, id := range deviceIDs {
+		unique[id] = true
+	}
+	return owned == len(unique), nil
+}
+
+// ListByUser returns the scenes of a user with their actions.
+func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Scene, error) {
+	rows, err := r.q.Query(ctx, `SELECT id, user_id, name, created_at, updated_at FROM scene WHERE user_id = $1 ORDER BY name, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Scene
+	index := make(map[int64]int)
+	for rows.Next() {
+		var s Scene
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Name, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		s.Actions = []Action{}
+		index[s.ID] = len(list)
+		list = append(list, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	actions, err := r.actions(ctx, `s.user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for sceneID, a := range actions {
+		if i, ok := index[sceneID]; ok {
+			list[i].Actions = a
+		}
+	}
+	return list, nil
+}
+
+// GetForUser returns a scene of a user with its actions, or ErrNotFound.
+func (r *Repo) GetForUser(ctx context.Context, userID, id int64) (*Scene, error) {
+	sql := `SELECT id, user_id, name, created_at, updated_at FROM scene WHERE id = $1 AND user_id = $2`
+	var s Scene
+	if err := r.q.QueryRow(ctx, sql, id, userID).Scan(&s.ID, &s.UserID, &s.Name, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	actions, err := r.actions(ctx, `s.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	s.Actions = actions[id]
+	if s.Actions == nil {
+		s.Actions = []Action{}
+	}
+	return &s, nil
+}
+
+// actions loads scene actions matching a condition on scene s, keyed by scene ID.
+func (r *Repo) actions(ctx context.Context, where string, arg int64) (map[int64][]Action, error) {
+	sql := `SELECT a.scene_id, a.device_id, d.name, a.power_on
+			FROM scene_action a
+			INNER JOIN scene s ON s.id = a.scene_id
+			INNER JOIN device d ON d.id = a.device_id
+			WHERE ` + where + `
+			ORDER BY d.name, a.device_id`
+	rows, err := r.q.Query(ctx, sql, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64][]Action)
+	for rows.Next() {
+		var sceneID int64
+		var a Action
+		if err := rows.Scan(&sceneID, &a.DeviceID, &a.DeviceName, &a.PowerOn); err != nil {
+			return nil, err
+		}
+		out[sceneID] = append(out[sceneID], a)
+	}
+	return out, rows.Err()
+}
+
+// split returns the device IDs and desired states of the actions as parallel arrays.
+func split(actions []Action) ([]int64, []bool) {
+	ids := make([]int64, len(actions))
+	on := make([]bool, len(actions))
+	for i, a := range actions {
+		ids[i], on[i] = a.DeviceID, a.PowerOn
+	}
+	return ids, on
+}
+
+// Create inserts a scene and its actions in one statement and returns its ID.
+func (r *Repo) Create(ctx context.Context, userID int64, req *SceneRequest) (int64, error) {
+	sql := `WITH s AS (
+				INSERT INTO scene (user_id, name) VALUES ($1, $2) RETURNING id
+			), a AS (
+				INSERT INTO scene_action (scene_id, device_id, power_on)
+				SELECT s.id, x.device_id, x.power_on
+				FROM s, unnest($3::bigint[], $4::boolean[]) AS x(device_id, power_on)
+			)
+			SELECT id FROM s`
+	ids, on := split(req.Actions)
+	var id int64
+	err := r.q.QueryRow(ctx, sql, userID, req.Name, ids, on).Scan(&id)
+	return id, err
+}
+
+// Update replaces the name and actions of a scene of a user in one statement.
+// It returns ErrNotFound when there is no such scene.
+func (r *Repo) Update(ctx context.Context, userID, id int64, req *SceneRequest) error {
+	sql := `WITH s AS (
+				UPDATE scene SET name = $3, updated_at = NOW() WHERE id = $1 AND user_id = $2 RETURNING id
+			), removed AS (
+				DELETE FROM scene_action
+				WHERE scene_id IN (SELECT id FROM s) AND device_id <> ALL($4::bigint[])
+			), upserted AS (
+				INSERT INTO scene_action (scene_id, device_id, power_on)
+				SELECT s.id, x.device_id, x.power_on
+				FROM s, unnest($4::bigint[], $5::boolean[]) AS x(device_id, power_on)
+				ON CONFLICT (scene_id, device_id) DO UPDATE SET power_on = EXCLUDED.power_on
+			)
+			SELECT id FROM s`
+	ids, on := split(req.Actions)
+	var updated int64
+	if err := r.q.QueryRow(ctx, sql, id, userID, req.Name, ids, on).Scan(&updated); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a scene of a user.
+// It returns ErrNotFound when there is no such scene.
+func (r *Repo) Delete(ctx context.Context, userID, id int64) error {
+	var deleted int64
+	err := r.q.QueryRow(ctx, `DELETE FROM scene WHERE id = $1 AND user_id = $2 RETURNING id`, id, userID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
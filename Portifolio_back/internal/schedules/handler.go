@@ -0,0 +1,249 @@
+package schedules
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles schedule HTTP requests.
+type Handler struct {
+	Repo *Repo
+}
+
+// NewHandler creates a new schedules handler.
+func NewHandler(repo *Repo) *Handler {
+	return &Handler{Repo: repo}
+}
+
+// RegisterRoutes registers schedule routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	s := r.Group("/schedules")
+	s.GET("", h.List)
+	s.POST("", h.Create)
+	s.GET("/:id", h.Get)
+	s.PUT("/:id", h.Update)
+	s.DELETE("/:id", h.Delete)
+	s.GET("/:id/runs", h.ListRuns)
+}
+
+// List returns the user's schedules.
+// Query params: device_id (optional)
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		deviceID = id
+	}
+
+	list, err := h.Repo.ListByUser(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch schedules"})
+		return
+	}
+	if list == nil {
+		list = []Schedule{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Get returns one of the user's schedules.
+func (h *Handler) Get(c *gin.Context) {
+	s, ok := h.ownedSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// Create adds a schedule on one of the user's devices.
+func (h *Handler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	req, next, ok := h.bindSchedule(c, userID)
+	if !ok {
+		return
+	}
+
+	s, err := h.Repo.Create(c.Request.Context(), userID, req, next)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule"})
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+// Update replaces one of the user's schedules; the next run is recomputed from now.
+func (h *Handler) Update(c *gin.Context) {
+	userID, id, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+	req, next, ok := h.bindSchedule(c, userID)
+	if !ok {
+		return
+	}
+
+	s, err := h.Repo.Update(c.Request.Context(), userID, id, req, next)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule"})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// Delete removes one of the user's schedules and its history.
+func (h *Handler) Delete(c *gin.Context) {
+	userID, id, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.Delete(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete schedule"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListRuns returns the execution history of a schedule.
+// Query params: limit (default 50)
+func (h *Handler) ListRuns(c *gin.Context) {
+	s, ok := h.ownedSchedule(c)
+	if !ok {
+		return
+	}
+	limit := 0
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	list, err := h.Repo.ListRuns(c.Request.Context(), s.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch schedule runs"})
+		return
+	}
+	if list == nil {
+		list = []Run{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// bindSchedule parses and validates a schedule payload, checks the user owns
+// its device or scene and computes its next run (nil when disabled).
+func (h *Handler) bindSchedule(c *gin.Context, userID int64) (*ScheduleRequest, *time.Time, bool) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: name, weekdays (0-6) and time_of_day are required"})
+		return nil, nil, false
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	if req.SceneID != 0 {
+		owns, err := h.Repo.UserOwnsScene(c.Request.Context(), userID, req.SceneID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify scene ownership"})
+			return nil, nil, false
+		}
+		if !owns {
+			c.JSON(http.StatusNotFound, gin.H{"error": "scene not found"})
+			return nil, nil, false
+		}
+	} else {
+		owns, err := h.Repo.UserOwnsDevice(c.Request.Context(), userID, req.DeviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+			return nil, nil, false
+		}
+		if !owns {
+			c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+			return nil, nil, false
+		}
+	}
+
+	if req.Enabled != nil && !*req.Enabled {
+		return &req, nil, true
+	}
+	s := Schedule{Weekdays: req.Weekdays, TimeOfDay: req.TimeOfDay, Timezone: req.Timezone}
+	next, err := s.NextAfter(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	return &req, &next, true
+}
+
+// ownedSchedule loads the :id schedule of the user, writing the error response if not found.
+func (h *Handler) ownedSchedule(c *gin.Context) (*Schedule, bool) {
+	userID, id, ok := scheduleParams(c)
+	if !ok {
+		return nil, false
+	}
+
+	s, err := h.Repo.GetForUser(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch schedule"})
+		return nil, false
+	}
+	return s, true
+}
+
+// scheduleParams extracts the user ID and the :id parameter, writing the error response if invalid.
+func scheduleParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,126 @@
+package schedules
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Actions a schedule can perform.
+const (
+	ActionOn    = "on"
+	ActionOff   = "off"
+	ActionScene = "scene" // apply the schedule's scene
+)
+
+// Missed-run policies, applied when a run is found more than lateTolerance
+// past its time (e.g. after the server was down).
+const (
+	MissedSkip    = "skip"     // record the run as skipped and wait for the next one
+	MissedRunOnce = "run_once" // run once now, however many runs were missed
+)
+
+// Run states.
+const (
+	RunSuccess = "success"
+	RunFailed  = "failed"
+	RunSkipped = "skipped"
+)
+
+// Schedule turns a device on or off, or applies a scene, at a time of day on
+// selected weekdays. Exactly one of DeviceID and SceneID is set.
+type Schedule struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	DeviceID     *int64     `json:"device_id,omitempty"`
+	SceneID      *int64     `json:"scene_id,omitempty"`
+	Name         string     `json:"name"`
+	Action       string     `json:"action"`
+	Weekdays     []int      `json:"weekdays"`    // 0 = Sunday ... 6 = Saturday
+	TimeOfDay    string     `json:"time_of_day"` // HH:MM in Timezone
+	Timezone     string     `json:"timezone"`    // IANA name, e.g. America/Sao_Paulo
+	MissedPolicy string     `json:"missed_policy"`
+	Enabled      bool       `json:"enabled"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ScheduleRequest is the payload for creating or replacing a schedule.
+// Set device_id with action on|off, or scene_id (the action is then "scene").
+type ScheduleRequest struct {
+	DeviceID     int64  `json:"device_id"`
+	SceneID      int64  `json:"scene_id"`
+	Name         string `json:"name" binding:"required"`
+	Action       string `json:"action" binding:"omitempty,oneof=on off scene"`
+	Weekdays     []int  `json:"weekdays" binding:"required,min=1,dive,min=0,max=6"`
+	TimeOfDay    string `json:"time_of_day" binding:"required"`
+	Timezone     string `json:"timezone"`
+	MissedPolicy string `json:"missed_policy" binding:"omitempty,oneof=skip run_once"`
+	Enabled      *bool  `json:"enabled"`
+}
+
+// Validate checks the target, time and timezone and fills defaults.
+func (r *ScheduleRequest) Validate() error {
+	switch {
+	case (r.DeviceID == 0) == (r.SceneID == 0):
+		return errors.New("set exactly one of device_id and scene_id")
+	case r.SceneID != 0:
+		r.Action = ActionScene
+	case r.Action != ActionOn && r.Action != ActionOff:
+		return errors.New("action must be on or off")
+	}
+	if _, err := time.Parse("15:04", r.TimeOfDay); err != nil {
+		return errors.New("time_of_day must be HH:MM")
+	}
+	if r.Timezone == "" {
+		r.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	if r.MissedPolicy == "" {
+		r.MissedPolicy = MissedSkip
+	}
+	slices.Sort(r.Weekdays)
+	r.Weekdays = slices.Compact(r.Weekdays)
+	return nil
+}
+
+// NextAfter returns the first time strictly after t at which the schedule runs.
+func (s *Schedule) NextAfter(t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tod, err := time.Parse("15:04", s.TimeOfDay)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := t.In(loc)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), tod.Hour(), tod.Minute(), 0, 0, loc)
+		if candidate.After(t) && slices.Contains(s.Weekdays, int(candidate.Weekday())) {
+			return candidate, nil
+		}
+	}
+	return time.Time{}, errors.New("schedule has no weekdays")
+}
+
+// Run is one execution (or skipped execution) of a schedule.
+type Run struct {
+	ID           int64     `json:"id"`
+	ScheduleID   int64     `json:"schedule_id"`
+	DeviceID     *int64    `json:"device_id,omitempty"`
+	SceneID      *int64    `json:"scene_id,omitempty"`
+	Action       string    `json:"action"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	ExecutedAt   time.Time `json:"executed_at"`
+	Status       string    `json:"status"`
+	Late         bool      `json:"late"` // ran after lateTolerance under the run_once policy
+	Error        *string   `json:"error,omitempty"`
+}
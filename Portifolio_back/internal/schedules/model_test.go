@@ -0,0 +1,96 @@
+package schedules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextAfter(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	weekdays := []int{1, 3, 5} // Monday, Wednesday, Friday
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		after    time.Time
+		want     time.Time
+	}{
+		{"later the same day", Schedule{Weekdays: weekdays, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-02T07:00:00Z"), utc("2025-06-02T07:30:00Z")},
+		{"just before the run", Schedule{Weekdays: weekdays, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-02T07:29:59.999Z"), utc("2025-06-02T07:30:00Z")},
+		{"exactly at the run is not after it", Schedule{Weekdays: weekdays, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-02T07:30:00Z"), utc("2025-06-04T07:30:00Z")},
+		{"skips unselected weekdays", Schedule{Weekdays: weekdays, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-03T06:00:00Z"), utc("2025-06-04T07:30:00Z")},
+		{"wraps into next week", Schedule{Weekdays: weekdays, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-06T08:00:00Z"), utc("2025-06-09T07:30:00Z")},
+		{"single weekday a full week later", Schedule{Weekdays: []int{0}, TimeOfDay: "07:30", Timezone: "UTC"},
+			utc("2025-06-08T07:30:00Z"), utc("2025-06-15T07:30:00Z")},
+		// 23:00 on Monday in São Paulo is already Tuesday in UTC
+		{"weekday in the schedule's timezone", Schedule{Weekdays: []int{1}, TimeOfDay: "23:30", Timezone: "America/Sao_Paulo"},
+			utc("2025-06-03T02:00:00Z"), time.Date(2025, 6, 2, 23, 30, 0, 0, saoPaulo)},
+		// Clocks go forward on 2025-03-09 and back on 2025-11-02 in New York
+		{"across spring forward", Schedule{Weekdays: []int{0, 6}, TimeOfDay: "08:00", Timezone: "America/New_York"},
+			time.Date(2025, 3, 8, 8, 0, 0, 0, newYork), time.Date(2025, 3, 9, 8, 0, 0, 0, newYork)},
+		{"across fall back", Schedule{Weekdays: []int{0, 6}, TimeOfDay: "08:00", Timezone: "America/New_York"},
+			time.Date(2025, 11, 1, 8, 0, 0, 0, newYork), time.Date(2025, 11, 2, 8, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.NextAfter(tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextAfter(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+
+	// The runs around a transition stay at 08:00 local, 23 and 25 hours apart
+	s := Schedule{Weekdays: []int{0, 6}, TimeOfDay: "08:00", Timezone: "America/New_York"}
+	for _, c := range []struct {
+		from time.Time
+		gap  time.Duration
+	}{
+		{time.Date(2025, 3, 8, 8, 0, 0, 0, newYork), 23 * time.Hour},
+		{time.Date(2025, 11, 1, 8, 0, 0, 0, newYork), 25 * time.Hour},
+	} {
+		if got, _ := s.NextAfter(c.from); got.Sub(c.from) != c.gap {
+			t.Errorf("run after %s is %s later, want %s", c.from, got.Sub(c.from), c.gap)
+		}
+	}
+}
+
+func TestNextAfterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"no weekdays", Schedule{TimeOfDay: "07:30", Timezone: "UTC"}},
+		{"unknown timezone", Schedule{Weekdays: []int{1}, TimeOfDay: "07:30", Timezone: "Mars/Olympus"}},
+		{"invalid time", Schedule{Weekdays: []int{1}, TimeOfDay: "7h30", Timezone: "UTC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.schedule.NextAfter(time.Now()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
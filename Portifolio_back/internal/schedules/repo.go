@@ -0,0 +1,213 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a schedule does not exist (or belongs to another user).
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for schedules and their runs.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new schedules repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsScene checks if a scene belongs to the user.
+func (r *Repo) UserOwnsScene(ctx context.Context, userID, sceneID int64) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM scene WHERE id = $1 AND user_id = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, sceneID, userID).Scan(&exists)
+	return exists, err
+}
+
+// UserOwnsDevice checks if a device belongs to the user.
+func (r *Repo) UserOwnsDevice(ctx context.Context, userID, deviceID int64) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM device WHERE id = $1 AND user_id = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, deviceID, userID).Scan(&exists)
+	return exists, err
+}
+
+const scheduleColumns = `id, user_id, device_id, scene_id, name, action, weekdays, time_of_day, timezone,
+			missed_policy, enabled, next_run_at, last_run_at, created_at, updated_at`
+
+func scanSchedule(row interface{ Scan(dest ...any) error }) (*Schedule, error) {
+	var s Schedule
+	err := row.Scan(&s.ID, &s.UserID, &s.DeviceID, &s.SceneID, &s.Name, &s.Action, &s.Weekdays, &s.TimeOfDay, &s.Timezone,
+		&s.MissedPolicy, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repo) list(ctx context.Context, sql string, args ...any) ([]Schedule, error) {
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// ListByUser returns the schedules of a user, optionally limited to one device (deviceID 0 = all).
+func (r *Repo) ListByUser(ctx context.Context, userID, deviceID int64) ([]Schedule, error) {
+	sql := `SELECT ` + scheduleColumns + ` FROM device_schedule
+			WHERE user_id = $1 AND ($2 = 0 OR device_id = $2)
+			ORDER BY device_id, time_of_day, id`
+	return r.list(ctx, sql, userID, deviceID)
+}
+
+// GetForUser returns a schedule of a user, or ErrNotFound.
+func (r *Repo) GetForUser(ctx context.Context, userID, id int64) (*Schedule, error) {
+	sql := `SELECT ` + scheduleColumns + ` FROM device_schedule WHERE id = $1 AND user_id = $2`
+	s, err := scanSchedule(r.q.QueryRow(ctx, sql, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+// Create inserts a schedule that first runs at next (nil when disabled).
+func (r *Repo) Create(ctx context.Context, userID int64, req *ScheduleRequest, next *time.Time) (*Schedule, error) {
+	sql := `INSERT INTO device_schedule (user_id, device_id, scene_id, name, action, weekdays, time_of_day, timezone,
+				missed_policy, enabled, next_run_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING ` + scheduleColumns
+	return scanSchedule(r.q.QueryRow(ctx, sql, userID, nullID(req.DeviceID), nullID(req.SceneID), req.Name, req.Action,
+		req.Weekdays, req.TimeOfDay, req.Timezone, req.MissedPolicy, req.Enabled == nil || *req.Enabled, next))
+}
+
+// Update replaces a schedule of a user and its next run.
+// It returns ErrNotFound when there is no such schedule.
+func (r *Repo) Update(ctx context.Context, userID, id int64, req *ScheduleRequest, next *time.Time) (*Schedule, error) {
+	sql := `UPDATE device_schedule SET device_id = $3, scene_id = $4, name = $5, action = $6, weekdays = $7,
+				time_of_day = $8, timezone = $9, missed_policy = $10, enabled = $11, next_run_at = $12, updated_at = NOW()
+			WHERE id = $1 AND user_id = $2
+			RETURNING ` + scheduleColumns
+	s, err := scanSchedule(r.q.QueryRow(ctx, sql, id, userID, nullID(req.DeviceID), nullID(req.SceneID), req.Name,
+		req.Action, req.Weekdays, req.TimeOfDay, req.Timezone, req.MissedPolicy, req.Enabled == nil || *req.Enabled, next))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+// Delete removes a schedule of a user and its history.
+// It returns ErrNotFound when there is no such schedule.
+func (r *Repo) Delete(ctx context.Context, userID, id int64) error {
+	var deleted int64
+	err := r.q.QueryRow(ctx, `DELETE FROM device_schedule WHERE id = $1 AND user_id = $2 RETURNING id`, id, userID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ListDue returns the enabled schedules whose next run is at or before now.
+func (r *Repo) ListDue(ctx context.Context, now time.Time) ([]Schedule, error) {
+	sql := `SELECT ` + scheduleColumns + ` FROM device_schedule
+			WHERE enabled AND next_run_at <= $1
+			ORDER BY next_run_at, id`
+	return r.list(ctx, sql, now)
+}
+
+// Advance moves a due schedule to its next run, provided its next run is still
+// due (another scheduler instance has not taken it). It reports whether it did.
+func (r *Repo) Advance(ctx context.Context, id int64, due time.Time, next *time.Time) (bool, error) {
+	sql := `UPDATE device_schedule SET next_run_at = $3, last_run_at = $2
+			WHERE id = $1 AND enabled AND next_run_at = $2
+			RETURNING id`
+	var updated int64
+	err := r.q.QueryRow(ctx, sql, id, due, next).Scan(&updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RecordRun appends an entry to the execution history.
+func (r *Repo) RecordRun(ctx context.Context, run *Run) error {
+	sql := `INSERT INTO schedule_run (schedule_id, device_id, scene_id, action, scheduled_for, status, late, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	return r.q.Exec(ctx, sql, run.ScheduleID, run.DeviceID, run.SceneID, run.Action, run.ScheduledFor, run.Status,
+		run.Late, run.Error)
+}
+
+// ListRuns returns the latest runs of a schedule, newest first.
+func (r *Repo) ListRuns(ctx context.Context, scheduleID int64, limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	sql := `SELECT id, schedule_id, device_id, scene_id, action, scheduled_for, executed_at, status, late, error
+			FROM schedule_run
+			WHERE schedule_id = $1
+			ORDER BY executed_at DESC, id DESC
+			LIMIT $2`
+	rows, err := r.q.Query(ctx, sql, scheduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Run
+	for rows.Next() {
+		var run Run
+		err := rows.Scan(&run.ID, &run.ScheduleID, &run.DeviceID, &run.SceneID, &run.Action, &run.ScheduledFor, &run.ExecutedAt,
+			&run.Status, &run.Late, &run.Error)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, run)
+	}
+	return list, rows.Err()
+}
+
+// nullID maps an unset (zero) ID to NULL.
+func nullID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
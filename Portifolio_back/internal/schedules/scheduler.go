@@ -0,0 +1,164 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/scenes"
+)
+
+const (
+	tickInterval   = 15 * time.Second // how often due schedules are checked
+	lateTolerance  = 5 * time.Minute  // a run found later than this was missed
+	commandTimeout = 30 * time.Second // timeout for switching one device
+)
+
+// DeviceController switches devices (satisfied by devices.Controller).
+type DeviceController interface {
+	DeviceForUser(ctx context.Context, userID, deviceID int64) (*devices.Device, error)
+	SetPower(ctx context.Context, d *devices.Device, on bool) (*devices.Device, error)
+}
+
+// SceneRunner applies scenes (satisfied by scenes.Executor).
+type SceneRunner interface {
+	Apply(ctx context.Context, userID, sceneID int64) (*scenes.Execution, error)
+}
+
+// Scheduler runs due schedules and records their history.
+type Scheduler struct {
+	repo    *Repo
+	control DeviceController
+	scenes  SceneRunner
+	done    chan struct{}
+}
+
+// NewScheduler creates a scheduler; call Start to run it.
+func NewScheduler(repo *Repo, control DeviceController, scenes SceneRunner) *Scheduler {
+	return &Scheduler{repo: repo, control: control, scenes: scenes, done: make(chan struct{})}
+}
+
+// Start runs due schedules until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Wait blocks until the scheduler and the runs in progress have finished.
+func (s *Scheduler) Wait() {
+	<-s.done
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	tick := time.NewTicker(tickInterval)
+	defer tick.Stop()
+
+	for {
+		s.RunDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// RunDue executes every schedule whose next run is at or before now.
+// Runs missed during downtime collapse into one, handled by the missed-run policy.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	due, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		log.Printf("Scheduler: failed to list due schedules: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range due {
+		sch := &due[i]
+		next, err := sch.NextAfter(now)
+		if err != nil {
+			log.Printf("Scheduler: schedule %d has an invalid time: %v", sch.ID, err)
+			continue
+		}
+		claimed, err := s.repo.Advance(ctx, sch.ID, *sch.NextRunAt, &next)
+		if err != nil {
+			log.Printf("Scheduler: failed to advance schedule %d: %v", sch.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // changed or taken by another instance since it was listed
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.execute(ctx, sch, now)
+		}()
+	}
+	wg.Wait()
+}
+
+// execute performs one run of a schedule and records it.
+func (s *Scheduler) execute(ctx context.Context, sch *Schedule, now time.Time) {
+	run := &Run{
+		ScheduleID:   sch.ID,
+		DeviceID:     sch.DeviceID,
+		SceneID:      sch.SceneID,
+		Action:       sch.Action,
+		ScheduledFor: *sch.NextRunAt,
+		Late:         now.Sub(*sch.NextRunAt) > lateTolerance,
+	}
+
+	if run.Late && sch.MissedPolicy == MissedSkip {
+		run.Status = RunSkipped
+		msg := "missed while the scheduler was not running"
+		run.Error = &msg
+	} else if err := s.perform(ctx, sch); err != nil {
+		run.Status = RunFailed
+		msg := err.Error()
+		run.Error = &msg
+		log.Printf("Scheduler: schedule %d failed: %v", sch.ID, err)
+	} else {
+		run.Status = RunSuccess
+	}
+
+	// The device may already have switched: record it even if shutdown started meanwhile
+	if err := s.repo.RecordRun(context.WithoutCancel(ctx), run); err != nil {
+		log.Printf("Scheduler: failed to record run of schedule %d: %v", sch.ID, err)
+	}
+}
+
+// perform switches the schedule's device or applies its scene.
+func (s *Scheduler) perform(ctx context.Context, sch *Schedule) error {
+	// Scenes time out per device, so only a single device command is bounded here
+	if sch.SceneID != nil {
+		exec, err := s.scenes.Apply(ctx, sch.UserID, *sch.SceneID)
+		if err != nil {
+			return fmt.Errorf("scene %d: %w", *sch.SceneID, err)
+		}
+		if exec.Failed > 0 {
+			return fmt.Errorf("scene %d: %d of %d devices failed", *sch.SceneID, exec.Failed, len(exec.Results))
+		}
+		return nil
+	}
+	if sch.DeviceID == nil {
+		return errors.New("schedule has no device or scene")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	d, err := s.control.DeviceForUser(ctx, sch.UserID, *sch.DeviceID)
+	if err != nil {
+		return fmt.Errorf("device %d: %w", *sch.DeviceID, err)
+	}
+	if _, err := s.control.SetPower(ctx, d, sch.Action == ActionOn); err != nil {
+		return fmt.Errorf("device %d: %w", *sch.DeviceID, err)
+	}
+	return nil
+}
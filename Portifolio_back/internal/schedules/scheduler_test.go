@@ -0,0 +1,107 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+)
+
+// memRuns records the runs written by Repo.RecordRun.
+type memRuns struct {
+	runs []Run
+}
+
+func (m *memRuns) Exec(_ context.Context, sql string, args ...any) error {
+	if !strings.HasPrefix(sql, "INSERT INTO schedule_run") {
+		return fmt.Errorf("unexpected exec: %s", sql)
+	}
+	m.runs = append(m.runs, Run{
+		ScheduleID:   args[0].(int64),
+		Action:       args[3].(string),
+		ScheduledFor: args[4].(time.Time),
+		Status:       args[5].(string),
+		Late:         args[6].(bool),
+		Error:        args[7].(*string),
+	})
+	return nil
+}
+
+func (m *memRuns) QueryRow(_ context.Context, sql string, _ ...any) interface{ Scan(dest ...any) error } {
+	return errRow{fmt.Errorf("unexpected query: %s", sql)}
+}
+
+func (m *memRuns) Query(_ context.Context, sql string, _ ...any) (Rows, error) {
+	return nil, fmt.Errorf("unexpected query: %s", sql)
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+// fakeControl switches devices in memory.
+type fakeControl struct {
+	err   error // returned by SetPower when set
+	calls []bool
+}
+
+func (f *fakeControl) DeviceForUser(_ context.Context, userID, deviceID int64) (*devices.Device, error) {
+	return &devices.Device{ID: deviceID, UserID: userID}, nil
+}
+
+func (f *fakeControl) SetPower(_ context.Context, d *devices.Device, on bool) (*devices.Device, error) {
+	f.calls = append(f.calls, on)
+	if f.err != nil {
+		return nil, f.err
+	}
+	d.PowerState = &on
+	return d, nil
+}
+
+func TestExecuteMissedPolicy(t *testing.T) {
+	now := time.Date(2025, 6, 2, 7, 30, 0, 0, time.UTC)
+	device := int64(4)
+
+	tests := []struct {
+		name      string
+		policy    string
+		due       time.Time
+		err       error
+		status    string
+		late      bool
+		switched  bool
+		withError bool
+	}{
+		{"on time", MissedSkip, now.Add(-time.Minute), nil, RunSuccess, false, true, false},
+		{"at the tolerance", MissedSkip, now.Add(-lateTolerance), nil, RunSuccess, false, true, false},
+		{"missed and skipped", MissedSkip, now.Add(-lateTolerance - time.Second), nil, RunSkipped, true, false, true},
+		{"missed and run once", MissedRunOnce, now.Add(-3 * time.Hour), nil, RunSuccess, true, true, false},
+		{"device fails", MissedRunOnce, now.Add(-time.Minute), errors.New("device unreachable"), RunFailed, false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &memRuns{}
+			control := &fakeControl{err: tt.err}
+			s := NewScheduler(NewRepo(db), control, nil)
+			due := tt.due
+			sch := &Schedule{ID: 9, UserID: 7, DeviceID: &device, Action: ActionOn, MissedPolicy: tt.policy, NextRunAt: &due}
+
+			s.execute(context.Background(), sch, now)
+
+			if switched := len(control.calls) > 0; switched != tt.switched {
+				t.Errorf("device switched = %v, want %v", switched, tt.switched)
+			}
+			if len(db.runs) != 1 {
+				t.Fatalf("recorded %d runs, want 1", len(db.runs))
+			}
+			run := db.runs[0]
+			if run.Status != tt.status || run.Late != tt.late || !run.ScheduledFor.Equal(due) || (run.Error != nil) != tt.withError {
+				t.Errorf("run = %+v, want status %s, late %v, scheduled for %s", run, tt.status, tt.late, due)
+			}
+		})
+	}
+}
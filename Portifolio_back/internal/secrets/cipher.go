@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNoKeys is returned when no encryption key is configured.
+var ErrNoKeys = errors.New("no credential encryption key configured")
+
+// ErrUnknownKey is returned when a ciphertext references a key that is not loaded.
+var ErrUnknownKey = errors.New("credential encrypted with unknown key")
+
+// Keyring holds AES-256 keys by id. The active key encrypts; all keys decrypt,
+// which lets old ciphertexts be read while they are rotated to the new key.
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// KeyringFromEnv parses CREDENTIALS_KEYS, a comma-separated list of id:base64key
+// entries. The first entry is the active key, e.g. "k2:<new>,k1:<old>".
+func KeyringFromEnv() (*Keyring, error) {
+	return ParseKeyring(os.Getenv("CREDENTIALS_KEYS"))
+}
+
+// ParseKeyring parses an "id:base64key,..." list; keys must decode to 32 bytes.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q: expected id:base64key", entry)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid base64: %w", id, err)
+		}
+		if len(raw) != 32 {
+			return nil, fmt.Errorf("key %q: must be 32 bytes, got %d", id, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := k.aeads[id]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		k.aeads[id] = aead
+		if k.active == "" {
+			k.active = id
+		}
+	}
+	if k.active == "" {
+		return nil, ErrNoKeys
+	}
+	return k, nil
+}
+
+// ActiveKeyID returns the id of the key used for new ciphertexts.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Encrypt seals plaintext with the active key. The device id is bound as
+// additional data so a ciphertext cannot be copied onto another device.
+func (k *Keyring) Encrypt(deviceID int64, plaintext []byte) (keyID string, nonce, ciphertext []byte, err error) {
+	aead := k.aeads[k.active]
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, nil, err
+	}
+	return k.active, nonce, aead.Seal(nil, nonce, plaintext, additionalData(deviceID)), nil
+}
+
+// Decrypt opens a ciphertext sealed with any loaded key.
+func (k *Keyring) Decrypt(deviceID int64, keyID string, nonce, ciphertext []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	plain, err := aead.Open(nil, nonce, ciphertext, additionalData(deviceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+	return plain, nil
+}
+
+func additionalData(deviceID int64) []byte {
+	return []byte(fmt.Sprintf("device:%d", deviceID))
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a device has no stored credentials.
+var ErrNotFound = errors.New("credentials not found")
+
+// Credentials are the secret part of a device connection.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Redacted returns a representation safe to send to clients.
+func (c *Credentials) Redacted() RedactedCredentials {
+	return RedactedCredentials{Username: maskUsername(c.Username), PasswordSet: c.Password != ""}
+}
+
+// RedactedCredentials is what the API exposes about stored credentials.
+type RedactedCredentials struct {
+	Username    string `json:"username,omitempty"`
+	PasswordSet bool   `json:"password_set"`
+}
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Store keeps device credentials encrypted in the device_secret table.
+type Store struct {
+	q    RowsQuerier
+	keys *Keyring
+}
+
+// NewStore creates a credential store.
+func NewStore(q RowsQuerier, keys *Keyring) *Store {
+	return &Store{q: q, keys: keys}
+}
+
+// Put encrypts and stores (or replaces) the credentials of a device.
+func (s *Store) Put(ctx context.Context, deviceID int64, c Credentials) error {
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	keyID, nonce, ciphertext, err := s.keys.Encrypt(deviceID, plain)
+	if err != nil {
+		return err
+	}
+	sql := `INSERT INTO device_secret (device_id, key_id, nonce, ciphertext, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (device_id) DO UPDATE
+			SET key_id = EXCLUDED.key_id, nonce = EXCLUDED.nonce, ciphertext = EXCLUDED.ciphertext, updated_at = NOW()`
+	return s.q.Exec(ctx, sql, deviceID, keyID, nonce, ciphertext)
+}
+
+// Get returns the decrypted credentials of a device.
+func (s *Store) Get(ctx context.Context, deviceID int64) (*Credentials, error) {
+	sql := `SELECT key_id, nonce, ciphertext FROM device_secret WHERE device_id = $1`
+	var keyID string
+	var nonce, ciphertext []byte
+	if err := s.q.QueryRow(ctx, sql, deviceID).Scan(&keyID, &nonce, &ciphertext); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	plain, err := s.keys.Decrypt(deviceID, keyID, nonce, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var c Credentials
+	if err := json.Unmarshal(plain, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Delete removes the credentials of a device.
+func (s *Store) Delete(ctx context.Context, deviceID int64) error {
+	return s.q.Exec(ctx, `DELETE FROM device_secret WHERE device_id = $1`, deviceID)
+}
+
+// Rotate re-encrypts every credential not sealed with the active key.
+// It returns how many rows were rewritten.
+func (s *Store) Rotate(ctx context.Context) (int, error) {
+	sql := `SELECT device_id FROM device_secret WHERE key_id <> $1`
+	rws, err := s.q.Query(ctx, sql, s.keys.ActiveKeyID())
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rws.Next() {
+		var id int64
+		if err := rws.Scan(&id); err != nil {
+			rws.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rws.Close()
+	if err := rws.Err(); err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, id := range ids {
+		c, err := s.Get(ctx, id)
+		if err != nil {
+			return rotated, err
+		}
+		if err := s.Put(ctx, id, *c); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// maskUsername keeps the first character and the e-mail domain: "p***@gmail.com".
+func maskUsername(u string) string {
+	if u == "" {
+		return ""
+	}
+	at := strings.LastIndex(u, "@")
+	if at <= 0 {
+		return u[:1] + "***"
+	}
+	return u[:1] + "***" + u[at:]
+}
@@ -1,6 +1,7 @@
 package simulator
 
 import (
+	"context"
 	"log"
 	"math"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
 )
 
 func init() {
@@ -38,17 +40,25 @@ type SimulatedTelemetry struct {
 type TelemetryCreator interface {
 	CreateTelemetry(deviceID int64, power, voltage, current float64, timestamp time.Time) (int64, error)
 	UserOwnsDevice(userID, deviceID int64) (bool, error)
-	UpdateDeviceStatus(deviceID int64) error
+	UpdateDeviceStatus(deviceID int64) (bool, error)
+}
+
+// AlertEvaluator checks a stored reading against the device's alert thresholds
+// (satisfied by alerts.Evaluator).
+type AlertEvaluator interface {
+	Evaluate(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error
 }
 
 // Handler handles simulator HTTP requests.
 type Handler struct {
 	Creator TelemetryCreator
+	Events  realtime.Publisher
+	Alerts  AlertEvaluator // nil disables threshold alerts
 }
 
 // NewHandler creates a new simulator handler.
-func NewHandler(creator TelemetryCreator) *Handler {
-	return &Handler{Creator: creator}
+func NewHandler(creator TelemetryCreator, events realtime.Publisher, alerts AlertEvaluator) *Handler {
+	return &Handler{Creator: creator, Events: events, Alerts: alerts}
 }
 
 // RegisterRoutes registers simulator routes on the Gin engine.
@@ -112,9 +122,8 @@ func (h *Handler) Generate(c *gin.Context) {
 	}
 
 	// Update device status
-	if err := h.Creator.UpdateDeviceStatus(deviceID); err != nil {
-		log.Printf("Warning: failed to update device status for device %d: %v", deviceID, err)
-	}
+	h.markOnline(userID, deviceID)
+	h.publish(c.Request.Context(), userID, telemetry)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":        id,
@@ -179,6 +188,7 @@ func (h *Handler) GenerateBulk(c *gin.Context) {
 	// Generate historical readings
 	now := time.Now()
 	created := 0
+	var latest *SimulatedTelemetry
 	for i := config.Count - 1; i >= 0; i-- {
 		timestamp := now.Add(-time.Duration(i*config.IntervalSec) * time.Second)
 		telemetry := generateTelemetry(deviceID, config, timestamp)
@@ -192,12 +202,15 @@ func (h *Handler) GenerateBulk(c *gin.Context) {
 		)
 		if err == nil {
 			created++
+			latest = &telemetry
 		}
 	}
 
 	// Update device status
-	if err := h.Creator.UpdateDeviceStatus(deviceID); err != nil {
-		log.Printf("Warning: failed to update device status for device %d: %v", deviceID, err)
+	h.markOnline(userID, deviceID)
+	// Only the most recent reading is live; the rest is history
+	if latest != nil {
+		h.publish(c.Request.Context(), userID, *latest)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -210,6 +223,29 @@ func (h *Handler) GenerateBulk(c *gin.Context) {
 	})
 }
 
+// markOnline records that the device reported and announces it when it was not online before.
+func (h *Handler) markOnline(userID, deviceID int64) {
+	changed, err := h.Creator.UpdateDeviceStatus(deviceID)
+	if err != nil {
+		log.Printf("Warning: failed to update device status for device %d: %v", deviceID, err)
+	}
+	if changed && h.Events != nil {
+		h.Events.Publish(realtime.StatusEvent(userID, deviceID, "online", nil))
+	}
+}
+
+// publish pushes a simulated reading to live subscribers and checks it against alert thresholds.
+func (h *Handler) publish(ctx context.Context, userID int64, t SimulatedTelemetry) {
+	if h.Events != nil {
+		h.Events.Publish(realtime.TelemetryEvent(userID, t.DeviceID, t))
+	}
+	if h.Alerts != nil {
+		if err := h.Alerts.Evaluate(ctx, userID, t.DeviceID, t.Power, t.Timestamp); err != nil {
+			log.Printf("Warning: failed to evaluate alerts for device %d: %v", t.DeviceID, err)
+		}
+	}
+}
+
 // generateTelemetry creates a simulated telemetry reading with realistic values.
 func generateTelemetry(deviceID int64, config SimulatorConfig, timestamp time.Time) SimulatedTelemetry {
 	// Add random variation to base power
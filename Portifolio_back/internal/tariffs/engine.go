@@ -0,0 +1,150 @@
+package tariffs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+// Report groupings.
+const (
+	GroupByDevice = "device"
+	GroupByRoom   = "room"
+)
+
+// EnergySource integrates telemetry into energy slots (satisfied by telemetry.Repo).
+type EnergySource interface {
+	EnergySlots(ctx context.Context, q *telemetry.AggregateQuery, step time.Duration) ([]telemetry.EnergySlot, error)
+}
+
+// Engine prices energy with each user's tariff and bandeiras.
+type Engine struct {
+	Repo   *Repo
+	Energy EnergySource
+}
+
+// NewEngine creates a cost engine.
+func NewEngine(repo *Repo, energy EnergySource) *Engine {
+	return &Engine{Repo: repo, Energy: energy}
+}
+
+// Cost returns the cost (R$) of each slot, or nil when the user has no tariff.
+func (e *Engine) Cost(ctx context.Context, userID int64, slots []telemetry.EnergySlot) ([]float64, error) {
+	if len(slots) == 0 {
+		return []float64{}, nil
+	}
+	p, err := e.pricer(ctx, userID, slots[0].Start, slots[len(slots)-1].Start)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	costs := make([]float64, len(slots))
+	for i, s := range slots {
+		costs[i] = p.price(s.Start, s.Energy).Total()
+	}
+	return costs, nil
+}
+
+// Report prices the energy used by the user's devices in [from, to), grouped
+// by device or room. It returns ErrNotFound when the user has no tariff.
+func (e *Engine) Report(ctx context.Context, userID int64, from, to time.Time, groupBy string) (*Report, error) {
+	p, err := e.pricer(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := e.Energy.EnergySlots(ctx, &telemetry.AggregateQuery{UserID: userID, From: from, To: to, Location: p.loc}, telemetry.SlotStep)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := e.Repo.listDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &Report{From: from, To: to, Timezone: p.t.Timezone, Kind: p.t.Kind, Groups: []GroupCost{}}
+	posts := map[string]*PostCost{}
+	groups := map[string]*GroupCost{}
+	for _, s := range slots {
+		price := p.price(s.Start, s.Energy)
+		rep.Energy += s.Energy
+		rep.EnergyCost += price.Energy
+		rep.FlagCost += price.Flag
+		rep.Taxes += price.Taxes
+		rep.Cost += price.Total()
+
+		if p.t.Kind == KindWhite {
+			post := p.post(s.Start.In(p.loc))
+			pc, ok := posts[post]
+			if !ok {
+				pc = &PostCost{Post: post}
+				posts[post] = pc
+			}
+			pc.Energy += s.Energy
+			pc.Cost += price.Total()
+		}
+
+		g := group(groups, s.DeviceID, devices[s.DeviceID], groupBy)
+		g.Energy += s.Energy
+		g.Cost += price.Total()
+	}
+
+	for _, post := range []string{PostPeak, PostIntermediate, PostOffPeak} {
+		if pc, ok := posts[post]; ok {
+			rep.Posts = append(rep.Posts, *pc)
+		}
+	}
+	for _, g := range groups {
+		rep.Groups = append(rep.Groups, *g)
+	}
+	sort.Slice(rep.Groups, func(i, j int) bool { return rep.Groups[i].Cost > rep.Groups[j].Cost })
+	return rep, nil
+}
+
+// group returns the running totals of the device's group, creating them on first use.
+func group(groups map[string]*GroupCost, deviceID int64, d deviceInfo, groupBy string) *GroupCost {
+	key := "room:" + d.Room
+	if groupBy != GroupByRoom {
+		key = "device:" + strconv.FormatInt(deviceID, 10)
+	}
+	if g, ok := groups[key]; ok {
+		return g
+	}
+	g := &GroupCost{Name: d.Name}
+	if groupBy == GroupByRoom {
+		room := d.Room
+		g.Room, g.Name = &room, room
+	} else {
+		id := deviceID
+		g.DeviceID = &id
+	}
+	groups[key] = g
+	return g
+}
+
+// pricer loads the user's tariff and the bandeiras of the months from from to to.
+func (e *Engine) pricer(ctx context.Context, userID int64, from, to time.Time) (*pricer, error) {
+	t, err := e.Repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := e.Repo.ListFlags(ctx, userID, monthStart(from.In(loc)), monthStart(to.In(loc)))
+	if err != nil {
+		return nil, err
+	}
+	return newPricer(t, flags)
+}
+
+// monthStart returns the first day of t's month.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
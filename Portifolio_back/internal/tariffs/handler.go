@@ -0,0 +1,256 @@
+package tariffs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles tariff and cost HTTP requests.
+type Handler struct {
+	Engine *Engine
+}
+
+// NewHandler creates a new tariffs handler.
+func NewHandler(engine *Engine) *Handler {
+	return &Handler{Engine: engine}
+}
+
+// RegisterRoutes registers tariff and cost routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	t := r.Group("/tariff")
+	t.GET("", h.Get)
+	t.PUT("", h.Set)
+	t.DELETE("", h.Delete)
+	t.GET("/flags", h.ListFlags)
+	t.PUT("/flags/:month", h.SetFlag)
+	t.DELETE("/flags/:month", h.DeleteFlag)
+
+	r.GET("/costs", h.Costs)
+}
+
+// Get returns the user's tariff.
+func (h *Handler) Get(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	t, err := h.Engine.Repo.Get(c.Request.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tariff not set"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tariff"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// Set creates or replaces the user's tariff.
+func (h *Handler) Set(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req TariffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: kind (convencional|branca) is required"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t, err := h.Engine.Repo.Set(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save tariff"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// Delete removes the user's tariff; costs are no longer reported.
+func (h *Handler) Delete(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	err := h.Engine.Repo.Delete(c.Request.Context(), userID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tariff not set"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tariff"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListFlags returns the bandeiras set for a year; months not listed are verde.
+// Query params: year (default: current year)
+func (h *Handler) ListFlags(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	year := time.Now().Year()
+	if v := c.Query("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 2000 || y > 9999 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+		year = y
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	list, err := h.Engine.Repo.ListFlags(c.Request.Context(), userID, from, from.AddDate(0, 11, 0))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch flags"})
+		return
+	}
+	if list == nil {
+		list = []Flag{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// SetFlag sets the bandeira of a month (:month is YYYY-MM).
+func (h *Handler) SetFlag(c *gin.Context) {
+	userID, month, ok := monthParams(c)
+	if !ok {
+		return
+	}
+	var req FlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: flag must be verde, amarela, vermelha_1, vermelha_2 or escassez_hidrica"})
+		return
+	}
+
+	if err := h.Engine.Repo.SetFlag(c.Request.Context(), userID, month, req.Flag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save flag"})
+		return
+	}
+	c.JSON(http.StatusOK, Flag{Month: month.Format("2006-01"), Flag: req.Flag})
+}
+
+// DeleteFlag removes the bandeira of a month, which then counts as verde.
+func (h *Handler) DeleteFlag(c *gin.Context) {
+	userID, month, ok := monthParams(c)
+	if !ok {
+		return
+	}
+
+	err := h.Engine.Repo.DeleteFlag(c.Request.Context(), userID, month)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "flag not set"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete flag"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Costs prices the energy used by the user's devices with their tariff.
+// Query params: from, to (RFC 3339, default: this month so far), group_by (device|room, default device)
+func (h *Handler) Costs(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", GroupByDevice)
+	if groupBy != GroupByDevice && groupBy != GroupByRoom {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_by: use device or room"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	t, err := h.Engine.Repo.Get(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tariff not set"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tariff"})
+		return
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid tariff timezone"})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: use RFC 3339"})
+			return
+		}
+	}
+	from := monthStart(to.In(loc))
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: use RFC 3339"})
+			return
+		}
+	}
+	if !from.Before(to) || to.Sub(from) > maxReportRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to and at most 400 days earlier"})
+		return
+	}
+
+	rep, err := h.Engine.Report(ctx, userID, from, to, groupBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute costs"})
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+// maxReportRange bounds the period of a cost report.
+const maxReportRange = 400 * 24 * time.Hour
+
+// monthParams reads the user and the :month path parameter (YYYY-MM), writing
+// the error response itself when one is missing or invalid.
+func monthParams(c *gin.Context) (int64, time.Time, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, time.Time{}, false
+	}
+	month, err := time.Parse("2006-01", c.Param("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month: use YYYY-MM"})
+		return 0, time.Time{}, false
+	}
+	return userID, month, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,210 @@
+package tariffs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tariff kinds.
+const (
+	KindConventional = "convencional" // one rate all day
+	KindWhite        = "branca"       // time-of-use rates on business days
+)
+
+// Time-of-use periods (postos tarifários) of the tarifa branca.
+const (
+	PostPeak         = "ponta"
+	PostIntermediate = "intermediario"
+	PostOffPeak      = "fora_ponta"
+)
+
+// Bandeiras tarifárias.
+const (
+	FlagGreen    = "verde"
+	FlagYellow   = "amarela"
+	FlagRed1     = "vermelha_1"
+	FlagRed2     = "vermelha_2"
+	FlagScarcity = "escassez_hidrica"
+)
+
+// Defaults for tariff requests that leave a field out: the usual 18h-21h
+// ponta with one intermediário hour on each side, the flag surcharges set by
+// ANEEL for 2025 (R$/kWh) and the Brasília timezone.
+const (
+	defaultPeakStart           = "18:00"
+	defaultPeakEnd             = "21:00"
+	defaultIntermediateMinutes = 60
+	defaultFlagYellow          = 0.01885
+	defaultFlagRed1            = 0.04463
+	defaultFlagRed2            = 0.07877
+	defaultFlagScarcity        = 0.142
+	defaultTimezone            = "America/Sao_Paulo"
+)
+
+// Tariff is a user's electricity tariff. Rates are R$/kWh before taxes.
+type Tariff struct {
+	UserID              int64     `json:"user_id"`
+	Kind                string    `json:"kind"`
+	Rate                *float64  `json:"rate,omitempty"`              // convencional
+	PeakRate            *float64  `json:"peak_rate,omitempty"`         // branca, ponta
+	IntermediateRate    *float64  `json:"intermediate_rate,omitempty"` // branca, intermediário
+	OffPeakRate         *float64  `json:"off_peak_rate,omitempty"`     // branca, fora ponta
+	PeakStart           string    `json:"peak_start"`                  // HH:MM, local time
+	PeakEnd             string    `json:"peak_end"`
+	IntermediateMinutes int       `json:"intermediate_minutes"` // before and after the ponta
+	FlagYellow          float64   `json:"flag_yellow"`          // R$/kWh surcharges per bandeira
+	FlagRed1            float64   `json:"flag_red_1"`
+	FlagRed2            float64   `json:"flag_red_2"`
+	FlagScarcity        float64   `json:"flag_scarcity"`
+	ICMS                float64   `json:"icms"` // %, charged "por dentro"
+	PIS                 float64   `json:"pis"`
+	COFINS              float64   `json:"cofins"`
+	Timezone            string    `json:"timezone"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// TariffRequest is the payload for setting the tariff.
+type TariffRequest struct {
+	Kind                string   `json:"kind" binding:"required,oneof=convencional branca"`
+	Rate                *float64 `json:"rate"`
+	PeakRate            *float64 `json:"peak_rate"`
+	IntermediateRate    *float64 `json:"intermediate_rate"`
+	OffPeakRate         *float64 `json:"off_peak_rate"`
+	PeakStart           string   `json:"peak_start"`
+	PeakEnd             string   `json:"peak_end"`
+	IntermediateMinutes *int     `json:"intermediate_minutes"`
+	FlagYellow          *float64 `json:"flag_yellow"`
+	FlagRed1            *float64 `json:"flag_red_1"`
+	FlagRed2            *float64 `json:"flag_red_2"`
+	FlagScarcity        *float64 `json:"flag_scarcity"`
+	ICMS                float64  `json:"icms"`
+	PIS                 float64  `json:"pis"`
+	COFINS              float64  `json:"cofins"`
+	Timezone            string   `json:"timezone"`
+}
+
+// Validate checks the rates required by the kind, the ponta window and the
+// taxes, and fills defaults.
+func (r *TariffRequest) Validate() error {
+	switch r.Kind {
+	case KindConventional:
+		if !positive(r.Rate) {
+			return errors.New("convencional requires a positive rate")
+		}
+		r.PeakRate, r.IntermediateRate, r.OffPeakRate = nil, nil, nil
+	case KindWhite:
+		if !positive(r.PeakRate) || !positive(r.IntermediateRate) || !positive(r.OffPeakRate) {
+			return errors.New("branca requires positive peak_rate, intermediate_rate and off_peak_rate")
+		}
+		r.Rate = nil
+	}
+
+	if r.PeakStart == "" {
+		r.PeakStart = defaultPeakStart
+	}
+	if r.PeakEnd == "" {
+		r.PeakEnd = defaultPeakEnd
+	}
+	if r.IntermediateMinutes == nil {
+		m := defaultIntermediateMinutes
+		r.IntermediateMinutes = &m
+	}
+	start, err := minuteOfDay(r.PeakStart)
+	if err != nil {
+		return errors.New("peak_start must be HH:MM")
+	}
+	end, err := minuteOfDay(r.PeakEnd)
+	if err != nil {
+		return errors.New("peak_end must be HH:MM")
+	}
+	im := *r.IntermediateMinutes
+	if start%15 != 0 || end%15 != 0 || im%15 != 0 {
+		return errors.New("peak_start, peak_end and intermediate_minutes must be multiples of 15 minutes")
+	}
+	if start >= end || im < 0 || start-im < 0 || end+im > 24*60 {
+		return errors.New("the ponta and intermediário periods must fit in one day with peak_start before peak_end")
+	}
+
+	r.FlagYellow = orDefault(r.FlagYellow, defaultFlagYellow)
+	r.FlagRed1 = orDefault(r.FlagRed1, defaultFlagRed1)
+	r.FlagRed2 = orDefault(r.FlagRed2, defaultFlagRed2)
+	r.FlagScarcity = orDefault(r.FlagScarcity, defaultFlagScarcity)
+	if *r.FlagYellow < 0 || *r.FlagRed1 < 0 || *r.FlagRed2 < 0 || *r.FlagScarcity < 0 {
+		return errors.New("flag surcharges must not be negative")
+	}
+
+	if r.ICMS < 0 || r.PIS < 0 || r.COFINS < 0 || r.ICMS+r.PIS+r.COFINS >= 100 {
+		return errors.New("icms, pis and cofins must be percentages adding up to less than 100")
+	}
+	if r.Timezone == "" {
+		r.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	return nil
+}
+
+// Flag is the bandeira in force in one month.
+type Flag struct {
+	Month string `json:"month"` // YYYY-MM
+	Flag  string `json:"flag"`
+}
+
+// FlagRequest is the payload for setting a month's bandeira.
+type FlagRequest struct {
+	Flag string `json:"flag" binding:"required,oneof=verde amarela vermelha_1 vermelha_2 escassez_hidrica"`
+}
+
+// Report is the cost of the energy used by a user's devices over a period.
+// Cost = EnergyCost + FlagCost + Taxes.
+type Report struct {
+	From       time.Time   `json:"from"`
+	To         time.Time   `json:"to"`
+	Timezone   string      `json:"timezone"`
+	Kind       string      `json:"kind"`
+	Energy     float64     `json:"energy"` // kWh
+	EnergyCost float64     `json:"energy_cost"`
+	FlagCost   float64     `json:"flag_cost"`
+	Taxes      float64     `json:"taxes"`
+	Cost       float64     `json:"cost"`            // R$
+	Posts      []PostCost  `json:"posts,omitempty"` // tarifa branca only
+	Groups     []GroupCost `json:"groups"`
+}
+
+// PostCost is the energy and cost of one time-of-use period.
+type PostCost struct {
+	Post   string  `json:"post"`
+	Energy float64 `json:"energy"`
+	Cost   float64 `json:"cost"`
+}
+
+// GroupCost is the energy and cost of one device or room.
+type GroupCost struct {
+	DeviceID *int64  `json:"device_id,omitempty"`
+	Room     *string `json:"room,omitempty"`
+	Name     string  `json:"name"`
+	Energy   float64 `json:"energy"`
+	Cost     float64 `json:"cost"`
+}
+
+func positive(v *float64) bool {
+	return v != nil && *v > 0
+}
+
+func orDefault(v *float64, def float64) *float64 {
+	if v == nil {
+		return &def
+	}
+	return v
+}
+
+// minuteOfDay parses HH:MM into minutes since midnight.
+func minuteOfDay(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
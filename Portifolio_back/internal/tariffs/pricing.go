@@ -0,0 +1,162 @@
+package tariffs
+
+import "time"
+
+// Price is the cost of some energy split into its components (R$).
+type Price struct {
+	Energy float64 // energy at the tariff rate
+	Flag   float64 // bandeira surcharge
+	Taxes  float64 // ICMS, PIS and COFINS
+}
+
+// Total returns the amount billed.
+func (p Price) Total() float64 {
+	return p.Energy + p.Flag + p.Taxes
+}
+
+// pricer prices energy at a point in time with one tariff and the bandeiras
+// of the months involved.
+type pricer struct {
+	t        *Tariff
+	loc      *time.Location
+	flags    map[string]string // YYYY-MM -> bandeira
+	peak     [2]int            // ponta [start, end) in minutes of the day
+	holidays map[int]map[string]bool
+}
+
+func newPricer(t *Tariff, flags []Flag) (*pricer, error) {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	start, err := minuteOfDay(t.PeakStart)
+	if err != nil {
+		return nil, err
+	}
+	end, err := minuteOfDay(t.PeakEnd)
+	if err != nil {
+		return nil, err
+	}
+	p := &pricer{t: t, loc: loc, flags: make(map[string]string, len(flags)), peak: [2]int{start, end}, holidays: map[int]map[string]bool{}}
+	for _, f := range flags {
+		p.flags[f.Month] = f.Flag
+	}
+	return p, nil
+}
+
+// price returns the cost of kwh used at time at.
+func (p *pricer) price(at time.Time, kwh float64) Price {
+	local := at.In(p.loc)
+	base := kwh * p.rate(p.post(local))
+	flag := kwh * p.surcharge(p.flags[local.Format("2006-01")])
+
+	// Taxes are charged "por dentro": they are part of the amount they are computed on
+	taxRate := (p.t.ICMS + p.t.PIS + p.t.COFINS) / 100
+	gross := (base + flag) / (1 - taxRate)
+	return Price{Energy: base, Flag: flag, Taxes: gross - base - flag}
+}
+
+// post returns the time-of-use period of a local time. Under the tarifa
+// convencional, and on weekends and national holidays, it is always fora ponta.
+func (p *pricer) post(local time.Time) string {
+	if p.t.Kind != KindWhite || local.Weekday() == time.Saturday || local.Weekday() == time.Sunday || p.holiday(local) {
+		return PostOffPeak
+	}
+	m := local.Hour()*60 + local.Minute()
+	im := p.t.IntermediateMinutes
+	switch {
+	case m >= p.peak[0] && m < p.peak[1]:
+		return PostPeak
+	case m >= p.peak[0]-im && m < p.peak[1]+im:
+		return PostIntermediate
+	default:
+		return PostOffPeak
+	}
+}
+
+func (p *pricer) rate(post string) float64 {
+	if p.t.Kind != KindWhite {
+		return value(p.t.Rate)
+	}
+	switch post {
+	case PostPeak:
+		return value(p.t.PeakRate)
+	case PostIntermediate:
+		return value(p.t.IntermediateRate)
+	default:
+		return value(p.t.OffPeakRate)
+	}
+}
+
+// surcharge returns the R$/kWh added by a bandeira (verde when unset).
+func (p *pricer) surcharge(flag string) float64 {
+	switch flag {
+	case FlagYellow:
+		return p.t.FlagYellow
+	case FlagRed1:
+		return p.t.FlagRed1
+	case FlagRed2:
+		return p.t.FlagRed2
+	case FlagScarcity:
+		return p.t.FlagScarcity
+	default:
+		return 0
+	}
+}
+
+func (p *pricer) holiday(local time.Time) bool {
+	year := local.Year()
+	days, ok := p.holidays[year]
+	if !ok {
+		days = nationalHolidays(year)
+		p.holidays[year] = days
+	}
+	return days[local.Format("01-02")]
+}
+
+// nationalHolidays returns the national holidays ANEEL treats as fora ponta
+// all day, as MM-DD: the fixed dates plus Carnival Monday and Tuesday, Good
+// Friday and Corpus Christi, which follow Easter.
+func nationalHolidays(year int) map[string]bool {
+	days := map[string]bool{
+		"01-01": true, // Confraternização Universal
+		"04-21": true, // Tiradentes
+		"05-01": true, // Dia do Trabalho
+		"09-07": true, // Independência
+		"10-12": true, // Nossa Senhora Aparecida
+		"11-02": true, // Finados
+		"11-15": true, // Proclamação da República
+		"12-25": true, // Natal
+	}
+	if year >= 2024 {
+		days["11-20"] = true // Dia Nacional de Zumbi e da Consciência Negra
+	}
+	easter := easterSunday(year)
+	for _, offset := range []int{-48, -47, -2, 60} {
+		days[easter.AddDate(0, 0, offset).Format("01-02")] = true
+	}
+	return days
+}
+
+// easterSunday computes the date of Easter (Gregorian calendar, anonymous algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
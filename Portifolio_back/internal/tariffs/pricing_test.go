@@ -0,0 +1,192 @@
+package tariffs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func whiteTariff() *Tariff {
+	return &Tariff{
+		Kind:                KindWhite,
+		PeakRate:            ptr(1.2),
+		IntermediateRate:    ptr(0.8),
+		OffPeakRate:         ptr(0.5),
+		PeakStart:           defaultPeakStart,
+		PeakEnd:             defaultPeakEnd,
+		IntermediateMinutes: defaultIntermediateMinutes,
+		FlagYellow:          defaultFlagYellow,
+		FlagRed1:            defaultFlagRed1,
+		FlagRed2:            defaultFlagRed2,
+		FlagScarcity:        defaultFlagScarcity,
+		Timezone:            defaultTimezone,
+	}
+}
+
+func newTestPricer(t *testing.T, tariff *Tariff, flags ...Flag) *pricer {
+	t.Helper()
+	p, err := newPricer(tariff, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPost(t *testing.T) {
+	p := newTestPricer(t, whiteTariff())
+	conventional := whiteTariff()
+	conventional.Kind, conventional.Rate = KindConventional, ptr(0.9)
+
+	tests := []struct {
+		name  string
+		p     *pricer
+		local string // in the tariff's timezone
+		want  string
+	}{
+		{"before intermediário", p, "2025-06-03 16:59", PostOffPeak},
+		{"intermediário starts", p, "2025-06-03 17:00", PostIntermediate},
+		{"last intermediário minute", p, "2025-06-03 17:59", PostIntermediate},
+		{"ponta starts", p, "2025-06-03 18:00", PostPeak},
+		{"last ponta minute", p, "2025-06-03 20:59", PostPeak},
+		{"intermediário after ponta", p, "2025-06-03 21:00", PostIntermediate},
+		{"last intermediário minute after", p, "2025-06-03 21:59", PostIntermediate},
+		{"fora ponta again", p, "2025-06-03 22:00", PostOffPeak},
+		{"midnight", p, "2025-06-03 00:00", PostOffPeak},
+		{"saturday", p, "2025-06-07 19:00", PostOffPeak},
+		{"sunday", p, "2025-06-08 19:00", PostOffPeak},
+		{"fixed holiday", p, "2025-09-07 19:00", PostOffPeak},
+		{"carnival monday", p, "2025-03-03 19:00", PostOffPeak},
+		{"carnival tuesday", p, "2025-03-04 19:00", PostOffPeak},
+		{"ash wednesday", p, "2025-03-05 19:00", PostPeak},
+		{"good friday", p, "2025-04-18 19:00", PostOffPeak},
+		{"corpus christi", p, "2025-06-19 19:00", PostOffPeak},
+		{"carnival in another year", p, "2024-02-13 19:00", PostOffPeak},
+		{"consciência negra", p, "2025-11-20 19:00", PostOffPeak},
+		{"consciência negra before 2024", p, "2023-11-20 19:00", PostPeak},
+		{"convencional", newTestPricer(t, conventional), "2025-06-03 19:00", PostOffPeak},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, err := time.ParseInLocation("2006-01-02 15:04", tt.local, p.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.p.post(local); got != tt.want {
+				t.Errorf("post(%s) = %q, want %q", tt.local, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEasterSunday(t *testing.T) {
+	tests := []struct {
+		year int
+		want string
+	}{
+		{2000, "2000-04-23"},
+		{2019, "2019-04-21"},
+		{2024, "2024-03-31"},
+		{2025, "2025-04-20"},
+		{2026, "2026-04-05"},
+		{2038, "2038-04-25"}, // latest possible date
+		{2285, "2285-03-22"}, // earliest possible date
+	}
+	for _, tt := range tests {
+		if got := easterSunday(tt.year).Format("2006-01-02"); got != tt.want {
+			t.Errorf("easterSunday(%d) = %s, want %s", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestNationalHolidays(t *testing.T) {
+	tests := []struct {
+		year int
+		want []string // moving holidays: Carnival Monday and Tuesday, Good Friday, Corpus Christi
+	}{
+		{2024, []string{"02-12", "02-13", "03-29", "05-30"}},
+		{2025, []string{"03-03", "03-04", "04-18", "06-19"}},
+		{2026, []string{"02-16", "02-17", "04-03", "06-04"}},
+	}
+	for _, tt := range tests {
+		days := nationalHolidays(tt.year)
+		for _, day := range tt.want {
+			if !days[day] {
+				t.Errorf("%d: %s is not a holiday", tt.year, day)
+			}
+		}
+		if want := 8 + 1 + len(tt.want); len(days) != want {
+			t.Errorf("%d: %d holidays, want %d", tt.year, len(days), want)
+		}
+	}
+}
+
+func TestPriceFlagByMonth(t *testing.T) {
+	p := newTestPricer(t, whiteTariff(),
+		Flag{Month: "2025-06", Flag: FlagRed1},
+		Flag{Month: "2025-07", Flag: FlagYellow},
+		Flag{Month: "2025-09", Flag: FlagScarcity},
+	)
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64 // R$/kWh surcharge
+	}{
+		{"vermelha 1", time.Date(2025, 6, 10, 15, 0, 0, 0, time.UTC), defaultFlagRed1},
+		{"amarela", time.Date(2025, 7, 10, 15, 0, 0, 0, time.UTC), defaultFlagYellow},
+		{"unset month is verde", time.Date(2025, 8, 10, 15, 0, 0, 0, time.UTC), 0},
+		{"escassez hídrica", time.Date(2025, 9, 10, 15, 0, 0, 0, time.UTC), defaultFlagScarcity},
+		// 02:00 UTC on July 1st is still June 30th in São Paulo
+		{"month taken in local time", time.Date(2025, 7, 1, 2, 0, 0, 0, time.UTC), defaultFlagRed1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.price(tt.at, 2)
+			if !approx(got.Flag, 2*tt.want) {
+				t.Errorf("flag = %.5f, want %.5f", got.Flag, 2*tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceRateAndTaxes(t *testing.T) {
+	taxed := whiteTariff()
+	taxed.ICMS, taxed.PIS, taxed.COFINS = 18, 1.65, 7.6
+
+	tests := []struct {
+		name   string
+		tariff *Tariff
+		flags  []Flag
+		at     time.Time
+		energy float64
+		flag   float64
+	}{
+		// 21:30 UTC is 18:30 in São Paulo
+		{"ponta in local time", whiteTariff(), nil, time.Date(2025, 6, 3, 21, 30, 0, 0, time.UTC), 2 * 1.2, 0},
+		{"intermediário", whiteTariff(), nil, time.Date(2025, 6, 3, 20, 30, 0, 0, time.UTC), 2 * 0.8, 0},
+		{"fora ponta", whiteTariff(), nil, time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC), 2 * 0.5, 0},
+		{"taxes without flag", taxed, nil, time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC), 2 * 0.5, 0},
+		{"taxes on the flag too", taxed, []Flag{{Month: "2025-06", Flag: FlagRed2}}, time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC), 2 * 0.5, 2 * defaultFlagRed2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestPricer(t, tt.tariff, tt.flags...).price(tt.at, 2)
+			if !approx(got.Energy, tt.energy) || !approx(got.Flag, tt.flag) {
+				t.Fatalf("energy %.5f, flag %.5f; want %.5f, %.5f", got.Energy, got.Flag, tt.energy, tt.flag)
+			}
+			// "Por dentro": the taxes are their rate applied to the total billed, taxes included
+			rate := (tt.tariff.ICMS + tt.tariff.PIS + tt.tariff.COFINS) / 100
+			if !approx(got.Taxes, rate*got.Total()) {
+				t.Errorf("taxes %.5f are not %.2f%% of the total %.5f", got.Taxes, rate*100, got.Total())
+			}
+			if !approx(got.Total(), (tt.energy+tt.flag)/(1-rate)) {
+				t.Errorf("total %.5f, want %.5f", got.Total(), (tt.energy+tt.flag)/(1-rate))
+			}
+		})
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,167 @@
+package tariffs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when the user has no tariff or flag.
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for tariffs.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new tariffs repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+const tariffColumns = `user_id, kind, rate, peak_rate, intermediate_rate, off_peak_rate, peak_start, peak_end,
+			intermediate_minutes, flag_yellow, flag_red_1, flag_red_2, flag_scarcity, icms, pis, cofins, timezone, updated_at`
+
+func scanTariff(row interface{ Scan(dest ...any) error }) (*Tariff, error) {
+	var t Tariff
+	err := row.Scan(&t.UserID, &t.Kind, &t.Rate, &t.PeakRate, &t.IntermediateRate, &t.OffPeakRate, &t.PeakStart, &t.PeakEnd,
+		&t.IntermediateMinutes, &t.FlagYellow, &t.FlagRed1, &t.FlagRed2, &t.FlagScarcity, &t.ICMS, &t.PIS, &t.COFINS,
+		&t.Timezone, &t.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Get returns the user's tariff.
+func (r *Repo) Get(ctx context.Context, userID int64) (*Tariff, error) {
+	sql := `SELECT ` + tariffColumns + ` FROM tariff WHERE user_id = $1`
+	return scanTariff(r.q.QueryRow(ctx, sql, userID))
+}
+
+// Set creates or replaces the user's tariff.
+func (r *Repo) Set(ctx context.Context, userID int64, req *TariffRequest) (*Tariff, error) {
+	sql := `INSERT INTO tariff (user_id, kind, rate, peak_rate, intermediate_rate, off_peak_rate, peak_start, peak_end,
+				intermediate_minutes, flag_yellow, flag_red_1, flag_red_2, flag_scarcity, icms, pis, cofins, timezone)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			ON CONFLICT (user_id) DO UPDATE
+			SET kind = EXCLUDED.kind, rate = EXCLUDED.rate, peak_rate = EXCLUDED.peak_rate,
+				intermediate_rate = EXCLUDED.intermediate_rate, off_peak_rate = EXCLUDED.off_peak_rate,
+				peak_start = EXCLUDED.peak_start, peak_end = EXCLUDED.peak_end,
+				intermediate_minutes = EXCLUDED.intermediate_minutes, flag_yellow = EXCLUDED.flag_yellow,
+				flag_red_1 = EXCLUDED.flag_red_1, flag_red_2 = EXCLUDED.flag_red_2, flag_scarcity = EXCLUDED.flag_scarcity,
+				icms = EXCLUDED.icms, pis = EXCLUDED.pis, cofins = EXCLUDED.cofins, timezone = EXCLUDED.timezone,
+				updated_at = NOW()
+			RETURNING ` + tariffColumns
+	return scanTariff(r.q.QueryRow(ctx, sql, userID, req.Kind, req.Rate, req.PeakRate, req.IntermediateRate, req.OffPeakRate,
+		req.PeakStart, req.PeakEnd, *req.IntermediateMinutes, *req.FlagYellow, *req.FlagRed1, *req.FlagRed2, *req.FlagScarcity,
+		req.ICMS, req.PIS, req.COFINS, req.Timezone))
+}
+
+// Delete removes the user's tariff.
+func (r *Repo) Delete(ctx context.Context, userID int64) error {
+	sql := `DELETE FROM tariff WHERE user_id = $1 RETURNING user_id`
+	var id int64
+	if err := r.q.QueryRow(ctx, sql, userID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListFlags returns the bandeiras set for the months in [from, to], oldest first.
+// Both bounds are the first day of a month.
+func (r *Repo) ListFlags(ctx context.Context, userID int64, from, to time.Time) ([]Flag, error) {
+	sql := `SELECT to_char(month, 'YYYY-MM'), flag FROM tariff_flag
+			WHERE user_id = $1 AND month BETWEEN $2::DATE AND $3::DATE
+			ORDER BY month`
+	rows, err := r.q.Query(ctx, sql, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Flag
+	for rows.Next() {
+		var f Flag
+		if err := rows.Scan(&f.Month, &f.Flag); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// SetFlag sets the bandeira of a month (first day of the month).
+func (r *Repo) SetFlag(ctx context.Context, userID int64, month time.Time, flag string) error {
+	sql := `INSERT INTO tariff_flag (user_id, month, flag) VALUES ($1, $2::DATE, $3)
+			ON CONFLICT (user_id, month) DO UPDATE SET flag = EXCLUDED.flag`
+	return r.q.Exec(ctx, sql, userID, month.Format(time.DateOnly), flag)
+}
+
+// DeleteFlag removes the bandeira of a month, which then counts as verde.
+func (r *Repo) DeleteFlag(ctx context.Context, userID int64, month time.Time) error {
+	sql := `DELETE FROM tariff_flag WHERE user_id = $1 AND month = $2::DATE RETURNING user_id`
+	var id int64
+	if err := r.q.QueryRow(ctx, sql, userID, month.Format(time.DateOnly)).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// deviceInfo is the name and room of a device.
+type deviceInfo struct {
+	Name string
+	Room string
+}
+
+// listDevices returns the name and room of each of the user's devices.
+func (r *Repo) listDevices(ctx context.Context, userID int64) (map[int64]deviceInfo, error) {
+	sql := `SELECT id, name, COALESCE(room, '') FROM device WHERE user_id = $1`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]deviceInfo)
+	for rows.Next() {
+		var id int64
+		var d deviceInfo
+		if err := rows.Scan(&id, &d.Name, &d.Room); err != nil {
+			return nil, err
+		}
+		out[id] = d
+	}
+	return out, rows.Err()
+}
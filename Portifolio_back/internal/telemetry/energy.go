@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxGap is the longest interval between two readings that is still
+// integrated when the device reports no energy counter. It covers the default
+// Tasmota telemetry period (5 min) with room to spare.
+const DefaultMaxGap = 15 * time.Minute
+
+// MaxGapFromEnv reads ENERGY_MAX_GAP_SEC (default DefaultMaxGap).
+func MaxGapFromEnv() time.Duration {
+	if v := os.Getenv("ENERGY_MAX_GAP_SEC"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	return DefaultMaxGap
+}
+
+// sampleColumns selects a reading together with the previous reading of the
+// same device, as used by intervalEnergy.
+const sampleColumns = `t.device_id, t.timestamp AS ts, t.power, t.voltage,
+				LAG(t.timestamp) OVER w AS prev_ts,
+				LAG(t.power) OVER w AS prev_power,
+				LAG(t.energy_total) OVER w AS prev_total,
+				LAG(t.energy_today) OVER w AS prev_today,
+				t.energy_total, t.energy_today`
+
+// intervalEnergy returns the SQL expression for the energy (kWh) used between a
+// sample and the previous one, clipped to start no earlier than the from param.
+// In order of preference it uses:
+//   - the growth of the device's lifetime counter (valid across any gap, a reset is skipped);
+//   - the growth of the device's daily counter, within maxGap (it resets at midnight);
+//   - the trapezoid of the two power readings, within maxGap.
+//
+// Longer gaps without a counter (device unplugged or unreachable) count as no energy.
+func intervalEnergy(maxGap, from string) string {
+	return fmt.Sprintf(`CASE WHEN prev_ts IS NULL OR ts <= prev_ts THEN 0 ELSE
+					(CASE
+						WHEN energy_total >= prev_total THEN energy_total - prev_total
+						WHEN ts - prev_ts <= %[1]s AND energy_today >= prev_today THEN energy_today - prev_today
+						WHEN ts - prev_ts <= %[1]s THEN (power + prev_power) / 2 * EXTRACT(EPOCH FROM ts - prev_ts) / 3600 / 1000
+						ELSE 0
+					END) * EXTRACT(EPOCH FROM ts - GREATEST(prev_ts, %[2]s)) / EXTRACT(EPOCH FROM ts - prev_ts)
+				END`, maxGap, from)
+}
+
+// interval formats a duration as a Postgres interval literal parameter.
+func interval(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int64(d.Seconds()))
+}
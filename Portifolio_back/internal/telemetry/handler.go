@@ -1,22 +1,45 @@
 package telemetry
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
 )
 
+// streamHeartbeat keeps idle SSE connections open through proxies.
+const streamHeartbeat = 25 * time.Second
+
+// AlertEvaluator checks a stored reading against the device's alert thresholds
+// (satisfied by alerts.Evaluator).
+type AlertEvaluator interface {
+	Evaluate(ctx context.Context, userID, deviceID int64, power float64, at time.Time) error
+}
+
+// CostCalculator prices energy slots with the user's tariff (satisfied by tariffs.Engine).
+// It returns one cost (R$) per slot, or nil when the user has no tariff.
+type CostCalculator interface {
+	Cost(ctx context.Context, userID int64, slots []EnergySlot) ([]float64, error)
+}
+
 // Handler handles telemetry HTTP requests.
 type Handler struct {
-	Repo *Repo
+	Repo   *Repo
+	Events *realtime.Hub
+	Alerts AlertEvaluator // nil disables threshold alerts
+	Costs  CostCalculator // nil leaves costs out of summaries and aggregations
 }
 
 // NewHandler creates a new telemetry handler.
-func NewHandler(repo *Repo) *Handler {
-	return &Handler{Repo: repo}
+func NewHandler(repo *Repo, events *realtime.Hub, alerts AlertEvaluator, costs CostCalculator) *Handler {
+	return &Handler{Repo: repo, Events: events, Alerts: alerts, Costs: costs}
 }
 
 // RegisterRoutes registers telemetry routes on the Gin engine.
@@ -24,6 +47,8 @@ func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
 	g := r.Group("/telemetry")
 	g.GET("", h.List)
 	g.GET("/latest", h.ListLatest)
+	g.GET("/aggregate", h.Aggregate)
+	g.GET("/stream", h.Stream)
 	g.POST("", h.Create)
 	g.DELETE("/:id", h.Delete)
 }
@@ -34,6 +59,7 @@ func (h *Handler) RegisterDeviceTelemetryRoutes(r *gin.RouterGroup) {
 	r.GET("/:id/telemetry", h.ListByDevice)
 	r.GET("/:id/telemetry/summary", h.GetSummary)
 	r.GET("/:id/telemetry/latest", h.GetLatest)
+	r.GET("/:id/telemetry/aggregate", h.AggregateDevice)
 }
 
 // List returns telemetry data for the authenticated user or a specific device.
@@ -157,10 +183,207 @@ func (h *Handler) GetSummary(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch telemetry summary"})
 		return
 	}
+	if h.Costs != nil && summary.TotalRecords > 0 {
+		to := time.Now()
+		q := &AggregateQuery{UserID: userID, DeviceID: deviceID, From: to.Add(-summaryWindow(summary.Period)), To: to, Location: time.UTC}
+		_, costs, err := h.pricedSlots(c.Request.Context(), q, SlotStep)
+		if err != nil {
+			log.Printf("Warning: failed to price telemetry summary of device %d: %v", deviceID, err)
+		} else if costs != nil {
+			total := 0.0
+			for _, cost := range costs {
+				total += cost
+			}
+			summary.TotalCost = &total
+		}
+	}
 
 	c.JSON(http.StatusOK, summary)
 }
 
+// maxBuckets bounds the size of an aggregation response.
+const maxBuckets = 10000
+
+// Aggregate returns time-bucketed telemetry for a device, a room or all of the user's devices.
+// Query params: device_id or room (default: all devices), from, to (RFC 3339, default: last 24h),
+// bucket (1m|5m|1h|1d, default 1h), tz (IANA name, default UTC)
+func (h *Handler) Aggregate(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		deviceID = id
+	}
+	room := c.Query("room")
+	if deviceID != 0 && room != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use either device_id or room"})
+		return
+	}
+	if room != "" {
+		exists, err := h.Repo.UserHasRoom(c.Request.Context(), userID, room)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify room"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+			return
+		}
+	}
+	h.aggregate(c, userID, deviceID, room)
+}
+
+// AggregateDevice returns time-bucketed telemetry for a device (via /api/devices/:id/telemetry/aggregate).
+// Query params: from, to, bucket, tz (see Aggregate)
+func (h *Handler) AggregateDevice(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	deviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+	h.aggregate(c, userID, deviceID, "")
+}
+
+func (h *Handler) aggregate(c *gin.Context, userID, deviceID int64, room string) {
+	ctx := c.Request.Context()
+	if deviceID != 0 {
+		owns, err := h.Repo.UserOwnsDevice(ctx, userID, deviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+			return
+		}
+		if !owns {
+			c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+			return
+		}
+	}
+
+	q, err := parseAggregateQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.UserID, q.DeviceID, q.Room = userID, deviceID, room
+
+	buckets, err := h.Repo.Aggregate(ctx, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to aggregate telemetry"})
+		return
+	}
+	if buckets == nil {
+		buckets = []Bucket{}
+	}
+	if h.Costs != nil && len(buckets) > 0 {
+		if err := h.priceBuckets(ctx, q, buckets); err != nil {
+			log.Printf("Warning: failed to price telemetry aggregation for user %d: %v", userID, err)
+		}
+	}
+	agg := &Aggregation{Room: room, From: q.From, To: q.To, Bucket: q.Bucket, Timezone: q.Location.String(), Buckets: buckets}
+	if deviceID != 0 {
+		agg.DeviceID = &deviceID
+	}
+	c.JSON(http.StatusOK, agg)
+}
+
+// priceBuckets sets the cost of each bucket from energy slots no longer than
+// SlotStep, so time-of-use tariffs are applied at their own resolution.
+func (h *Handler) priceBuckets(ctx context.Context, q *AggregateQuery, buckets []Bucket) error {
+	step := min(BucketDurations[q.Bucket], SlotStep)
+	slots, costs, err := h.pricedSlots(ctx, q, step)
+	if err != nil || costs == nil {
+		return err
+	}
+	for i := range buckets {
+		zero := 0.0
+		buckets[i].Cost = &zero
+	}
+	for i, s := range slots {
+		// The bucket holding the slot is the last one starting at or before it
+		j := sort.Search(len(buckets), func(k int) bool { return buckets[k].Start.After(s.Start) }) - 1
+		if j >= 0 {
+			*buckets[j].Cost += costs[i]
+		}
+	}
+	return nil
+}
+
+// pricedSlots returns the energy slots selected by q with their costs (nil
+// when the user has no tariff).
+func (h *Handler) pricedSlots(ctx context.Context, q *AggregateQuery, step time.Duration) ([]EnergySlot, []float64, error) {
+	slots, err := h.Repo.EnergySlots(ctx, q, step)
+	if err != nil {
+		return nil, nil, err
+	}
+	costs, err := h.Costs.Cost(ctx, q.UserID, slots)
+	return slots, costs, err
+}
+
+// summaryWindow is the time range covered by a summary period.
+func summaryWindow(period string) time.Duration {
+	switch period {
+	case "week":
+		return 7 * 24 * time.Hour
+	case "month":
+		return 30 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// parseAggregateQuery reads the time range, bucket size and timezone of an aggregation.
+func parseAggregateQuery(c *gin.Context) (*AggregateQuery, error) {
+	q := &AggregateQuery{Bucket: c.DefaultQuery("bucket", BucketHour), Location: time.UTC}
+	size, ok := BucketDurations[q.Bucket]
+	if !ok {
+		return nil, fmt.Errorf("invalid bucket: use %s, %s, %s or %s", BucketMinute, BucketFiveMinute, BucketHour, BucketDay)
+	}
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tz %q", tz)
+		}
+		q.Location = loc
+	}
+
+	q.To = time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid to: use RFC 3339")
+		}
+		q.To = t
+	}
+	q.From = q.To.Add(-24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid from: use RFC 3339")
+		}
+		q.From = t
+	}
+	if !q.From.Before(q.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if q.To.Sub(q.From)/size > maxBuckets {
+		return nil, fmt.Errorf("range too large for bucket %s (at most %d buckets)", q.Bucket, maxBuckets)
+	}
+	return q, nil
+}
+
 // GetLatest returns the most recent telemetry reading for a device.
 func (h *Handler) GetLatest(c *gin.Context) {
 	userID, ok := getUserID(c)
@@ -235,14 +458,88 @@ func (h *Handler) Create(c *gin.Context) {
 	}
 
 	// Update device last_seen and status to 'online'
-	if err := h.Repo.UpdateDeviceLastSeenAndStatus(c.Request.Context(), req.DeviceID); err != nil {
+	changed, err := h.Repo.UpdateDeviceLastSeenAndStatus(c.Request.Context(), req.DeviceID)
+	if err != nil {
 		log.Printf("Warning: failed to update device status for device %d: %v", req.DeviceID, err)
 	}
 
 	t.ID = id
+	if changed {
+		h.Events.Publish(realtime.StatusEvent(userID, t.DeviceID, "online", nil))
+	}
+	h.Events.Publish(realtime.TelemetryEvent(userID, t.DeviceID, t))
+	if h.Alerts != nil {
+		if err := h.Alerts.Evaluate(c.Request.Context(), userID, t.DeviceID, t.Power, t.Timestamp); err != nil {
+			log.Printf("Warning: failed to evaluate alerts for device %d: %v", t.DeviceID, err)
+		}
+	}
 	c.JSON(http.StatusCreated, t)
 }
 
+// Stream pushes new readings and device status changes of the user's devices
+// as Server-Sent Events. Query params: device_id (optional)
+func (h *Handler) Stream(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if h.Events == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "streaming not available"})
+		return
+	}
+
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		owns, err := h.Repo.UserOwnsDevice(c.Request.Context(), userID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+			return
+		}
+		if !owns {
+			c.JSON(http.StatusForbidden, gin.H{"error": "device not found or not owned by user"})
+			return
+		}
+		deviceID = id
+	}
+
+	sub := h.Events.Subscribe(userID, deviceID)
+	defer h.Events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			c.SSEvent(e.Type, e)
+			c.Writer.Flush()
+		}
+	}
+}
+
 // Delete removes a telemetry record by ID.
 func (h *Handler) Delete(c *gin.Context) {
 	_, ok := getUserID(c)
@@ -4,12 +4,14 @@ import "time"
 
 // Telemetry represents a single telemetry reading from a device.
 type Telemetry struct {
-	ID        int64     `json:"id"`
-	DeviceID  int64     `json:"device_id"`
-	Power     float64   `json:"power"`      // Watts
-	Voltage   *float64  `json:"voltage"`    // Volts (optional)
-	Current   *float64  `json:"current"`    // Amps (optional)
-	Timestamp time.Time `json:"timestamp"`
+	ID          int64     `json:"id"`
+	DeviceID    int64     `json:"device_id"`
+	Power       float64   `json:"power"`                  // Watts
+	Voltage     *float64  `json:"voltage"`                // Volts (optional)
+	Current     *float64  `json:"current"`                // Amps (optional)
+	EnergyTotal *float64  `json:"energy_total,omitempty"` // kWh cumulative counter reported by the device (optional)
+	EnergyToday *float64  `json:"energy_today,omitempty"` // kWh counted by the device today (optional)
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 // CreateTelemetryRequest represents the payload for creating telemetry data.
@@ -30,7 +32,8 @@ type TelemetrySummary struct {
 	AvgPower     float64   `json:"avg_power"`     // Average power in Watts
 	MaxPower     float64   `json:"max_power"`     // Maximum power in Watts
 	MinPower     float64   `json:"min_power"`     // Minimum power in Watts
-	TotalEnergy  float64   `json:"total_energy"`  // Energy in kWh (estimated)
+	TotalEnergy  float64   `json:"total_energy"`  // Energy in kWh (integrated from readings)
+	TotalCost    *float64  `json:"total_cost,omitempty"` // R$ with the user's tariff (nil without one)
 	AvgVoltage   *float64  `json:"avg_voltage"`   // Average voltage (optional)
 	AvgCurrent   *float64  `json:"avg_current"`   // Average current (optional)
 }
@@ -44,3 +47,96 @@ type DeviceWithTelemetry struct {
 	LatestPower *float64 `json:"latest_power,omitempty"`
 	LastSeen   *time.Time `json:"last_seen,omitempty"`
 }
+
+// WindowStats holds aggregates over a device's recent readings.
+// The pointer fields are nil when there are no readings (or no voltage readings).
+type WindowStats struct {
+	DeviceID   int64     `json:"device_id"`
+	Since      time.Time `json:"since"`
+	Count      int       `json:"count"`
+	AvgPower   *float64  `json:"avg_power"`
+	MinPower   *float64  `json:"min_power"`
+	MaxPower   *float64  `json:"max_power"`
+	AvgVoltage *float64  `json:"avg_voltage"`
+	MinVoltage *float64  `json:"min_voltage"`
+	MaxVoltage *float64  `json:"max_voltage"`
+
+	FirstAt    *time.Time `json:"first_at,omitempty"`
+	LastAt     *time.Time `json:"last_at,omitempty"`
+	LongestGap *float64   `json:"longest_gap_sec,omitempty"` // longest time between consecutive readings
+}
+
+// Covers reports whether the readings leave no hole longer than maxGap in the
+// window up to now: none at its start, between readings or since the last one.
+func (s *WindowStats) Covers(now time.Time, maxGap time.Duration) bool {
+	if s.Count == 0 || s.FirstAt == nil || s.LastAt == nil {
+		return false
+	}
+	if s.FirstAt.Sub(s.Since) > maxGap || now.Sub(*s.LastAt) > maxGap {
+		return false
+	}
+	return s.LongestGap == nil || *s.LongestGap <= maxGap.Seconds()
+}
+
+// Aggregation bucket sizes.
+const (
+	BucketMinute     = "1m"
+	BucketFiveMinute = "5m"
+	BucketHour       = "1h"
+	BucketDay        = "1d" // calendar days in the requested timezone
+)
+
+// BucketDurations maps each bucket size to its length.
+var BucketDurations = map[string]time.Duration{
+	BucketMinute:     time.Minute,
+	BucketFiveMinute: 5 * time.Minute,
+	BucketHour:       time.Hour,
+	BucketDay:        24 * time.Hour,
+}
+
+// AggregateQuery selects the readings to aggregate: one device, the devices of
+// a room, or all devices of the user (DeviceID 0 and Room empty).
+type AggregateQuery struct {
+	UserID   int64
+	DeviceID int64
+	Room     string
+	From     time.Time // inclusive
+	To       time.Time // exclusive
+	Bucket   string
+	Location *time.Location
+}
+
+// Aggregation is the time-bucketed telemetry of a device, room or user.
+type Aggregation struct {
+	DeviceID *int64    `json:"device_id,omitempty"`
+	Room     string    `json:"room,omitempty"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Bucket   string    `json:"bucket"`
+	Timezone string    `json:"timezone"`
+	Buckets  []Bucket  `json:"buckets"`
+}
+
+// Bucket holds the aggregates of one time bucket. Over several devices the
+// power figures are the sums of the per-device values, i.e. the combined load;
+// buckets without readings are omitted.
+type Bucket struct {
+	Start      time.Time `json:"start"`
+	Count      int       `json:"count"`
+	AvgPower   float64   `json:"avg_power"`
+	MinPower   float64   `json:"min_power"`
+	MaxPower   float64   `json:"max_power"`
+	AvgVoltage *float64  `json:"avg_voltage"`
+	Energy     float64   `json:"energy"`         // kWh (integrated from readings)
+	Cost       *float64  `json:"cost,omitempty"` // R$ with the user's tariff (nil without one)
+}
+
+// SlotStep is the resolution at which energy is priced.
+const SlotStep = 15 * time.Minute
+
+// EnergySlot is the energy a device used in one time slot.
+type EnergySlot struct {
+	DeviceID int64     `json:"device_id"`
+	Start    time.Time `json:"start"`
+	Energy   float64   `json:"energy"` // kWh
+}
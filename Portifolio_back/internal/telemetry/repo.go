@@ -2,7 +2,10 @@ package telemetry
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
@@ -27,24 +30,25 @@ type Rows interface {
 
 // Repo provides database operations for telemetry.
 type Repo struct {
-	q RowsQuerier
+	q      RowsQuerier
+	maxGap time.Duration // longest reading interval integrated without an energy counter
 }
 
 // NewRepo creates a new telemetry repository.
 func NewRepo(q RowsQuerier) *Repo {
-	return &Repo{q: q}
+	return &Repo{q: q, maxGap: MaxGapFromEnv()}
 }
 
 // Create inserts a new telemetry record and returns its ID.
 func (r *Repo) Create(ctx context.Context, t *Telemetry) (int64, error) {
-	sql := `INSERT INTO telemetry (device_id, power, voltage, current, timestamp)
-			VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	sql := `INSERT INTO telemetry (device_id, power, voltage, current, energy_total, energy_today, timestamp)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 	var id int64
 	ts := t.Timestamp
 	if ts.IsZero() {
 		ts = time.Now()
 	}
-	err := r.q.QueryRow(ctx, sql, t.DeviceID, t.Power, t.Voltage, t.Current, ts).Scan(&id)
+	err := r.q.QueryRow(ctx, sql, t.DeviceID, t.Power, t.Voltage, t.Current, t.EnergyTotal, t.EnergyToday, ts).Scan(&id)
 	return id, err
 }
 
@@ -56,7 +60,7 @@ func (r *Repo) ListByDevice(ctx context.Context, deviceID int64, limit int) ([]T
 	if limit > 1000 {
 		limit = 1000 // Cap at 1000 to prevent resource exhaustion
 	}
-	sql := `SELECT id, device_id, power, voltage, current, timestamp
+	sql := `SELECT id, device_id, power, voltage, current, energy_total, energy_today, timestamp
 			FROM telemetry
 			WHERE device_id = $1
 			ORDER BY timestamp DESC
@@ -70,7 +74,7 @@ func (r *Repo) ListByDevice(ctx context.Context, deviceID int64, limit int) ([]T
 	var out []Telemetry
 	for rws.Next() {
 		var t Telemetry
-		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.Timestamp); err != nil {
+		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.EnergyTotal, &t.EnergyToday, &t.Timestamp); err != nil {
 			return nil, err
 		}
 		out = append(out, t)
@@ -86,7 +90,7 @@ func (r *Repo) ListByUser(ctx context.Context, userID int64, limit int) ([]Telem
 	if limit > 1000 {
 		limit = 1000 // Cap at 1000 to prevent resource exhaustion
 	}
-	sql := `SELECT t.id, t.device_id, t.power, t.voltage, t.current, t.timestamp
+	sql := `SELECT t.id, t.device_id, t.power, t.voltage, t.current, t.energy_total, t.energy_today, t.timestamp
 			FROM telemetry t
 			JOIN device d ON t.device_id = d.id
 			WHERE d.user_id = $1
@@ -101,7 +105,7 @@ func (r *Repo) ListByUser(ctx context.Context, userID int64, limit int) ([]Telem
 	var out []Telemetry
 	for rws.Next() {
 		var t Telemetry
-		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.Timestamp); err != nil {
+		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.EnergyTotal, &t.EnergyToday, &t.Timestamp); err != nil {
 			return nil, err
 		}
 		out = append(out, t)
@@ -130,7 +134,7 @@ func (r *Repo) ListByDeviceForUser(ctx context.Context, userID, deviceID int64,
 	if limit > 1000 {
 		limit = 1000
 	}
-	sql := `SELECT t.id, t.device_id, t.power, t.voltage, t.current, t.timestamp
+	sql := `SELECT t.id, t.device_id, t.power, t.voltage, t.current, t.energy_total, t.energy_today, t.timestamp
 			FROM telemetry t
 			JOIN device d ON t.device_id = d.id
 			WHERE t.device_id = $1 AND d.user_id = $2
@@ -145,7 +149,7 @@ func (r *Repo) ListByDeviceForUser(ctx context.Context, userID, deviceID int64,
 	var out []Telemetry
 	for rws.Next() {
 		var t Telemetry
-		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.Timestamp); err != nil {
+		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.EnergyTotal, &t.EnergyToday, &t.Timestamp); err != nil {
 			return nil, err
 		}
 		out = append(out, t)
@@ -154,9 +158,20 @@ func (r *Repo) ListByDeviceForUser(ctx context.Context, userID, deviceID int64,
 }
 
 // UpdateDeviceLastSeenAndStatus updates the device last_seen and status when telemetry is received.
-func (r *Repo) UpdateDeviceLastSeenAndStatus(ctx context.Context, deviceID int64) error {
-	sql := `UPDATE device SET last_seen = NOW(), status = 'online' WHERE id = $1`
-	return r.q.Exec(ctx, sql, deviceID)
+// It reports whether the device was not online before, so callers can announce the change.
+func (r *Repo) UpdateDeviceLastSeenAndStatus(ctx context.Context, deviceID int64) (bool, error) {
+	sql := `UPDATE device d SET last_seen = NOW(), status = 'online'
+		FROM (SELECT status FROM device WHERE id = $1 FOR UPDATE) prev
+		WHERE d.id = $1
+		RETURNING prev.status IS DISTINCT FROM 'online'`
+	var changed bool
+	if err := r.q.QueryRow(ctx, sql, deviceID).Scan(&changed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return changed, nil
 }
 
 // GetSummaryByDevice returns aggregated telemetry for a device over a time period.
@@ -208,11 +223,12 @@ func (r *Repo) GetSummaryByDevice(ctx context.Context, userID, deviceID int64, p
 		return nil, err
 	}
 
-	// Calculate estimated energy consumption (kWh)
-	// Energy = Power * Time (hours)
 	if summary.TotalRecords > 0 {
-		duration := summary.EndTime.Sub(summary.StartTime).Hours()
-		summary.TotalEnergy = (summary.AvgPower * duration) / 1000 // Convert Wh to kWh
+		now := time.Now()
+		summary.TotalEnergy, err = r.Energy(ctx, deviceID, now.AddDate(0, 0, -days), now)
+		if err != nil {
+			return nil, err
+		}
 		if avgVoltage > 0 {
 			summary.AvgVoltage = &avgVoltage
 		}
@@ -224,15 +240,59 @@ func (r *Repo) GetSummaryByDevice(ctx context.Context, userID, deviceID int64, p
 	return &summary, nil
 }
 
+// WindowStats aggregates the readings of a device since a point in time.
+func (r *Repo) WindowStats(ctx context.Context, deviceID int64, since time.Time) (*WindowStats, error) {
+	sql := `SELECT COUNT(*), AVG(power), MIN(power), MAX(power), AVG(voltage), MIN(voltage), MAX(voltage),
+				MIN(timestamp), MAX(timestamp), MAX(gap)
+			FROM (
+				SELECT power, voltage, timestamp,
+					EXTRACT(EPOCH FROM timestamp - LAG(timestamp) OVER (ORDER BY timestamp))::FLOAT8 AS gap
+				FROM telemetry
+				WHERE device_id = $1 AND timestamp >= $2
+			) r`
+	s := WindowStats{DeviceID: deviceID, Since: since}
+	err := r.q.QueryRow(ctx, sql, deviceID, since).Scan(
+		&s.Count, &s.AvgPower, &s.MinPower, &s.MaxPower, &s.AvgVoltage, &s.MinVoltage, &s.MaxVoltage,
+		&s.FirstAt, &s.LastAt, &s.LongestGap,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// EnergySince returns the energy (kWh) used by a device since a point in time.
+func (r *Repo) EnergySince(ctx context.Context, deviceID int64, since time.Time) (float64, error) {
+	return r.Energy(ctx, deviceID, since, time.Now())
+}
+
+// Energy integrates the energy (kWh) used by a device in [from, to) over
+// consecutive readings (see intervalEnergy). The reading before from, if
+// within the max gap, covers the start of the range.
+func (r *Repo) Energy(ctx context.Context, deviceID int64, from, to time.Time) (float64, error) {
+	sql := `WITH samples AS (
+				SELECT ` + sampleColumns + `
+				FROM telemetry t
+				WHERE t.device_id = $1 AND t.timestamp >= $2::TIMESTAMPTZ - $4::INTERVAL AND t.timestamp < $3
+				WINDOW w AS (ORDER BY t.timestamp)
+			)
+			SELECT COALESCE(SUM(` + intervalEnergy("$4::INTERVAL", "$2::TIMESTAMPTZ") + `), 0)
+			FROM samples
+			WHERE ts >= $2`
+	var kwh float64
+	err := r.q.QueryRow(ctx, sql, deviceID, from, to, interval(r.maxGap)).Scan(&kwh)
+	return kwh, err
+}
+
 // GetLatestByDevice returns the most recent telemetry reading for a device.
 func (r *Repo) GetLatestByDevice(ctx context.Context, deviceID int64) (*Telemetry, error) {
-	sql := `SELECT id, device_id, power, voltage, current, timestamp
+	sql := `SELECT id, device_id, power, voltage, current, energy_total, energy_today, timestamp
 			FROM telemetry
 			WHERE device_id = $1
 			ORDER BY timestamp DESC
 			LIMIT 1`
 	var t Telemetry
-	err := r.q.QueryRow(ctx, sql, deviceID).Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.Timestamp)
+	err := r.q.QueryRow(ctx, sql, deviceID).Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.EnergyTotal, &t.EnergyToday, &t.Timestamp)
 	if err != nil {
 		return nil, err
 	}
@@ -241,7 +301,7 @@ func (r *Repo) GetLatestByDevice(ctx context.Context, deviceID int64) (*Telemetr
 
 // GetLatestByUserDevices returns the latest telemetry for all devices of a user (one per device).
 func (r *Repo) GetLatestByUserDevices(ctx context.Context, userID int64) ([]Telemetry, error) {
-	sql := `SELECT DISTINCT ON (t.device_id) t.id, t.device_id, t.power, t.voltage, t.current, t.timestamp
+	sql := `SELECT DISTINCT ON (t.device_id) t.id, t.device_id, t.power, t.voltage, t.current, t.energy_total, t.energy_today, t.timestamp
 			FROM telemetry t
 			JOIN device d ON t.device_id = d.id
 			WHERE d.user_id = $1
@@ -255,10 +315,113 @@ func (r *Repo) GetLatestByUserDevices(ctx context.Context, userID int64) ([]Tele
 	var out []Telemetry
 	for rws.Next() {
 		var t Telemetry
-		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.Timestamp); err != nil {
+		if err := rws.Scan(&t.ID, &t.DeviceID, &t.Power, &t.Voltage, &t.Current, &t.EnergyTotal, &t.EnergyToday, &t.Timestamp); err != nil {
 			return nil, err
 		}
 		out = append(out, t)
 	}
 	return out, rws.Err()
 }
+
+// Aggregate groups the readings selected by q into time buckets. Day buckets
+// follow calendar days in q.Location; shorter ones are aligned to local midnight
+// of q.From. Energy is integrated as in Energy, each interval counting in the
+// bucket of the reading that ends it.
+func (r *Repo) Aggregate(ctx context.Context, q *AggregateQuery) ([]Bucket, error) {
+	args := []any{q.UserID, q.DeviceID, q.Room, q.From, q.To, interval(r.maxGap)}
+	var bucket string
+	if q.Bucket == BucketDay {
+		bucket = `date_trunc('day', ts, $7)`
+		args = append(args, q.Location.String())
+	} else {
+		y, m, d := q.From.In(q.Location).Date()
+		origin := time.Date(y, m, d, 0, 0, 0, 0, q.Location)
+		bucket = `date_bin($7::INTERVAL, ts, $8::TIMESTAMPTZ)`
+		args = append(args, interval(BucketDurations[q.Bucket]), origin)
+	}
+
+	// Aggregate per device first so several devices add up to the combined load
+	sql := `WITH samples AS (
+				SELECT ` + sampleColumns + `
+				FROM telemetry t
+				JOIN device d ON d.id = t.device_id
+				WHERE d.user_id = $1 AND ($2 = 0 OR t.device_id = $2) AND ($3 = '' OR d.room = $3)
+					AND t.timestamp >= $4::TIMESTAMPTZ - $6::INTERVAL AND t.timestamp < $5
+				WINDOW w AS (PARTITION BY t.device_id ORDER BY t.timestamp)
+			), per_device AS (
+				SELECT ` + bucket + ` AS bucket,
+					COUNT(*) AS readings,
+					AVG(power) AS avg_power,
+					MIN(power) AS min_power,
+					MAX(power) AS max_power,
+					AVG(voltage) AS avg_voltage,
+					SUM(` + intervalEnergy("$6::INTERVAL", "$4::TIMESTAMPTZ") + `) AS energy
+				FROM samples
+				WHERE ts >= $4
+				GROUP BY device_id, 1
+			)
+			SELECT bucket, SUM(readings)::INT, SUM(avg_power), SUM(min_power), SUM(max_power), AVG(avg_voltage), SUM(energy)
+			FROM per_device
+			GROUP BY bucket
+			ORDER BY bucket`
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Bucket
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Start, &b.Count, &b.AvgPower, &b.MinPower, &b.MaxPower, &b.AvgVoltage, &b.Energy); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// EnergySlots integrates the energy of each device selected by q (as in
+// Aggregate) into slots of the given step, aligned to local midnight of q.From.
+// Slots without readings are omitted; the result is ordered by slot.
+func (r *Repo) EnergySlots(ctx context.Context, q *AggregateQuery, step time.Duration) ([]EnergySlot, error) {
+	y, m, d := q.From.In(q.Location).Date()
+	origin := time.Date(y, m, d, 0, 0, 0, 0, q.Location)
+	sql := `WITH samples AS (
+				SELECT ` + sampleColumns + `
+				FROM telemetry t
+				JOIN device d ON d.id = t.device_id
+				WHERE d.user_id = $1 AND ($2 = 0 OR t.device_id = $2) AND ($3 = '' OR d.room = $3)
+					AND t.timestamp >= $4::TIMESTAMPTZ - $6::INTERVAL AND t.timestamp < $5
+				WINDOW w AS (PARTITION BY t.device_id ORDER BY t.timestamp)
+			)
+			SELECT device_id, date_bin($7::INTERVAL, ts, $8::TIMESTAMPTZ) AS slot,
+				SUM(` + intervalEnergy("$6::INTERVAL", "$4::TIMESTAMPTZ") + `)
+			FROM samples
+			WHERE ts >= $4
+			GROUP BY device_id, slot
+			ORDER BY slot, device_id`
+	rows, err := r.q.Query(ctx, sql, q.UserID, q.DeviceID, q.Room, q.From, q.To, interval(r.maxGap), interval(step), origin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EnergySlot
+	for rows.Next() {
+		var s EnergySlot
+		if err := rows.Scan(&s.DeviceID, &s.Start, &s.Energy); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// UserHasRoom checks if the user has a device in the room.
+func (r *Repo) UserHasRoom(ctx context.Context, userID int64, room string) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM device WHERE user_id = $1 AND room = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, userID, room).Scan(&exists)
+	return exists, err
+}
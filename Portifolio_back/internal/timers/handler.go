@@ -0,0 +1,144 @@
+package timers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles timer HTTP requests.
+type Handler struct {
+	Repo *Repo
+}
+
+// NewHandler creates a new timers handler.
+func NewHandler(repo *Repo) *Handler {
+	return &Handler{Repo: repo}
+}
+
+// RegisterRoutes registers timer routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	t := r.Group("/timers")
+	t.GET("", h.List)
+	t.POST("", h.Create)
+	t.DELETE("/:id", h.Cancel)
+}
+
+// List returns the user's timers, newest first.
+// Query params: device_id, status (active|fired|failed|cancelled)
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var deviceID int64
+	if v := c.Query("device_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device_id"})
+			return
+		}
+		deviceID = id
+	}
+	status := c.Query("status")
+	switch status {
+	case "", StatusActive, StatusFired, StatusFailed, StatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	list, err := h.Repo.ListByUser(c.Request.Context(), userID, deviceID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch timers"})
+		return
+	}
+	if list == nil {
+		list = []Timer{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Create starts a timer on one of the user's devices.
+func (h *Handler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req TimerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: device_id, kind (countdown|idle_off|overrun_off) and duration_minutes (1-10080) are required"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	owns, err := h.Repo.UserOwnsDevice(ctx, userID, req.DeviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify device ownership"})
+		return
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+
+	var fireAt *time.Time
+	if req.Kind == KindCountdown {
+		at := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+		fireAt = &at
+	}
+	t, err := h.Repo.Create(ctx, userID, &req, fireAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create timer"})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// Cancel stops an active timer; it stays in the list as cancelled.
+func (h *Handler) Cancel(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	t, err := h.Repo.Cancel(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "timer not found or not active"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel timer"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
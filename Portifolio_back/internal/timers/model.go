@@ -0,0 +1,72 @@
+package timers
+
+import (
+	"errors"
+	"time"
+)
+
+// Timer kinds.
+const (
+	KindCountdown  = "countdown"   // run action once duration_minutes have passed
+	KindIdleOff    = "idle_off"    // turn off once power stays below threshold_watts for duration_minutes
+	KindOverrunOff = "overrun_off" // turn off once power stays above threshold_watts for duration_minutes
+)
+
+// Timer states.
+const (
+	StatusActive    = "active"
+	StatusFired     = "fired"
+	StatusFailed    = "failed" // fired but the device command failed
+	StatusCancelled = "cancelled"
+)
+
+// Actions a countdown can perform; power-based timers always turn the device off.
+const (
+	ActionOn  = "on"
+	ActionOff = "off"
+)
+
+// Timer is a one-shot device automation. Timers are stored in the database,
+// so active ones keep counting across restarts.
+type Timer struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	DeviceID        int64      `json:"device_id"`
+	Kind            string     `json:"kind"`
+	Action          string     `json:"action"`
+	FireAt          *time.Time `json:"fire_at,omitempty"`
+	ThresholdWatts  *float64   `json:"threshold_watts,omitempty"`
+	DurationMinutes int        `json:"duration_minutes"`
+	Status          string     `json:"status"`
+	Error           *string    `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	FiredAt         *time.Time `json:"fired_at,omitempty"`
+	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// TimerRequest is the payload for creating a timer.
+type TimerRequest struct {
+	DeviceID        int64    `json:"device_id" binding:"required"`
+	Kind            string   `json:"kind" binding:"required,oneof=countdown idle_off overrun_off"`
+	Action          string   `json:"action" binding:"omitempty,oneof=on off"`
+	ThresholdWatts  *float64 `json:"threshold_watts"`
+	DurationMinutes int      `json:"duration_minutes" binding:"required,min=1,max=10080"`
+}
+
+// Validate checks the fields required by the timer kind and fills defaults.
+func (r *TimerRequest) Validate() error {
+	if r.Action == "" {
+		r.Action = ActionOff
+	}
+	if r.Kind == KindCountdown {
+		r.ThresholdWatts = nil
+		return nil
+	}
+	if r.Action != ActionOff {
+		return errors.New("power-based timers can only turn the device off")
+	}
+	if r.ThresholdWatts == nil || *r.ThresholdWatts < 0 {
+		return errors.New("threshold_watts is required and must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package timers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a timer does not exist (or belongs to another user).
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// Repo provides database operations for timers.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new timers repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+// UserOwnsDevice checks if a device belongs to the user.
+func (r *Repo) UserOwnsDevice(ctx context.Context, userID, deviceID int64) (bool, error) {
+	sql := `SELECT EXISTS(SELECT 1 FROM device WHERE id = $1 AND user_id = $2)`
+	var exists bool
+	err := r.q.QueryRow(ctx, sql, deviceID, userID).Scan(&exists)
+	return exists, err
+}
+
+const timerColumns = `id, user_id, device_id, kind, action, fire_at, threshold_watts, duration_minutes,
+			status, error, created_at, fired_at, cancelled_at`
+
+func scanTimer(row interface{ Scan(dest ...any) error }) (*Timer, error) {
+	var t Timer
+	err := row.Scan(&t.ID, &t.UserID, &t.DeviceID, &t.Kind, &t.Action, &t.FireAt, &t.ThresholdWatts, &t.DurationMinutes,
+		&t.Status, &t.Error, &t.CreatedAt, &t.FiredAt, &t.CancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Repo) list(ctx context.Context, sql string, args ...any) ([]Timer, error) {
+	rows, err := r.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Timer
+	for rows.Next() {
+		t, err := scanTimer(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// ListByUser returns the timers of a user, newest first.
+// deviceID 0 and status "" match any.
+func (r *Repo) ListByUser(ctx context.Context, userID, deviceID int64, status string) ([]Timer, error) {
+	sql := `SELECT ` + timerColumns + ` FROM device_timer
+			WHERE user_id = $1 AND ($2 = 0 OR device_id = $2) AND ($3 = '' OR status = $3)
+			ORDER BY created_at DESC, id DESC
+			LIMIT 200`
+	return r.list(ctx, sql, userID, deviceID, status)
+}
+
+// Create inserts an active timer.
+func (r *Repo) Create(ctx context.Context, userID int64, req *TimerRequest, fireAt *time.Time) (*Timer, error) {
+	sql := `INSERT INTO device_timer (user_id, device_id, kind, action, fire_at, threshold_watts, duration_minutes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING ` + timerColumns
+	return scanTimer(r.q.QueryRow(ctx, sql, userID, req.DeviceID, req.Kind, req.Action, fireAt,
+		req.ThresholdWatts, req.DurationMinutes))
+}
+
+// Cancel stops an active timer of a user.
+// It returns ErrNotFound when there is no such active timer.
+func (r *Repo) Cancel(ctx context.Context, userID, id int64) (*Timer, error) {
+	sql := `UPDATE device_timer SET status = 'cancelled', cancelled_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND status = 'active'
+			RETURNING ` + timerColumns
+	t, err := scanTimer(r.q.QueryRow(ctx, sql, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// ListActive returns every active timer.
+func (r *Repo) ListActive(ctx context.Context) ([]Timer, error) {
+	sql := `SELECT ` + timerColumns + ` FROM device_timer WHERE status = 'active' ORDER BY id`
+	return r.list(ctx, sql)
+}
+
+// MarkFired moves an active timer to fired, reporting false when it is no
+// longer active (cancelled or taken by another instance).
+func (r *Repo) MarkFired(ctx context.Context, id int64) (bool, error) {
+	sql := `UPDATE device_timer SET status = 'fired', fired_at = NOW()
+			WHERE id = $1 AND status = 'active'
+			RETURNING id`
+	var updated int64
+	err := r.q.QueryRow(ctx, sql, id).Scan(&updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MarkFailed records that a fired timer's device command failed.
+func (r *Repo) MarkFailed(ctx context.Context, id int64, cmdErr string) error {
+	return r.q.Exec(ctx, `UPDATE device_timer SET status = 'failed', error = $2 WHERE id = $1`, id, cmdErr)
+}
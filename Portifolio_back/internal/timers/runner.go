@@ -0,0 +1,152 @@
+package timers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+const (
+	tickInterval   = 15 * time.Second // how often active timers are checked
+	commandTimeout = 30 * time.Second // timeout for switching one device
+)
+
+// DeviceController switches devices (satisfied by devices.Controller).
+type DeviceController interface {
+	DeviceForUser(ctx context.Context, userID, deviceID int64) (*devices.Device, error)
+	SetPower(ctx context.Context, d *devices.Device, on bool) (*devices.Device, error)
+}
+
+// TelemetryStats aggregates recorded readings (satisfied by telemetry.Repo).
+type TelemetryStats interface {
+	WindowStats(ctx context.Context, deviceID int64, since time.Time) (*telemetry.WindowStats, error)
+}
+
+// Runner fires active timers when they come due.
+type Runner struct {
+	repo    *Repo
+	control DeviceController
+	stats   TelemetryStats
+	maxGap  time.Duration // longest hole in the readings a power-based timer tolerates
+	done    chan struct{}
+}
+
+// NewRunner creates a timer runner; call Start to run it.
+func NewRunner(repo *Repo, control DeviceController, stats TelemetryStats) *Runner {
+	return &Runner{repo: repo, control: control, stats: stats, maxGap: telemetry.MaxGapFromEnv(), done: make(chan struct{})}
+}
+
+// Start checks the timers until ctx is cancelled.
+func (r *Runner) Start(ctx context.Context) {
+	go r.run(ctx)
+}
+
+// Wait blocks until the runner and the commands in progress have finished.
+func (r *Runner) Wait() {
+	<-r.done
+}
+
+func (r *Runner) run(ctx context.Context) {
+	defer close(r.done)
+
+	tick := time.NewTicker(tickInterval)
+	defer tick.Stop()
+
+	for {
+		r.FireDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// FireDue fires every active timer whose condition holds at now.
+func (r *Runner) FireDue(ctx context.Context, now time.Time) {
+	active, err := r.repo.ListActive(ctx)
+	if err != nil {
+		log.Printf("Timers: failed to list active timers: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range active {
+		t := &active[i]
+		due, err := r.due(ctx, t, now)
+		if err != nil {
+			log.Printf("Timers: failed to check timer %d: %v", t.ID, err)
+			continue
+		}
+		if !due {
+			continue
+		}
+		fired, err := r.repo.MarkFired(ctx, t.ID)
+		if err != nil {
+			log.Printf("Timers: failed to mark timer %d fired: %v", t.ID, err)
+			continue
+		}
+		if !fired {
+			continue // cancelled or taken by another instance since it was listed
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.fire(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// due reports whether a timer's condition holds at now. Power-based timers need
+// readings covering their whole duration since they were created, with no hole
+// longer than the energy max gap; a device that stops reporting never fires them.
+func (r *Runner) due(ctx context.Context, t *Timer, now time.Time) (bool, error) {
+	if t.Kind == KindCountdown {
+		return t.FireAt != nil && !t.FireAt.After(now), nil
+	}
+
+	since := now.Add(-time.Duration(t.DurationMinutes) * time.Minute)
+	if t.CreatedAt.After(since) || t.ThresholdWatts == nil {
+		return false, nil
+	}
+	s, err := r.stats.WindowStats(ctx, t.DeviceID, since)
+	if err != nil || !s.Covers(now, r.maxGap) {
+		return false, err
+	}
+	switch t.Kind {
+	case KindIdleOff:
+		return *s.MaxPower < *t.ThresholdWatts, nil
+	case KindOverrunOff:
+		return *s.MinPower > *t.ThresholdWatts, nil
+	}
+	return false, nil
+}
+
+func (r *Runner) fire(ctx context.Context, t *Timer) {
+	cmdCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	err := func() error {
+		d, err := r.control.DeviceForUser(cmdCtx, t.UserID, t.DeviceID)
+		if err != nil {
+			return err
+		}
+		_, err = r.control.SetPower(cmdCtx, d, t.Action == ActionOn)
+		return err
+	}()
+	if err == nil {
+		log.Printf("Timers: timer %d turned device %d %s", t.ID, t.DeviceID, t.Action)
+		return
+	}
+
+	log.Printf("Timers: timer %d failed to turn device %d %s: %v", t.ID, t.DeviceID, t.Action, err)
+	if err := r.repo.MarkFailed(context.WithoutCancel(ctx), t.ID, err.Error()); err != nil {
+		log.Printf("Timers: failed to record failure of timer %d: %v", t.ID, err)
+	}
+}
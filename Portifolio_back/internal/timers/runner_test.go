@@ -0,0 +1,64 @@
+package timers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/telemetry"
+)
+
+type fakeStats struct{ s telemetry.WindowStats }
+
+func (f *fakeStats) WindowStats(_ context.Context, deviceID int64, since time.Time) (*telemetry.WindowStats, error) {
+	s := f.s
+	s.DeviceID, s.Since = deviceID, since
+	return &s, nil
+}
+
+func TestDueRequiresCoverage(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-30 * time.Minute)
+	at := func(min int) *time.Time { v := since.Add(time.Duration(min) * time.Minute); return &v }
+	secs := func(min int) *float64 { v := float64(min * 60); return &v }
+	watts := func(v float64) *float64 { return &v }
+
+	timer := &Timer{
+		DeviceID:        1,
+		Kind:            KindIdleOff,
+		ThresholdWatts:  watts(5),
+		DurationMinutes: 30,
+		CreatedAt:       now.Add(-time.Hour),
+	}
+	idle := telemetry.WindowStats{Count: 30, MinPower: watts(1), MaxPower: watts(2)}
+
+	tests := []struct {
+		name        string
+		first, last *time.Time
+		longest     *float64
+		count       int
+		want        bool
+	}{
+		{"readings throughout", at(0), at(30), secs(1), 30, true},
+		{"first reading within the max gap", at(10), at(29), secs(5), 10, true},
+		{"no readings", nil, nil, nil, 0, false},
+		{"first reading too late", at(20), at(30), secs(1), 10, false},
+		{"hole between readings", at(0), at(30), secs(20), 2, false},
+		{"device stopped reporting", at(0), at(10), secs(1), 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := idle
+			s.Count, s.FirstAt, s.LastAt, s.LongestGap = tt.count, tt.first, tt.last, tt.longest
+			r := &Runner{stats: &fakeStats{s}, maxGap: 15 * time.Minute}
+
+			got, err := r.due(context.Background(), timer, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("due = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
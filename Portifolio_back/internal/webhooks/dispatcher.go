@@ -0,0 +1,336 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/retry"
+)
+
+const (
+	eventBuffer      = 1024             // events waiting to be written to the outbox
+	dispatchInterval = 5 * time.Second  // how often due deliveries are checked
+	dispatchBatch    = 50               // deliveries claimed per check
+	concurrency      = 4                // simultaneous requests
+	requestTimeout   = 10 * time.Second // timeout for a single request
+	maxAttempts      = 8                // attempts before a delivery is marked failed
+	maxResponseBody  = 1024             // bytes of the response kept in the delivery log
+)
+
+// Request headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature header value of a delivery:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher turns hub events into webhook deliveries and sends them,
+// retrying failures with exponential backoff.
+type Dispatcher struct {
+	repo   *Repo
+	client *http.Client
+
+	events  chan realtime.Event
+	wake    chan struct{}
+	dropped atomic.Int64
+	done    chan struct{}
+}
+
+// NewDispatcher creates a dispatcher; register Observe on the hub and call Start.
+func NewDispatcher(repo *Repo) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: newClient(),
+		events: make(chan realtime.Event, eventBuffer),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// newClient returns the HTTP client deliveries are sent with. Webhook URLs are
+// user supplied, so it refuses to connect to internal addresses (checked on the
+// resolved IP at dial time, which also covers DNS rebinding) and does not follow
+// redirects.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: dialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialControl rejects connections to addresses that are not publicly routable.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("destination %s is not a public address", ap.Addr())
+	}
+	return nil
+}
+
+// nonPublic lists the ranges that are not routable on the internet but have no
+// netip predicate: "this network" (reaches the local host on Linux) and the
+// carrier-grade NAT shared space.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// publicAddr reports whether ip may be the target of a webhook.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Observe queues a hub event without blocking the publisher.
+func (d *Dispatcher) Observe(e realtime.Event) {
+	select {
+	case d.events <- e:
+	default:
+		if n := d.dropped.Add(1); n%100 == 1 {
+			log.Printf("Warning: webhook event queue full, %d events dropped", n)
+		}
+	}
+}
+
+// Wake asks the dispatcher to check for due deliveries now.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the dispatcher until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.enqueueLoop(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		d.sendLoop(ctx)
+	}()
+	go func() {
+		wg.Wait()
+		close(d.done)
+	}()
+}
+
+// Wait blocks until the dispatcher has stopped.
+func (d *Dispatcher) Wait() {
+	<-d.done
+}
+
+func (d *Dispatcher) enqueueLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.events:
+			if d.enqueue(ctx, e) {
+				d.Wake()
+			}
+		}
+	}
+}
+
+// enqueue stores the deliveries of an event, reporting whether any were created.
+func (d *Dispatcher) enqueue(ctx context.Context, e realtime.Event) bool {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("Webhooks: failed to generate event id: %v", err)
+		return false
+	}
+
+	created := false
+	for _, typ := range eventTypes(e) {
+		body, err := json.Marshal(Payload{ID: hex.EncodeToString(id), Type: typ, DeviceID: e.DeviceID, Time: e.Time, Data: e.Data})
+		if err != nil {
+			log.Printf("Webhooks: failed to encode %s event: %v", typ, err)
+			continue
+		}
+		n, err := d.repo.Enqueue(ctx, e.UserID, typ, string(body))
+		if err != nil {
+			log.Printf("Webhooks: failed to queue %s event for user %d: %v", typ, e.UserID, err)
+			continue
+		}
+		created = created || n > 0
+	}
+	return created
+}
+
+// eventTypes maps a hub event to the webhook event types it triggers.
+func eventTypes(e realtime.Event) []string {
+	switch e.Type {
+	case realtime.EventTelemetry:
+		return []string{EventTelemetryCreated}
+	case realtime.EventDeviceStatus:
+		if s, ok := e.Data.(realtime.DeviceStatus); ok && s.Commanded {
+			return []string{EventDeviceStatusChanged, EventDeviceToggled}
+		}
+		return []string{EventDeviceStatusChanged}
+	case realtime.EventAlert:
+		a, ok := e.Data.(*alerts.Alert)
+		switch {
+		case !ok:
+			return nil
+		case a.State == alerts.StateOpen:
+			return []string{EventAlertFired}
+		case a.State == alerts.StateResolved:
+			return []string{EventAlertResolved}
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) sendLoop(ctx context.Context) {
+	tick := time.NewTicker(dispatchInterval)
+	defer tick.Stop()
+
+	for {
+		d.DispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchDue sends every delivery whose next attempt is due.
+func (d *Dispatcher) DispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := d.repo.claimDue(ctx, dispatchBatch)
+		if err != nil {
+			log.Printf("Webhooks: failed to read deliveries: %v", err)
+			return
+		}
+
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(j *job) {
+				defer func() { <-sem; wg.Done() }()
+				d.deliver(ctx, j)
+			}(&batch[i])
+		}
+		wg.Wait()
+
+		if len(batch) < dispatchBatch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, j *job) {
+	a := d.send(ctx, j)
+	if ctx.Err() != nil && a.Status != StatusDelivered {
+		return // shutting down: the claim lease expires and the delivery is retried
+	}
+	if err := d.repo.recordAttempt(ctx, j.ID, a); err != nil {
+		log.Printf("Webhooks: failed to record attempt for delivery %d: %v", j.ID, err)
+	}
+}
+
+// send POSTs a delivery once and classifies the outcome.
+func (d *Dispatcher) send(ctx context.Context, j *job) *attempt {
+	body := []byte(j.Payload)
+	now := time.Now()
+
+	a := &attempt{}
+	fail := func(msg string) *attempt {
+		a.Error = &msg
+		if attempts := j.Attempts + 1; attempts >= maxAttempts {
+			a.Status = StatusFailed
+		} else {
+			a.Status = StatusPending
+			a.Next = time.Now().Add(retry.Backoff(attempts))
+		}
+		return a
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.URL, bytes.NewReader(body))
+	if err != nil {
+		return fail(err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EnergyController-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, j.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(j.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(j.Secret, now.Unix(), body))
+
+	resp, err := d.client.Do(req)
+	a.Duration = time.Since(now)
+	if err != nil {
+		return fail(err.Error())
+	}
+	defer resp.Body.Close()
+
+	status := resp.StatusCode
+	a.ResponseStatus = &status
+	if b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody)); err == nil && len(b) > 0 {
+		// Stored as TEXT: drop what Postgres would reject
+		s := strings.ReplaceAll(strings.ToValidUTF8(string(b), "\uFFFD"), "\x00", "")
+		a.ResponseBody = &s
+	}
+	if status < 200 || status > 299 {
+		return fail(fmt.Sprintf("unexpected status %d", status))
+	}
+	a.Status = StatusDelivered
+	return a
+}
@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/alerts"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"telemetry.created"}`)
+	// printf '1717430400.{"type":"telemetry.created"}' | openssl dgst -sha256 -hmac whsec_test
+	const want = "sha256=07ef8f8a7d03e272a325dbb5029ee22636ffca5981542b29ebde4d00301ceaa0"
+
+	if got := Sign("whsec_test", 1717430400, body); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+	for name, got := range map[string]string{
+		"secret":    Sign("whsec_other", 1717430400, body),
+		"timestamp": Sign("whsec_test", 1717430401, body),
+		"body":      Sign("whsec_test", 1717430400, []byte(`{"type":"alert.fired"}`)),
+	} {
+		if got == want {
+			t.Errorf("changing the %s keeps the signature", name)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"100.63.255.255", true},     // just below the shared space
+		{"100.128.0.0", true},        // just above it
+		{"0.0.0.0", false},           // unspecified
+		{"0.1.2.3", false},           // "this network"
+		{"127.0.0.1", false},         // loopback
+		{"::1", false},               // loopback
+		{"10.1.2.3", false},          // private
+		{"172.16.0.1", false},        // private
+		{"192.168.1.10", false},      // private
+		{"fd00::1", false},           // unique local
+		{"100.64.0.1", false},        // carrier-grade NAT
+		{"100.127.255.254", false},   // carrier-grade NAT
+		{"169.254.169.254", false},   // link-local, cloud metadata
+		{"fe80::1", false},           // link-local
+		{"224.0.0.1", false},         // multicast
+		{"ff02::1", false},           // multicast
+		{"::ffff:127.0.0.1", false},  // IPv4-mapped loopback
+		{"::ffff:100.64.0.1", false}, // IPv4-mapped shared space
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if publicAddr(netip.Addr{}) {
+		t.Error("the zero address is public")
+	}
+}
+
+func TestEventTypes(t *testing.T) {
+	on := true
+	tests := []struct {
+		name  string
+		event realtime.Event
+		want  []string
+	}{
+		{"telemetry", realtime.TelemetryEvent(1, 2, nil), []string{EventTelemetryCreated}},
+		{"status change", realtime.StatusEvent(1, 2, "offline", nil), []string{EventDeviceStatusChanged}},
+		{"command", realtime.CommandEvent(1, 2, "online", &on), []string{EventDeviceStatusChanged, EventDeviceToggled}},
+		{"alert opened", realtime.AlertEvent(1, 2, &alerts.Alert{State: alerts.StateOpen}), []string{EventAlertFired}},
+		{"alert resolved", realtime.AlertEvent(1, 2, &alerts.Alert{State: alerts.StateResolved}), []string{EventAlertResolved}},
+		{"alert acknowledged", realtime.AlertEvent(1, 2, &alerts.Alert{State: alerts.StateAcknowledged}), nil},
+		{"alert of another type", realtime.AlertEvent(1, 2, alerts.Alert{State: alerts.StateOpen}), nil},
+		{"unknown event", realtime.Event{Type: "heartbeat"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventTypes(tt.event); !slices.Equal(got, tt.want) {
+				t.Errorf("eventTypes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
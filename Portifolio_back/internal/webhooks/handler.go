@@ -0,0 +1,281 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles webhook HTTP requests.
+type Handler struct {
+	Repo       *Repo
+	Dispatcher *Dispatcher // woken on redelivery; may be nil
+}
+
+// NewHandler creates a new webhooks handler.
+func NewHandler(repo *Repo, dispatcher *Dispatcher) *Handler {
+	return &Handler{Repo: repo, Dispatcher: dispatcher}
+}
+
+// RegisterRoutes registers webhook routes.
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	w := r.Group("/webhooks")
+	w.GET("", h.List)
+	w.POST("", h.Create)
+	w.GET("/events", h.ListEventTypes)
+	w.GET("/:id", h.Get)
+	w.PUT("/:id", h.Update)
+	w.DELETE("/:id", h.Delete)
+	w.GET("/:id/deliveries", h.ListDeliveries)
+	w.POST("/:id/deliveries/:deliveryId/redeliver", h.Redeliver)
+}
+
+// ListEventTypes returns the event types webhooks can subscribe to.
+func (h *Handler) ListEventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, EventTypes)
+}
+
+// List returns the user's webhooks.
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.Repo.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch webhooks"})
+		return
+	}
+	if list == nil {
+		list = []Webhook{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Get returns one of the user's webhooks.
+func (h *Handler) Get(c *gin.Context) {
+	w, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+// Create adds a webhook. The secret is returned only in this response
+// (and when changed through Update).
+func (h *Handler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+			return
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	w, err := h.Repo.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+	w.Secret = req.Secret
+	c.JSON(http.StatusCreated, w)
+}
+
+// Update replaces one of the user's webhooks; an empty secret keeps the current one.
+func (h *Handler) Update(c *gin.Context) {
+	userID, id, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+
+	w, err := h.Repo.Update(c.Request.Context(), userID, id, req)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update webhook"})
+		return
+	}
+	w.Secret = req.Secret
+	c.JSON(http.StatusOK, w)
+}
+
+// Delete removes one of the user's webhooks and its delivery log.
+func (h *Handler) Delete(c *gin.Context) {
+	userID, id, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.Delete(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery log of a webhook.
+// Query params: status (pending|delivered|failed), limit (default 50)
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	w, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", StatusPending, StatusDelivered, StatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+	limit := 0
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	list, err := h.Repo.ListDeliveries(c.Request.Context(), w.ID, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch deliveries"})
+		return
+	}
+	if list == nil {
+		list = []Delivery{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Redeliver queues a delivery's event again as a new delivery.
+func (h *Handler) Redeliver(c *gin.Context) {
+	w, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return
+	}
+
+	d, err := h.Repo.Redeliver(c.Request.Context(), w.ID, deliveryID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue redelivery"})
+		return
+	}
+	if h.Dispatcher != nil {
+		h.Dispatcher.Wake()
+	}
+	c.JSON(http.StatusAccepted, d)
+}
+
+// bindWebhook parses and validates a webhook payload, writing the error response if invalid.
+func bindWebhook(c *gin.Context) (*WebhookRequest, bool) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload: url and at least one event are required"})
+		return nil, false
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an https URL without credentials"})
+		return nil, false
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); (err == nil && !publicAddr(ip)) || strings.EqualFold(u.Hostname(), "localhost") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must point to a public host"})
+		return nil, false
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(EventTypes, e) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown event type %q", e)})
+			return nil, false
+		}
+	}
+	if req.Secret != "" && len(req.Secret) < 16 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "secret must be at least 16 characters"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// ownedWebhook loads the :id webhook of the user, writing the error response if not found.
+func (h *Handler) ownedWebhook(c *gin.Context) (*Webhook, bool) {
+	userID, id, ok := webhookParams(c)
+	if !ok {
+		return nil, false
+	}
+
+	w, err := h.Repo.GetForUser(c.Request.Context(), userID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch webhook"})
+		return nil, false
+	}
+	return w, true
+}
+
+// webhookParams extracts the user ID and the :id parameter, writing the error response if invalid.
+func webhookParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
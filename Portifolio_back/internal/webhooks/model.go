@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types a webhook can subscribe to.
+const (
+	EventTelemetryCreated    = "telemetry.created"
+	EventDeviceStatusChanged = "device.status_changed"
+	EventDeviceToggled       = "device.toggled"
+	EventAlertFired          = "alert.fired"
+	EventAlertResolved       = "alert.resolved"
+)
+
+// EventTypes lists every event type a webhook can subscribe to.
+var EventTypes = []string{
+	EventTelemetryCreated,
+	EventDeviceStatusChanged,
+	EventDeviceToggled,
+	EventAlertFired,
+	EventAlertResolved,
+}
+
+// Delivery states.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed" // gave up after the maximum number of attempts
+)
+
+// Webhook is a user-configured endpoint that receives events.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"` // only returned when created or changed
+	Events      []string  `json:"events"`
+	Description *string   `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookRequest is the payload for creating or replacing a webhook.
+// An empty secret generates a random one on create and keeps the current one on update.
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	Description *string  `json:"description"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// Delivery is one event sent (or to be sent) to a webhook.
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   *string         `json:"response_body,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Payload is the JSON body POSTed to webhooks.
+type Payload struct {
+	ID       string    `json:"id"` // unique per event, shared by every webhook receiving it
+	Type     string    `json:"type"`
+	DeviceID int64     `json:"device_id"`
+	Time     time.Time `json:"time"`
+	Data     any       `json:"data"`
+}
+
+// job is a claimed delivery with what is needed to send it.
+type job struct {
+	ID        int64
+	WebhookID int64
+	URL       string
+	Secret    string
+	EventType string
+	Payload   string
+	Attempts  int
+}
+
+// attempt is the outcome of sending a delivery once.
+type attempt struct {
+	Status         string
+	ResponseStatus *int
+	ResponseBody   *string
+	Error          *string
+	Duration       time.Duration
+	Next           time.Time // next attempt when Status is pending
+}
@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when a webhook or delivery does not exist (or belongs to another user).
+var ErrNotFound = errors.New("not found")
+
+// Querier is an interface for database operations (compatible with pgxpool.Pool wrapper).
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) error
+	QueryRow(ctx context.Context, sql string, args ...any) interface{ Scan(dest ...any) error }
+}
+
+// RowsQuerier extends Querier with Query support for listing.
+type RowsQuerier interface {
+	Querier
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Rows is an interface for query result iteration.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close()
+	Err() error
+}
+
+// claimLease is how long a claimed delivery stays hidden from other dispatchers.
+const claimLease = 5 * time.Minute
+
+// Repo provides database operations for webhooks and their deliveries.
+type Repo struct {
+	q RowsQuerier
+}
+
+// NewRepo creates a new webhooks repository.
+func NewRepo(q RowsQuerier) *Repo {
+	return &Repo{q: q}
+}
+
+const webhookColumns = `id, user_id, url, events, description, enabled, created_at, updated_at`
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*Webhook, error) {
+	var w Webhook
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, &w.Events, &w.Description, &w.Enabled, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListByUser returns the webhooks of a user.
+func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Webhook, error) {
+	sql := `SELECT ` + webhookColumns + ` FROM webhook WHERE user_id = $1 ORDER BY id`
+	rows, err := r.q.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *w)
+	}
+	return list, rows.Err()
+}
+
+// GetForUser returns a webhook of a user, or ErrNotFound.
+func (r *Repo) GetForUser(ctx context.Context, userID, id int64) (*Webhook, error) {
+	sql := `SELECT ` + webhookColumns + ` FROM webhook WHERE id = $1 AND user_id = $2`
+	w, err := scanWebhook(r.q.QueryRow(ctx, sql, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return w, err
+}
+
+// Create inserts a webhook. req.Secret must be set.
+func (r *Repo) Create(ctx context.Context, userID int64, req *WebhookRequest) (*Webhook, error) {
+	sql := `INSERT INTO webhook (user_id, url, secret, events, description, enabled)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING ` + webhookColumns
+	return scanWebhook(r.q.QueryRow(ctx, sql, userID, req.URL, req.Secret, req.Events, req.Description,
+		req.Enabled == nil || *req.Enabled))
+}
+
+// Update replaces a webhook of a user, keeping its secret when req.Secret is empty.
+// It returns ErrNotFound when there is no such webhook.
+func (r *Repo) Update(ctx context.Context, userID, id int64, req *WebhookRequest) (*Webhook, error) {
+	sql := `UPDATE webhook SET url = $3, secret = COALESCE(NULLIF($4, ''), secret), events = $5,
+				description = $6, enabled = $7, updated_at = NOW()
+			WHERE id = $1 AND user_id = $2
+			RETURNING ` + webhookColumns
+	w, err := scanWebhook(r.q.QueryRow(ctx, sql, id, userID, req.URL, req.Secret, req.Events, req.Description,
+		req.Enabled == nil || *req.Enabled))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return w, err
+}
+
+// Delete removes a webhook of a user and its delivery log.
+// It returns ErrNotFound when there is no such webhook.
+func (r *Repo) Delete(ctx context.Context, userID, id int64) error {
+	var deleted int64
+	err := r.q.QueryRow(ctx, `DELETE FROM webhook WHERE id = $1 AND user_id = $2 RETURNING id`, id, userID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Enqueue creates a pending delivery of an event for every enabled webhook
+// of the user subscribed to its type, returning how many were created.
+func (r *Repo) Enqueue(ctx context.Context, userID int64, eventType, payload string) (int, error) {
+	sql := `WITH inserted AS (
+				INSERT INTO webhook_delivery (webhook_id, event_type, payload)
+				SELECT id, $2, $3 FROM webhook
+				WHERE user_id = $1 AND enabled AND $2 = ANY(events)
+				RETURNING 1
+			)
+			SELECT COUNT(*) FROM inserted`
+	var n int
+	err := r.q.QueryRow(ctx, sql, userID, eventType, payload).Scan(&n)
+	return n, err
+}
+
+// claimDue returns up to limit pending deliveries of enabled webhooks whose next
+// attempt is due and pushes that attempt back by claimLease.
+func (r *Repo) claimDue(ctx context.Context, limit int) ([]job, error) {
+	sql := `WITH claimed AS (
+				UPDATE webhook_delivery SET next_attempt_at = NOW() + make_interval(secs => $2)
+				WHERE id IN (
+					SELECT dl.id FROM webhook_delivery dl
+					INNER JOIN webhook w ON w.id = dl.webhook_id
+					WHERE dl.status = 'pending' AND dl.next_attempt_at <= NOW() AND w.enabled
+					ORDER BY dl.next_attempt_at, dl.id
+					LIMIT $1
+					FOR UPDATE OF dl SKIP LOCKED
+				)
+				RETURNING id, webhook_id, event_type, payload, attempts
+			)
+			SELECT c.id, c.webhook_id, w.url, w.secret, c.event_type, c.payload, c.attempts
+			FROM claimed c
+			INNER JOIN webhook w ON w.id = c.webhook_id`
+	rows, err := r.q.Query(ctx, sql, limit, claimLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.ID, &j.WebhookID, &j.URL, &j.Secret, &j.EventType, &j.Payload, &j.Attempts); err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
+
+// recordAttempt stores the outcome of sending a delivery.
+func (r *Repo) recordAttempt(ctx context.Context, id int64, a *attempt) error {
+	sql := `UPDATE webhook_delivery SET status = $2, attempts = attempts + 1, response_status = $3,
+				response_body = $4, last_error = $5, duration_ms = $6, next_attempt_at = $7,
+				delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+			WHERE id = $1`
+	next := a.Next
+	if next.IsZero() {
+		next = time.Now()
+	}
+	return r.q.Exec(ctx, sql, id, a.Status, a.ResponseStatus, a.ResponseBody, a.Error,
+		int(a.Duration.Milliseconds()), next)
+}
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+			response_status, response_body, last_error, duration_ms, redelivery_of, created_at, delivered_at`
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*Delivery, error) {
+	var d Delivery
+	var payload string
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.DurationMs, &d.RedeliveryOf, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = []byte(payload)
+	return &d, nil
+}
+
+// ListDeliveries returns the latest deliveries of a webhook, newest first.
+func (r *Repo) ListDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]Delivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	sql := `SELECT ` + deliveryColumns + ` FROM webhook_delivery
+			WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+			ORDER BY created_at DESC, id DESC
+			LIMIT $3`
+	rows, err := r.q.Query(ctx, sql, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+	return list, rows.Err()
+}
+
+// Redeliver queues a new delivery with the same event as an existing one.
+// It returns ErrNotFound when the delivery does not belong to the webhook.
+func (r *Repo) Redeliver(ctx context.Context, webhookID, deliveryID int64) (*Delivery, error) {
+	sql := `INSERT INTO webhook_delivery (webhook_id, event_type, payload, redelivery_of)
+			SELECT webhook_id, event_type, payload, id FROM webhook_delivery
+			WHERE id = $1 AND webhook_id = $2
+			RETURNING ` + deliveryColumns
+	d, err := scanDelivery(r.q.QueryRow(ctx, sql, deliveryID, webhookID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
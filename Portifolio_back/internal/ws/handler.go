@@ -0,0 +1,413 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/devices"
+	"github.com/pedrohdcosta/projetoPortifolio/Portifolio_back/internal/realtime"
+)
+
+const (
+	writeWait       = 10 * time.Second // deadline for a single write
+	pongWait        = 60 * time.Second // connection is dropped without a pong for this long
+	pingPeriod      = 25 * time.Second // must be shorter than pongWait
+	maxMessageSize  = 4096
+	sendBuffer      = 64
+	commandTimeout  = 20 * time.Second // plug handshakes can be slow
+	commandsPerConn = 4                // concurrent commands per connection
+)
+
+// Handler serves the WebSocket control channel.
+type Handler struct {
+	Control *devices.Controller
+	Events  *realtime.Hub
+
+	upgrader websocket.Upgrader
+}
+
+// NewHandler creates a control channel handler. Cross-origin browsers are
+// accepted only when listed in WS_ALLOWED_ORIGINS (comma-separated).
+func NewHandler(control *devices.Controller, events *realtime.Hub) *Handler {
+	allowed := parseOrigins(os.Getenv("WS_ALLOWED_ORIGINS"))
+	h := &Handler{Control: control, Events: events}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     func(r *http.Request) bool { return checkOrigin(r, allowed) },
+	}
+	return h
+}
+
+// RegisterRoutes registers the control channel route (/api/ws).
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/ws", h.Serve)
+}
+
+// Serve upgrades the request and runs the connection until either side closes it.
+func (h *Handler) Serve(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ws, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		log.Printf("WebSocket: upgrade failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := &conn{
+		h:      h,
+		ws:     ws,
+		userID: userID,
+		send:   make(chan Message, sendBuffer),
+		sem:    make(chan struct{}, commandsPerConn),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	conn.run()
+}
+
+// conn is one WebSocket client. Only writeLoop writes to ws.
+type conn struct {
+	h      *Handler
+	ws     *websocket.Conn
+	userID int64
+	send   chan Message
+	sem    chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	all     bool           // subscribed to every device of the user
+	devices map[int64]bool // subscribed devices when all is false
+}
+
+func (c *conn) run() {
+	sub := c.h.Events.Subscribe(c.userID, 0)
+	defer c.h.Events.Unsubscribe(sub)
+
+	go c.writeLoop(sub)
+	c.readLoop()
+
+	// Stop running commands and wait for them before the send channel goes away
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *conn) readLoop() {
+	c.ws.SetReadLimit(maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg Message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket: read error for user %d: %v", c.userID, err)
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+		c.handle(msg)
+	}
+}
+
+// writeLoop forwards queued messages and hub events, and pings the client.
+func (c *conn) writeLoop(sub *realtime.Subscription) {
+	ping := time.NewTicker(pingPeriod)
+	defer func() {
+		// Nothing reads c.send any more: stop running commands so they do not
+		// wait on it, and close the socket so readLoop returns too
+		c.cancel()
+		ping.Stop()
+		c.ws.Close()
+	}()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case msg := <-c.send:
+			if err := c.write(msg); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				// Hub closed: the server is shutting down
+				_ = c.ws.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(writeWait))
+				return
+			}
+			if !c.subscribed(e.DeviceID) {
+				continue
+			}
+			if err := c.write(eventMessage(e)); err != nil {
+				return
+			}
+		case <-ping.C:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) write(msg Message) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(msg)
+}
+
+// reply queues a message, giving up if the connection is closing.
+func (c *conn) reply(msg Message) {
+	select {
+	case c.send <- msg:
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *conn) handle(msg Message) {
+	switch msg.Type {
+	case TypePing:
+		c.reply(Message{Type: TypePong, ID: msg.ID})
+	case TypeSubscribe:
+		c.subscribe(msg)
+	case TypeUnsubscribe:
+		c.unsubscribe(msg)
+	case TypeCommand:
+		c.command(msg)
+	default:
+		c.reply(errorMessage(msg.ID, "unknown message type"))
+	}
+}
+
+// subscribe adds devices to the event filter after checking the user owns them.
+func (c *conn) subscribe(msg Message) {
+	for _, id := range msg.DeviceIDs {
+		if _, err := c.h.Control.DeviceForUser(c.ctx, c.userID, id); err != nil {
+			c.reply(errorMessage(msg.ID, "device "+strconv.FormatInt(id, 10)+" not found"))
+			return
+		}
+	}
+
+	c.mu.Lock()
+	if len(msg.DeviceIDs) == 0 {
+		c.all = true
+	} else {
+		if c.devices == nil {
+			c.devices = make(map[int64]bool)
+		}
+		for _, id := range msg.DeviceIDs {
+			c.devices[id] = true
+		}
+	}
+	c.mu.Unlock()
+
+	c.reply(Message{Type: TypeSubscribed, ID: msg.ID, DeviceIDs: msg.DeviceIDs})
+}
+
+func (c *conn) unsubscribe(msg Message) {
+	c.mu.Lock()
+	if len(msg.DeviceIDs) == 0 {
+		c.all = false
+		c.devices = nil
+	} else {
+		for _, id := range msg.DeviceIDs {
+			delete(c.devices, id)
+		}
+	}
+	c.mu.Unlock()
+
+	c.reply(Message{Type: TypeSubscribed, ID: msg.ID, DeviceIDs: c.subscribedIDs()})
+}
+
+func (c *conn) subscribed(deviceID int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.all || c.devices[deviceID]
+}
+
+func (c *conn) subscribedIDs() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]int64, 0, len(c.devices))
+	for id := range c.devices {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// command runs a device command in the background and replies with command_result,
+// so a slow plug never blocks the connection.
+func (c *conn) command(msg Message) {
+	switch msg.Action {
+	case ActionToggle, ActionOn, ActionOff, ActionRead:
+	default:
+		c.reply(commandFailed(msg, "unknown action", ""))
+		return
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+	default:
+		c.reply(commandFailed(msg, "too many commands in progress", ""))
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() { <-c.sem }()
+
+		ctx, cancel := context.WithTimeout(c.ctx, commandTimeout)
+		defer cancel()
+		c.reply(c.execute(ctx, msg))
+	}()
+}
+
+func (c *conn) execute(ctx context.Context, msg Message) Message {
+	d, err := c.h.Control.DeviceForUser(ctx, c.userID, msg.DeviceID)
+	if err != nil {
+		return commandFailed(msg, "device not found", "")
+	}
+
+	var data any
+	switch msg.Action {
+	case ActionRead:
+		data, err = c.read(ctx, d)
+	case ActionToggle:
+		data, err = deviceStatus(c.h.Control.Toggle(ctx, d))
+	default:
+		data, err = deviceStatus(c.h.Control.SetPower(ctx, d, msg.Action == ActionOn))
+	}
+	if err != nil {
+		errMsg, detail := commandError(err)
+		return commandFailed(msg, errMsg, detail)
+	}
+
+	ok := true
+	return Message{Type: TypeCommandResult, ID: msg.ID, DeviceID: msg.DeviceID, Action: msg.Action, OK: &ok, Data: data}
+}
+
+func (c *conn) read(ctx context.Context, d *devices.Device) (any, error) {
+	reading, err := c.h.Control.Read(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	return reading, nil
+}
+
+// deviceStatus reduces a command's updated device to the fields clients need.
+func deviceStatus(d *devices.Device, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return realtime.DeviceStatus{Status: d.Status, PowerState: d.PowerState}, nil
+}
+
+func commandFailed(msg Message, errMsg, detail string) Message {
+	ok := false
+	return Message{
+		Type:     TypeCommandResult,
+		ID:       msg.ID,
+		DeviceID: msg.DeviceID,
+		Action:   msg.Action,
+		OK:       &ok,
+		Error:    errMsg,
+		Detail:   detail,
+	}
+}
+
+// commandError splits a Controller error into a stable message and the driver detail.
+func commandError(err error) (string, string) {
+	for _, kind := range []error{
+		devices.ErrIncompleteConnection,
+		devices.ErrDeviceControl,
+		devices.ErrDeviceRead,
+	} {
+		if errors.Is(err, kind) {
+			return kind.Error(), devices.ErrorDetail(err, kind)
+		}
+	}
+	for _, kind := range []error{
+		devices.ErrNotSwitchable,
+		devices.ErrNotConfigured,
+		devices.ErrCredentials,
+	} {
+		if errors.Is(err, kind) {
+			return kind.Error(), ""
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "device did not respond in time", ""
+	}
+	return devices.ErrStateUpdate.Error(), ""
+}
+
+// eventMessage converts a hub event to its wire form.
+func eventMessage(e realtime.Event) Message {
+	typ := TypeTelemetry
+	switch e.Type {
+	case realtime.EventDeviceStatus:
+		typ = TypeStatus
+	case realtime.EventAlert:
+		typ = TypeAlert
+	}
+	return Message{Type: typ, DeviceID: e.DeviceID, Data: e.Data, Time: &e.Time}
+}
+
+// checkOrigin accepts same-origin requests, clients that send no Origin (non-browsers)
+// and the configured allowed origins.
+func checkOrigin(r *http.Request, allowed map[string]bool) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || allowed[strings.ToLower(origin)]
+}
+
+func parseOrigins(spec string) map[string]bool {
+	allowed := make(map[string]bool)
+	for _, o := range strings.Split(spec, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			allowed[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+		}
+	}
+	return allowed
+}
+
+// getUserID extracts user ID from Gin context (set by auth middleware).
+func getUserID(c *gin.Context) (int64, bool) {
+	sub := c.GetString("sub")
+	if sub == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,47 @@
+package ws
+
+import "time"
+
+// Message types exchanged over the control channel.
+const (
+	// Client to server
+	TypeSubscribe   = "subscribe"   // device_ids: devices to receive events for (empty = all)
+	TypeUnsubscribe = "unsubscribe" // device_ids: devices to stop receiving (empty = all)
+	TypeCommand     = "command"     // id, device_id, action
+	TypePing        = "ping"
+
+	// Server to client
+	TypeSubscribed    = "subscribed"
+	TypeCommandResult = "command_result" // id, device_id, ok, data or error
+	TypeTelemetry     = "telemetry"
+	TypeStatus        = "status"
+	TypeAlert         = "alert"
+	TypePong          = "pong"
+	TypeError         = "error"
+)
+
+// Command actions.
+const (
+	ActionToggle = "toggle"
+	ActionOn     = "on"
+	ActionOff    = "off"
+	ActionRead   = "read"
+)
+
+// Message is the envelope of every frame. Fields not relevant to a type are omitted.
+type Message struct {
+	Type      string     `json:"type"`
+	ID        string     `json:"id,omitempty"` // client correlation id, echoed in command_result
+	DeviceID  int64      `json:"device_id,omitempty"`
+	DeviceIDs []int64    `json:"device_ids,omitempty"`
+	Action    string     `json:"action,omitempty"`
+	OK        *bool      `json:"ok,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+	Data      any        `json:"data,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+}
+
+func errorMessage(id, msg string) Message {
+	return Message{Type: TypeError, ID: id, Error: msg}
+}
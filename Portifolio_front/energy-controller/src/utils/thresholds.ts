@@ -1,48 +1,92 @@
+import { reactive } from 'vue'
+import { isAxiosError } from 'axios'
+import api from '../api/axios'
+
 export interface DeviceThresholds {
   warning: number
   danger: number
 }
 
-const STORAGE_KEY = 'device_thresholds_v1'
+interface ThresholdResponse extends DeviceThresholds {
+  device_id: number
+}
+
+// Thresholds are stored by the backend (which also raises alerts from them);
+// this cache keeps lookups synchronous for rendering.
+const cache = reactive<Record<string, DeviceThresholds>>({})
 
-function loadAll(): Record<string, DeviceThresholds> {
+// Thresholds used to live only in the browser under this key; the first load
+// uploads them so they are not lost.
+const LEGACY_STORAGE_KEY = 'device_thresholds_v1'
+let legacyMigrated = false
+
+export async function loadThresholds(): Promise<Record<string, DeviceThresholds>> {
+  const { data } = await api.get<ThresholdResponse[]>('/thresholds')
+  for (const key of Object.keys(cache)) delete cache[key]
+  for (const t of data) {
+    cache[String(t.device_id)] = { warning: t.warning, danger: t.danger }
+  }
+  if (!legacyMigrated) {
+    legacyMigrated = true
+    await migrateLegacyThresholds()
+  }
+  return cache
+}
+
+// Pushes the thresholds kept in localStorage to the backend, skipping devices
+// that already have thresholds there. Entries the backend rejects (device
+// removed, invalid values) are dropped; entries that failed for another reason
+// stay stored and are retried on the next page load.
+async function migrateLegacyThresholds() {
+  let legacy: Record<string, DeviceThresholds>
   try {
-    const raw = localStorage.getItem(STORAGE_KEY)
-    if (!raw) return {}
-    return JSON.parse(raw)
+    const raw = localStorage.getItem(LEGACY_STORAGE_KEY)
+    if (!raw) return
+    legacy = JSON.parse(raw)
   } catch (e) {
-    console.warn('Failed to load thresholds', e)
-    return {}
+    console.warn('Failed to read stored thresholds', e)
+    return
+  }
+
+  const pending: Record<string, DeviceThresholds> = {}
+  for (const [deviceId, thresholds] of Object.entries(legacy)) {
+    if (cache[deviceId]) continue
+    try {
+      await setThresholds(Number(deviceId), thresholds)
+    } catch (e) {
+      const status = isAxiosError(e) ? e.response?.status : undefined
+      if (status === 400 || status === 404) console.warn(`Dropped stored thresholds of device ${deviceId}`, e)
+      else pending[deviceId] = thresholds
+    }
   }
-}
 
-function saveAll(map: Record<string, DeviceThresholds>) {
   try {
-    localStorage.setItem(STORAGE_KEY, JSON.stringify(map))
+    if (Object.keys(pending).length > 0) {
+      localStorage.setItem(LEGACY_STORAGE_KEY, JSON.stringify(pending))
+    } else {
+      localStorage.removeItem(LEGACY_STORAGE_KEY)
+    }
   } catch (e) {
-    console.warn('Failed to save thresholds', e)
+    console.warn('Failed to update stored thresholds', e)
   }
 }
 
 export function getThresholds(deviceId: number): DeviceThresholds | undefined {
-  const map = loadAll()
-  return map[String(deviceId)]
+  return cache[String(deviceId)]
 }
 
-export function setThresholds(deviceId: number, thresholds: DeviceThresholds) {
-  const map = loadAll()
-  map[String(deviceId)] = thresholds
-  saveAll(map)
+export async function setThresholds(deviceId: number, thresholds: DeviceThresholds) {
+  const { data } = await api.put<ThresholdResponse>(`/devices/${deviceId}/thresholds`, thresholds)
+  cache[String(deviceId)] = { warning: data.warning, danger: data.danger }
 }
 
-export function removeThresholds(deviceId: number) {
-  const map = loadAll()
-  delete map[String(deviceId)]
-  saveAll(map)
+export async function removeThresholds(deviceId: number) {
+  await api.delete(`/devices/${deviceId}/thresholds`)
+  delete cache[String(deviceId)]
 }
 
 export function getAllThresholds(): Record<string, DeviceThresholds> {
-  return loadAll()
+  return cache
 }
 
-export default { getThresholds, setThresholds, removeThresholds, getAllThresholds }
+export default { loadThresholds, getThresholds, setThresholds, removeThresholds, getAllThresholds }